@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ============================================================================
+// OFFLINE ANALYSIS COMMAND
+// ============================================================================
+// `neurapay analyze spending|personality|recurring|counterparties` runs the same analytics
+// as the tools directly on a statement file - the transactions.csv schema, a
+// bank CSV export, OFX/QFX, QIF, camt.053 or MT940 - no LLM, no Liminal, no
+// server.
+
+const analyzeUsage = `Usage: neurapay analyze spending|personality|recurring|counterparties [flags]
+
+Flags:
+  --csv file     transactions as CSV, OFX/QFX, QIF, camt.053 or MT940 (default: transactions.csv)
+  --days N       analyze the N days up to the newest transaction; 0 = all (default: 30)
+  --format f     table or json (default: table)
+  --limit N      counterparties per ranking (default: 10)
+  --heatmap      spending: add spend by weekday, hour and day of month
+  --tz name      IANA timezone to read spending times in (default: UTC)
+`
+
+func runAnalyzeCommand(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprint(os.Stderr, analyzeUsage)
+		return 2
+	}
+	kind := args[0]
+
+	fs := flag.NewFlagSet("analyze "+kind, flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, analyzeUsage) }
+	csvPath := fs.String("csv", "transactions.csv", "")
+	days := fs.Int("days", 30, "")
+	format := fs.String("format", "table", "")
+	limit := fs.Int("limit", 10, "")
+	heatmap := fs.Bool("heatmap", false, "")
+	tz := fs.String("tz", "", "")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	loc, err := heatmapLocation(*tz)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ --tz: %v\n", err)
+		return 2
+	}
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "❌ --format must be table or json, got %q\n", *format)
+		return 2
+	}
+	if *days < 0 {
+		fmt.Fprintln(os.Stderr, "❌ --days cannot be negative")
+		return 2
+	}
+	if *limit < 1 {
+		fmt.Fprintln(os.Stderr, "❌ --limit must be at least 1")
+		return 2
+	}
+
+	// loadTransactionsFromCSV logs for the server; keep stdout clean.
+	log.SetOutput(io.Discard)
+	transactions, err := loadTransactionsFromCSV(*csvPath)
+	log.SetOutput(os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+	all := transactions
+	transactions, window := lastDays(transactions, *days)
+
+	var (
+		result interface{}
+		table  func(w io.Writer)
+	)
+	switch kind {
+	case "spending":
+		analysis := analyzeTransactions(transactions, window)
+		out := map[string]interface{}{
+			"period_days":        window,
+			"total_transactions": len(transactions),
+			"analysis":           analysis,
+			"data_source":        map[string]string{"csv": *csvPath},
+		}
+		var hm *SpendingHeatmap
+		if *heatmap {
+			if hm = spendingHeatmap(transactions, loc); hm != nil {
+				out["heatmap"] = hm
+			}
+		}
+		result = out
+		table = func(w io.Writer) {
+			spendingTable(w, window, len(transactions), analysis)
+			if hm != nil {
+				heatmapTable(w, hm)
+			}
+		}
+
+	case "personality":
+		if len(transactions) < minPersonalityTransactions {
+			fmt.Fprintf(os.Stderr, "❌ Need at least %d transactions for accurate personality analysis, got %d\n", minPersonalityTransactions, len(transactions))
+			return 1
+		}
+		scores := calculatePersonalityScores(transactions)
+		archetype := matchArchetype(scores)
+		out := map[string]interface{}{
+			"personality_type":        archetype.Type,
+			"emoji":                   archetype.Emoji,
+			"confidence":              fmt.Sprintf("%.0f%%", archetype.Confidence*100),
+			"traits":                  archetype.Traits,
+			"behavioral_triggers":     archetype.Triggers,
+			"personalized_strategies": archetype.Strategies,
+			"fun_fact":                archetype.FunFact,
+			"raw_scores":              scores,
+		}
+		if hm := spendingHeatmap(transactions, loc); hm != nil && len(hm.Insights) > 0 {
+			out["trigger_evidence"] = hm.Insights
+		}
+		result = out
+		table = func(w io.Writer) { personalityTable(w, archetype, scores) }
+
+	case "recurring":
+		income := append([]RecurringSeries{}, detectRecurring(transactions, "receive")...)
+		bills := append([]RecurringSeries{}, detectRecurring(transactions, "send")...)
+		result = map[string]interface{}{
+			"period_days": window,
+			"income":      income,
+			"bills":       bills,
+		}
+		table = func(w io.Writer) { recurringTable(w, income, bills) }
+
+	case "counterparties":
+		// Uses the whole file: changes compare with the window before.
+		report := analyzeCounterparties(all, window, *limit, newestTransaction(all, time.Now()))
+		result = report
+		table = func(w io.Writer) { counterpartiesTable(w, report) }
+
+	default:
+		fmt.Fprintf(os.Stderr, "❌ unknown analysis %q\n\n%s", kind, analyzeUsage)
+		return 2
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	table(tw)
+	tw.Flush()
+	return 0
+}
+
+// lastDays keeps the transactions from the days up to and including the
+// newest one, so old exports analyze the same as fresh ones. It returns the
+// window length used for averages; days == 0 keeps everything and spans the
+// whole file.
+func lastDays(transactions []map[string]interface{}, days int) ([]map[string]interface{}, int) {
+	var newest, oldest time.Time
+	for _, tx := range transactions {
+		if ts, ok := txTime(tx); ok {
+			if ts.After(newest) {
+				newest = ts
+			}
+			if oldest.IsZero() || ts.Before(oldest) {
+				oldest = ts
+			}
+		}
+	}
+	if newest.IsZero() {
+		if days == 0 {
+			days = 30
+		}
+		return transactions, days
+	}
+	if days == 0 {
+		span := int(newest.Sub(oldest).Hours()/24) + 1
+		return transactions, span
+	}
+
+	since := newest.AddDate(0, 0, -days)
+	var kept []map[string]interface{}
+	for _, tx := range transactions {
+		if ts, ok := txTime(tx); ok && ts.After(since) {
+			kept = append(kept, tx)
+		}
+	}
+	return kept, days
+}
+
+func spendingTable(w io.Writer, days, count int, analysis map[string]interface{}) {
+	fmt.Fprintf(w, "SPENDING (%d days, %d transactions)\n", days, count)
+	if summary, ok := analysis["summary"]; ok {
+		fmt.Fprintln(w, summary)
+		return
+	}
+	for _, key := range []string{"total_spent", "total_received", "net_cashflow", "avg_daily_spend", "spend_count", "receive_count", "velocity"} {
+		fmt.Fprintf(w, "%s\t%v\n", strings.ReplaceAll(key, "_", " "), analysis[key])
+	}
+
+	top, _ := analysis["top_categories"].(map[string]string)
+	if len(top) > 0 {
+		categories := make([]string, 0, len(top))
+		for c := range top {
+			categories = append(categories, c)
+		}
+		amount := func(c string) float64 {
+			v, _ := numberValue(strings.TrimPrefix(top[c], "$"))
+			return v
+		}
+		sort.Slice(categories, func(i, j int) bool { return amount(categories[i]) > amount(categories[j]) })
+		fmt.Fprintln(w, "\nTOP CATEGORIES")
+		for _, c := range categories {
+			fmt.Fprintf(w, "%s\t%s\n", c, top[c])
+		}
+	}
+}
+
+func personalityTable(w io.Writer, archetype PersonalityArchetype, scores map[string]float64) {
+	fmt.Fprintf(w, "%s %s\t(confidence %.0f%%)\n\n", archetype.Emoji, archetype.Type, archetype.Confidence*100)
+
+	names := make([]string, 0, len(scores))
+	for name := range scores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "SCORES")
+	for _, name := range names {
+		fmt.Fprintf(w, "%s\t%.1f\n", strings.ReplaceAll(name, "_", " "), scores[name])
+	}
+
+	fmt.Fprintln(w, "\nTRAITS")
+	for _, t := range archetype.Traits {
+		fmt.Fprintf(w, "- %s\n", t)
+	}
+	fmt.Fprintln(w, "\nSTRATEGIES")
+	for _, s := range archetype.Strategies {
+		fmt.Fprintf(w, "- %s\n", s)
+	}
+}
+
+func recurringTable(w io.Writer, income, bills []RecurringSeries) {
+	section := func(title string, series []RecurringSeries) {
+		fmt.Fprintf(w, "%s (%d)\n", title, len(series))
+		if len(series) == 0 {
+			fmt.Fprintln(w, "none detected")
+			return
+		}
+		fmt.Fprintln(w, "COUNTERPARTY\tCATEGORY\tCADENCE\tAVG\tCOUNT\tLAST\tNEXT")
+		for _, s := range series {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%.2f\t%d\t%s\t%s\n",
+				s.Counterparty, s.Category, s.Cadence, s.AvgAmount, s.Count,
+				s.Last.Format("2006-01-02"), s.Last.Add(time.Duration(s.IntervalDays*24)*time.Hour).Format("2006-01-02"))
+		}
+	}
+	section("INCOME", income)
+	fmt.Fprintln(w)
+	section("BILLS AND SUBSCRIPTIONS", bills)
+}
+
+func counterpartiesTable(w io.Writer, report CounterpartyReport) {
+	fmt.Fprintf(w, "COUNTERPARTIES (%d days, %d counterparties)\n", report.PeriodDays, report.Counterparties)
+	ranking := func(title string, stats []CounterpartyStats) {
+		fmt.Fprintf(w, "\n%s\n", title)
+		fmt.Fprintln(w, "COUNTERPARTY\tSENT\tRECEIVED\tNET\tCOUNT\tLAST")
+		for _, s := range stats {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", s.Counterparty,
+				formatMoney(s.Sent, s.Currency), formatMoney(s.Received, s.Currency), formatMoney(s.Net, s.Currency),
+				s.count(), s.Last.Format("2006-01-02"))
+		}
+	}
+	ranking("TOP BY VOLUME", report.ByVolume)
+	ranking("TOP BY FREQUENCY", report.ByFrequency)
+	if len(report.P2PNetFlow) > 0 {
+		ranking("NET FLOW WITH @TAGS", report.P2PNetFlow)
+	}
+	if len(report.Reciprocal) > 0 {
+		fmt.Fprintln(w, "\nRECIPROCAL")
+		for _, r := range report.Reciprocal {
+			fmt.Fprintf(w, "- %s\n", r.Note)
+		}
+	}
+	if len(report.Changes) > 0 {
+		fmt.Fprintln(w, "\nVOLUME CHANGES")
+		for _, c := range report.Changes {
+			fmt.Fprintf(w, "- %s\n", c.Note)
+		}
+	}
+	if report.Note != "" {
+		fmt.Fprintf(w, "\n%s\n", report.Note)
+	}
+}
+
+func heatmapTable(w io.Writer, h *SpendingHeatmap) {
+	bar := func(share float64) string { return strings.Repeat("#", int(math.Round(share/2))) }
+	fmt.Fprintf(w, "\nBY WEEKDAY (%s, %s)\n", h.Currency, h.Timezone)
+	for _, c := range h.Weekday {
+		fmt.Fprintf(w, "%s\t%s\t%.1f%%\t%s\n", c.Label, formatMoney(c.Amount, h.Currency), c.Share, bar(c.Share))
+	}
+	fmt.Fprintln(w, "\nBY HOUR")
+	for _, c := range h.Hour {
+		if c.Count > 0 {
+			fmt.Fprintf(w, "%s\t%s\t%.1f%%\t%s\n", c.Label, formatMoney(c.Amount, h.Currency), c.Share, bar(c.Share))
+		}
+	}
+	if len(h.Insights) > 0 {
+		fmt.Fprintln(w, "\nPATTERNS")
+		for _, in := range h.Insights {
+			fmt.Fprintf(w, "- %s\n", in)
+		}
+	}
+}
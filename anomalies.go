@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+	"github.com/becomeliminal/nim-go-sdk/tools"
+)
+
+// ============================================================================
+// UNUSUAL ACTIVITY DETECTION
+// ============================================================================
+// Scores each send against the user's own history rather than fixed limits:
+//
+//   - amount: far above what the user usually pays in that category (or at
+//     all, for categories with little history)
+//   - new counterparty: the first payment to someone, more so when it is big
+//   - time of day: an hour the user hardly ever pays at
+//   - velocity: a burst of payments within an hour, well past the usual
+//
+// Signals combine into a 0-1 score (any one strong signal is enough; several
+// weak ones add up) with a severity and a reason in plain words.
+// detect_anomalies reports them on demand and the background monitor pushes
+// new ones into the insight queue. Hours are read in the timestamp's own
+// offset, which is the user's local time when Liminal sends it.
+
+// Anomaly is a send that stands out from the user's history.
+type Anomaly struct {
+	TransactionID string    `json:"transaction_id"`
+	Timestamp     time.Time `json:"timestamp"`
+	Counterparty  string    `json:"counterparty"`
+	Category      string    `json:"category,omitempty"`
+	Amount        float64   `json:"amount"`
+	Currency      string    `json:"currency"`
+	Score         float64   `json:"score"`    // 0-1
+	Severity      string    `json:"severity"` // low, medium or high
+	Signals       []string  `json:"signals"`  // amount, new_counterparty, time_of_day, velocity
+	Reason        string    `json:"reason"`
+}
+
+// anomalySeverities orders the severity levels.
+var anomalySeverities = map[string]int{"low": 1, "medium": 2, "high": 3}
+
+// anomalySeverity names a score.
+func anomalySeverity(score float64) string {
+	switch {
+	case score >= 0.7:
+		return "high"
+	case score >= 0.4:
+		return "medium"
+	}
+	return "low"
+}
+
+const (
+	// minAnomalyBaseline is the history needed before anything is called
+	// unusual; with less, everything looks new.
+	minAnomalyBaseline = 20
+	// minCategoryBaseline is the payments a category needs for its own
+	// amount distribution; smaller ones are compared with all payments.
+	minCategoryBaseline = 5
+	// anomalyMinScore drops sends too ordinary to mention: a small first
+	// payment to a new shop on its own is just life.
+	anomalyMinScore = 0.2
+)
+
+// anomalyBaseline is what "usual" looks like for one user.
+type anomalyBaseline struct {
+	amounts        []float64            // all sends, sorted
+	byCategory     map[string][]float64 // sorted
+	counterparties map[string]bool
+	hours          [24]int
+	sends          int
+	hourlyBurst    int // payments in a busy hour (95th percentile)
+}
+
+// newAnomalyBaseline learns from sends, leaving out savings transfers.
+func newAnomalyBaseline(history []map[string]interface{}) *anomalyBaseline {
+	b := &anomalyBaseline{byCategory: make(map[string][]float64), counterparties: make(map[string]bool)}
+	perHour := make(map[time.Time]int)
+	for _, tx := range history {
+		if txString(tx, "type") != "send" || isSavingsTransfer(tx) {
+			continue
+		}
+		amount := txAmount(tx)
+		b.sends++
+		b.amounts = append(b.amounts, amount)
+		category := firstNonEmpty(txString(tx, "category"), "uncategorized")
+		b.byCategory[category] = append(b.byCategory[category], amount)
+		if c := normalizeCounterparty(txString(tx, "counterparty")); c != "" {
+			b.counterparties[c] = true
+		}
+		if ts, ok := txTime(tx); ok {
+			b.hours[ts.Hour()]++
+			perHour[ts.Truncate(time.Hour)]++
+		}
+	}
+	sort.Float64s(b.amounts)
+	for _, amounts := range b.byCategory {
+		sort.Float64s(amounts)
+	}
+	counts := make([]float64, 0, len(perHour))
+	for _, n := range perHour {
+		counts = append(counts, float64(n))
+	}
+	sort.Float64s(counts)
+	b.hourlyBurst = int(math.Max(1, percentile(counts, 0.95)))
+	return b
+}
+
+// percentile reads the p-th percentile (0-1) of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// score rates one send. sendTimes is every send's time, for the velocity
+// check; it may include tx itself.
+func (b *anomalyBaseline) score(tx map[string]interface{}, sendTimes []time.Time) *Anomaly {
+	if txString(tx, "type") != "send" || isSavingsTransfer(tx) {
+		return nil
+	}
+	amount := txAmount(tx)
+	currency := strings.ToUpper(firstNonEmpty(txString(tx, "currency"), "USD"))
+	counterparty := txString(tx, "counterparty")
+	category := txString(tx, "category")
+	ts, hasTime := txTime(tx)
+
+	var (
+		signals []string
+		reasons []string
+		scores  []float64
+	)
+	flag := func(signal string, score float64, reason string) {
+		signals = append(signals, signal)
+		scores = append(scores, score)
+		reasons = append(reasons, reason)
+	}
+
+	// Amount against the category's history, or all payments.
+	amounts, label := b.byCategory[firstNonEmpty(category, "uncategorized")], category+" payment"
+	if len(amounts) < minCategoryBaseline {
+		amounts, label = b.amounts, "payment"
+	}
+	typical := calculateMedian(amounts)
+	if typical > 0 && amount > percentile(amounts, 0.95) {
+		if ratio := amount / typical; ratio >= 3 {
+			flag("amount", math.Min(0.85, 0.3+0.1*(ratio-3)),
+				fmt.Sprintf("%.1fx your typical %s (%s)", ratio, label, formatMoney(typical, currency)))
+		}
+	}
+
+	// First payment to someone new.
+	if name := normalizeCounterparty(counterparty); name != "" && !b.counterparties[name] {
+		if amount >= percentile(b.amounts, 0.9) {
+			flag("new_counterparty", 0.5, fmt.Sprintf("first payment to %s, and a large one", counterparty))
+		} else {
+			flag("new_counterparty", 0.15, fmt.Sprintf("first payment to %s", counterparty))
+		}
+	}
+
+	if hasTime {
+		// An hour (give or take one) the user hardly pays at.
+		hour := ts.Hour()
+		near := b.hours[hour] + b.hours[(hour+23)%24] + b.hours[(hour+1)%24]
+		if float64(near) < 0.02*float64(b.sends) {
+			score := 0.2
+			if hour < 6 {
+				score = 0.35 // the small hours more so
+			}
+			flag("time_of_day", score, fmt.Sprintf("sent at %s, when you rarely pay", ts.Format("15:04")))
+		}
+
+		// A burst of payments in the hour up to this one.
+		from := ts.Add(-time.Hour)
+		burst := 0
+		for _, t := range sendTimes {
+			if t.After(from) && !t.After(ts) {
+				burst++
+			}
+		}
+		if burst >= 4 && burst >= 3*b.hourlyBurst {
+			flag("velocity", math.Min(0.8, 0.3+0.1*float64(burst-4)),
+				fmt.Sprintf("%d payments within an hour - you usually make at most %d", burst, b.hourlyBurst))
+		}
+	}
+
+	if len(scores) == 0 {
+		return nil
+	}
+	// Noisy-or: each signal is an independent reason to look twice.
+	rest := 1.0
+	for _, s := range scores {
+		rest *= 1 - s
+	}
+	score := math.Round((1-rest)*100) / 100
+	if score < anomalyMinScore {
+		return nil
+	}
+	return &Anomaly{
+		TransactionID: txID(tx),
+		Timestamp:     ts,
+		Counterparty:  counterparty,
+		Category:      category,
+		Amount:        amount,
+		Currency:      currency,
+		Score:         score,
+		Severity:      anomalySeverity(score),
+		Signals:       signals,
+		Reason:        strings.Join(reasons, "; "),
+	}
+}
+
+// detectAnomalies scores candidates against a baseline learned from
+// history, which should not include them. It returns nil when the history
+// is too short to tell what's usual.
+func detectAnomalies(candidates, history []map[string]interface{}) []Anomaly {
+	baseline := newAnomalyBaseline(history)
+	if baseline.sends < minAnomalyBaseline {
+		return nil
+	}
+	var sendTimes []time.Time
+	for _, set := range [][]map[string]interface{}{history, candidates} {
+		for _, tx := range set {
+			if ts, ok := txTime(tx); ok && txString(tx, "type") == "send" && !isSavingsTransfer(tx) {
+				sendTimes = append(sendTimes, ts)
+			}
+		}
+	}
+
+	out := []Anomaly{}
+	for _, tx := range candidates {
+		if a := baseline.score(tx, sendTimes); a != nil {
+			out = append(out, *a)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].Timestamp.After(out[j].Timestamp)
+	})
+	return out
+}
+
+// anomalyInsights turns the unusual new transactions into insights at or
+// above minSeverity ("off" for none).
+func anomalyInsights(newTx, transactions []map[string]interface{}, minSeverity string) []Insight {
+	if minSeverity == "off" {
+		return nil
+	}
+	fresh := make(map[string]bool, len(newTx))
+	for _, tx := range newTx {
+		fresh[txID(tx)] = true
+	}
+	var history []map[string]interface{}
+	for _, tx := range transactions {
+		if !fresh[txID(tx)] {
+			history = append(history, tx)
+		}
+	}
+
+	var out []Insight
+	for _, a := range detectAnomalies(newTx, history) {
+		if anomalySeverities[a.Severity] < anomalySeverities[minSeverity] {
+			continue
+		}
+		severity := "info"
+		if a.Severity == "high" {
+			severity = "warning"
+		}
+		out = append(out, Insight{
+			Type:     "unusual_activity",
+			Severity: severity,
+			Title:    "Unusual payment",
+			Message:  fmt.Sprintf("%s to %s looks unusual: %s.", formatMoney(a.Amount, a.Currency), a.Counterparty, a.Reason),
+			Data: map[string]interface{}{
+				"transaction_id": a.TransactionID,
+				"amount":         a.Amount,
+				"counterparty":   a.Counterparty,
+				"score":          a.Score,
+				"severity":       a.Severity,
+				"signals":        a.Signals,
+			},
+			DedupKey: "anomaly:" + a.TransactionID,
+		})
+	}
+	return out
+}
+
+// ============================================================================
+// CUSTOM TOOL: DETECT ANOMALIES
+// ============================================================================
+
+func createDetectAnomaliesTool(liminalExecutor core.ToolExecutor, statements *statementStore) core.Tool {
+	return tools.New("detect_anomalies").
+		Description("Find unusual activity in the user's recent payments, judged against their own history: amounts far above their usual for the category, first payments to new counterparties, payments at hours they rarely pay, and bursts of payments. Each one has a 0-1 score, a severity (low, medium, high) and the reason in plain words.").
+		Schema(tools.ObjectSchema(map[string]interface{}{
+			"days":               tools.IntegerProperty("Check payments from the last N days; older ones form the baseline (default: 7)"),
+			"min_severity":       tools.StringProperty("low, medium or high (default: low)"),
+			"include_statements": tools.BooleanProperty("Include bank statements the user imported (default: true)"),
+			"refresh":            tools.BooleanProperty("Fetch the latest transactions from Liminal instead of the local cache (default: false)"),
+			"use_csv":            tools.BooleanProperty("Use local CSV file instead of API (for testing, default: false)"),
+		})).
+		Handler(func(ctx context.Context, toolParams *core.ToolParams) (*core.ToolResult, error) {
+			var params struct {
+				Days              int    `json:"days"`
+				MinSeverity       string `json:"min_severity"`
+				IncludeStatements *bool  `json:"include_statements"`
+				Refresh           bool   `json:"refresh"`
+				UseCSV            bool   `json:"use_csv"`
+			}
+			if err := json.Unmarshal(toolParams.Input, &params); err != nil {
+				return &core.ToolResult{
+					Success: false,
+					Error:   fmt.Sprintf("invalid input: %v", err),
+				}, nil
+			}
+			if params.Days == 0 {
+				params.Days = 7
+			}
+			if params.Days < 0 || params.Days > 90 {
+				return &core.ToolResult{Success: false, Error: "days must be between 1 and 90"}, nil
+			}
+			params.MinSeverity = strings.ToLower(firstNonEmpty(params.MinSeverity, "low"))
+			if anomalySeverities[params.MinSeverity] == 0 {
+				return &core.ToolResult{Success: false, Error: "min_severity must be low, medium or high"}, nil
+			}
+			if params.Refresh {
+				ctx = withFreshTransactions(ctx)
+			}
+
+			transactions, err := loadToolTransactions(ctx, liminalExecutor, toolParams, params.UseCSV)
+			if err != nil {
+				return &core.ToolResult{Success: false, Error: err.Error()}, nil
+			}
+			statementCount := 0
+			if params.IncludeStatements == nil || *params.IncludeStatements {
+				transactions, statementCount = statements.withStatements(toolParams.UserID, transactions, time.Time{})
+			}
+			now := time.Now()
+			if params.UseCSV {
+				now = newestTransaction(transactions, now)
+			}
+
+			since := now.AddDate(0, 0, -params.Days)
+			var recent, history []map[string]interface{}
+			for _, tx := range transactions {
+				if ts, ok := txTime(tx); ok && ts.After(since) {
+					recent = append(recent, tx)
+				} else {
+					history = append(history, tx)
+				}
+			}
+
+			result := map[string]interface{}{
+				"period_days": params.Days,
+				"checked":     len(recent),
+				"baseline":    len(history),
+				"data_source": map[string]interface{}{"csv": params.UseCSV, "api": !params.UseCSV, "statements": statementCount},
+			}
+			found := detectAnomalies(recent, history)
+			if found == nil {
+				result["anomalies"] = []Anomaly{}
+				result["note"] = fmt.Sprintf("Not enough history before the last %d days to know what's usual (need %d payments)", params.Days, minAnomalyBaseline)
+				return &core.ToolResult{Success: true, Data: result}, nil
+			}
+			anomalies := []Anomaly{}
+			for _, a := range found {
+				if anomalySeverities[a.Severity] >= anomalySeverities[params.MinSeverity] {
+					anomalies = append(anomalies, a)
+				}
+			}
+			result["anomalies"] = anomalies
+			switch {
+			case len(found) == 0:
+				result["note"] = "Nothing unusual - recent payments look like the user's normal activity"
+			case len(anomalies) == 0:
+				result["note"] = fmt.Sprintf("Nothing %s severity; %d lower ones", params.MinSeverity, len(found))
+			}
+			return &core.ToolResult{Success: true, Data: result}, nil
+		}).
+		Build()
+}
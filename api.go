@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+)
+
+// ============================================================================
+// COMPANION HTTP API
+// ============================================================================
+// The SDK server owns the WebSocket endpoint. Everything else the frontend
+// needs (authorizing background access, uploading bank statements, ...) is
+// served here on API_PORT.
+
+// maxStatementUpload bounds the size of an uploaded statement file.
+const maxStatementUpload = 10 << 20
+
+func newAPIHandler(liminalExecutor core.ToolExecutor, creds *credentialStore, statements *statementStore, exports *exportStore, notify *notifier) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+
+	// POST stores the caller's token pair for background access; DELETE
+	// revokes it. The caller authenticates with the access token itself,
+	// which Liminal verifies before either is allowed.
+	mux.HandleFunc("/v1/credentials", func(w http.ResponseWriter, r *http.Request) {
+		if creds == nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "background access is not enabled on this server"})
+			return
+		}
+		userID, ok := authenticateUser(w, r, liminalExecutor)
+		if !ok {
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPost:
+			var body struct {
+				RefreshToken string `json:"refresh_token"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+				return
+			}
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			_, expiresAt, _ := jwtSubject(token)
+			if err := creds.put(Credentials{UserID: userID, AccessToken: token, RefreshToken: body.RefreshToken, ExpiresAt: expiresAt}); err != nil {
+				log.Printf("⚠️  Failed to store credentials for %s: %v", userID, err)
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to store credentials"})
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"user_id": userID, "stored_at": time.Now().Format(time.RFC3339)})
+		case http.MethodDelete:
+			if err := creds.remove(userID); err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to remove credentials"})
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "POST, DELETE")
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		}
+	})
+
+	// POST uploads a statement file in any format importStatement reads as
+	// the raw body, ?name= giving its file name and ?preset= a CSV preset;
+	// GET lists the imported files; DELETE ?id= removes one.
+	mux.HandleFunc("/v1/statements", func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := authenticateUser(w, r, liminalExecutor)
+		if !ok {
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPost:
+			data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxStatementUpload))
+			if err != nil {
+				writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": fmt.Sprintf("statements are limited to %d MB", maxStatementUpload>>20)})
+				return
+			}
+			if len(data) == 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "the request body should be the statement file"})
+				return
+			}
+			name := statementName(r.URL.Query().Get("name"))
+			result, err := importStatement(name, data, r.URL.Query().Get("preset"))
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			if len(result.Transactions) == 0 {
+				writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{"error": "no transactions could be read from the file", "import": result})
+				return
+			}
+			file, err := statements.add(userID, name, data, result)
+			if err != nil {
+				log.Printf("⚠️  Failed to store statement for %s: %v", userID, err)
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			log.Printf("📄 Imported %s for %s: %d transactions (%d duplicates, %d rows skipped)", file.Name, userID, file.Added, file.Duplicates, file.Errors)
+			writeJSON(w, http.StatusOK, map[string]interface{}{"statement": file, "import": result})
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, map[string]interface{}{"statements": statements.files(userID)})
+		case http.MethodDelete:
+			found, err := statements.remove(userID, r.URL.Query().Get("id"))
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to remove statement"})
+				return
+			}
+			if !found {
+				writeJSON(w, http.StatusNotFound, map[string]string{"error": "no such statement"})
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		}
+	})
+
+	// GET returns the secret that signs the caller's webhooks, so they can
+	// check X-NeuraPay-Signature; POST replaces it. It is served here rather
+	// than by configure_notifications so it never passes through the model.
+	mux.HandleFunc("/v1/notifications/webhook-secret", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			w.Header().Set("Allow", "GET, POST")
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		userID, ok := authenticateUser(w, r, liminalExecutor)
+		if !ok {
+			return
+		}
+		secret := notify.webhookSecret(userID, r.Method == http.MethodPost)
+		if secret == "" {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "no webhook is configured; set one with configure_notifications first"})
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusOK, map[string]string{"webhook_secret": secret})
+	})
+
+	// GET downloads an export_transactions file. The ID in the link is the
+	// credential, so the link opens in a browser without a token.
+	mux.HandleFunc("/v1/exports/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		file, path, ok := exports.lookup(strings.TrimPrefix(r.URL.Path, "/v1/exports/"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "no such export"})
+			return
+		}
+		if time.Now().After(file.ExpiresAt) {
+			writeJSON(w, http.StatusGone, map[string]string{"error": "this download link has expired; ask for a new export"})
+			return
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			log.Printf("⚠️  Export %s missing: %v", file.ID, err)
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "no such export"})
+			return
+		}
+		defer f.Close()
+		w.Header().Set("Content-Type", exportContentTypes[file.Format])
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Name}))
+		w.Header().Set("Cache-Control", "private, no-store")
+		http.ServeContent(w, r, file.Name, file.CreatedAt, f)
+	})
+
+	return mux
+}
+
+// authenticateUser checks the bearer token with Liminal and returns whose it
+// is, writing the error response when it isn't valid.
+func authenticateUser(w http.ResponseWriter, r *http.Request, liminalExecutor core.ToolExecutor) (string, bool) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	userID, _, err := jwtSubject(token)
+	if err == nil && userID != "" {
+		_, err = callLiminal(withBearerToken(r.Context(), token), liminalExecutor, userID, "", "get_profile", map[string]interface{}{})
+	}
+	if err != nil || userID == "" {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "a valid bearer token is required"})
+		return "", false
+	}
+	return userID, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,534 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+	"github.com/becomeliminal/nim-go-sdk/tools"
+)
+
+// ============================================================================
+// CUSTOM TOOL: PAYDAY AUTO-SAVE ("PAY YOURSELF FIRST")
+// ============================================================================
+// Implements the Reward Seeker strategy "Auto-save 20% BEFORE you see your
+// paycheck". Incoming receives are matched against recurring income streams
+// (same source, similar amount, regular cadence). When a paycheck lands, each
+// enabled rule either proposes a savings transfer for the user to confirm or,
+// if the user explicitly opted in to execution, deposits it right away.
+//
+// Rules only apply to paychecks that land after the rule was created.
+
+// IncomeStream is a recurring source of income detected from receives.
+type IncomeStream struct {
+	Source       string    `json:"source"`
+	AvgAmount    float64   `json:"avg_amount"`
+	Cadence      string    `json:"cadence"`
+	IntervalDays float64   `json:"interval_days"`
+	Count        int       `json:"count"`
+	LastPaid     time.Time `json:"last_paid"`
+}
+
+// detectIncomeStreams finds receives that repeat from the same source with
+// similar amounts on a pay-like cadence.
+func detectIncomeStreams(transactions []map[string]interface{}) []IncomeStream {
+	var streams []IncomeStream
+	for _, series := range detectRecurring(transactions, "receive") {
+		streams = append(streams, IncomeStream{
+			Source:       series.Counterparty,
+			AvgAmount:    series.AvgAmount,
+			Cadence:      series.Cadence,
+			IntervalDays: series.IntervalDays,
+			Count:        series.Count,
+			LastPaid:     series.Last,
+		})
+	}
+	return streams
+}
+
+// matchIncomeStream returns the stream a receive belongs to, if any.
+func matchIncomeStream(tx map[string]interface{}, streams []IncomeStream) *IncomeStream {
+	if txString(tx, "type") != "receive" {
+		return nil
+	}
+	source := normalizeCounterparty(txString(tx, "counterparty"))
+	amount := txAmount(tx)
+	for i := range streams {
+		s := &streams[i]
+		if s.Source == source && s.AvgAmount > 0 && math.Abs(amount-s.AvgAmount)/s.AvgAmount <= recurringAmountTolerance {
+			return s
+		}
+	}
+	return nil
+}
+
+// AutosaveRule describes how much to save when a paycheck lands.
+type AutosaveRule struct {
+	ID             string    `json:"id"`
+	Source         string    `json:"source,omitempty"` // empty = any detected paycheck
+	Percent        float64   `json:"percent,omitempty"`
+	FixedAmount    float64   `json:"fixed_amount,omitempty"`
+	MaxPerTransfer float64   `json:"max_per_transfer,omitempty"`
+	MaxPerMonth    float64   `json:"max_per_month,omitempty"`
+	Mode           string    `json:"mode"` // "propose" or "execute"
+	Currency       string    `json:"currency"`
+	Enabled        bool      `json:"enabled"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// AutosaveTransfer is a transfer a rule produced for a paycheck.
+type AutosaveTransfer struct {
+	ID         string    `json:"id"`
+	RuleID     string    `json:"rule_id"`
+	PaycheckID string    `json:"paycheck_id"`
+	Source     string    `json:"source"`
+	Paycheck   float64   `json:"paycheck"`
+	Amount     float64   `json:"amount"`
+	Currency   string    `json:"currency"`
+	Status     string    `json:"status"` // proposed, pending, executed, confirmed, declined, failed
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// AutosaveAccount is the per-user auto-save state.
+type AutosaveAccount struct {
+	Rules     []AutosaveRule     `json:"rules"`
+	Transfers []AutosaveTransfer `json:"transfers"`
+	NextID    int                `json:"next_id"`
+}
+
+// amountFor computes the transfer for a paycheck, applying the rule's caps.
+func (r AutosaveRule) amountFor(paycheck, savedThisMonth float64) float64 {
+	amount := r.FixedAmount
+	if r.Percent > 0 {
+		amount = paycheck * r.Percent / 100
+	}
+	if r.MaxPerTransfer > 0 {
+		amount = math.Min(amount, r.MaxPerTransfer)
+	}
+	if r.MaxPerMonth > 0 {
+		amount = math.Min(amount, r.MaxPerMonth-savedThisMonth)
+	}
+	amount = math.Min(amount, paycheck)
+	return math.Max(math.Floor(amount*100)/100, 0)
+}
+
+// autosaveService owns payday auto-save state for all users.
+type autosaveService struct {
+	liminalExecutor core.ToolExecutor
+	store           *jsonStore
+
+	mu         sync.Mutex
+	accounts   map[string]*AutosaveAccount
+	depositing map[string]bool // users with automatic deposits in flight
+}
+
+func newAutosaveService(liminalExecutor core.ToolExecutor) *autosaveService {
+	s := &autosaveService{
+		liminalExecutor: liminalExecutor,
+		store:           newJSONStore("autosave.json"),
+		accounts:        make(map[string]*AutosaveAccount),
+		depositing:      make(map[string]bool),
+	}
+	if err := s.store.load(&s.accounts); err != nil {
+		log.Printf("⚠️  Auto-save state not loaded: %v", err)
+	}
+	return s
+}
+
+func (s *autosaveService) account(userID string) *AutosaveAccount {
+	acct, ok := s.accounts[userID]
+	if !ok {
+		acct = &AutosaveAccount{}
+		s.accounts[userID] = acct
+	}
+	return acct
+}
+
+func (s *autosaveService) persist() {
+	if err := s.store.save(s.accounts); err != nil {
+		log.Printf("⚠️  Failed to save auto-save state: %v", err)
+	}
+}
+
+// savedThisMonth sums the non-declined transfers a rule made this month.
+func (acct *AutosaveAccount) savedThisMonth(ruleID string, now time.Time) float64 {
+	total := 0.0
+	for _, t := range acct.Transfers {
+		if t.RuleID != ruleID || t.Status == "declined" || t.Status == "failed" {
+			continue
+		}
+		if t.CreatedAt.Year() == now.Year() && t.CreatedAt.Month() == now.Month() {
+			total += t.Amount
+		}
+	}
+	return total
+}
+
+// handled reports whether a rule already produced a transfer for a
+// paycheck. Failed transfers don't count, so they are retried on the next
+// run, and neither do pending ones, which only outlive their run if the
+// server stopped mid-deposit.
+func (acct *AutosaveAccount) handled(ruleID, paycheckID string) bool {
+	for _, t := range acct.Transfers {
+		if t.RuleID == ruleID && t.PaycheckID == paycheckID && t.Status != "failed" && t.Status != "pending" {
+			return true
+		}
+	}
+	return false
+}
+
+// recordTransfer adds a transfer, replacing an earlier failed or pending
+// attempt for the same rule and paycheck.
+func (acct *AutosaveAccount) recordTransfer(transfer AutosaveTransfer) {
+	for i, t := range acct.Transfers {
+		if t.RuleID == transfer.RuleID && t.PaycheckID == transfer.PaycheckID && (t.Status == "failed" || t.Status == "pending") {
+			acct.Transfers[i] = transfer
+			return
+		}
+	}
+	acct.Transfers = append(acct.Transfers, transfer)
+}
+
+// process looks for new paychecks and applies the user's rules. It returns the
+// income streams it detected and the transfers created during this run.
+func (s *autosaveService) process(ctx context.Context, userID, requestID string) ([]IncomeStream, []AutosaveTransfer, error) {
+	transactions, err := fetchTransactions(ctx, s.liminalExecutor, userID, requestID, 100)
+	if err != nil {
+		return nil, nil, err
+	}
+	streams := detectIncomeStreams(transactions)
+
+	s.mu.Lock()
+	if s.depositing[userID] {
+		s.mu.Unlock()
+		return streams, nil, nil // the run already in flight covers these paychecks
+	}
+	acct := s.account(userID)
+
+	var (
+		created  []AutosaveTransfer
+		deposits []int // indexes into created of transfers to execute
+	)
+	now := time.Now()
+	for _, tx := range transactions {
+		stream := matchIncomeStream(tx, streams)
+		if stream == nil {
+			continue
+		}
+		landed, _ := txTime(tx)
+		paycheckID := txID(tx)
+
+		for _, rule := range acct.Rules {
+			if !rule.Enabled || landed.Before(rule.CreatedAt) || acct.handled(rule.ID, paycheckID) {
+				continue
+			}
+			if rule.Source != "" && !strings.EqualFold(rule.Source, stream.Source) {
+				continue
+			}
+			// The deposit is in the rule's currency, so a paycheck in another
+			// currency would be saved at the wrong value.
+			if currency := txString(tx, "currency"); currency != "" && !strings.EqualFold(currency, rule.Currency) {
+				continue
+			}
+
+			amount := rule.amountFor(txAmount(tx), acct.savedThisMonth(rule.ID, now))
+			if amount <= 0 {
+				continue
+			}
+
+			acct.NextID++
+			transfer := AutosaveTransfer{
+				ID:         fmt.Sprintf("as-%d", acct.NextID),
+				RuleID:     rule.ID,
+				PaycheckID: paycheckID,
+				Source:     stream.Source,
+				Paycheck:   txAmount(tx),
+				Amount:     amount,
+				Currency:   rule.Currency,
+				Status:     "proposed",
+				CreatedAt:  now,
+			}
+			if rule.Mode == "execute" {
+				transfer.Status = "pending"
+				deposits = append(deposits, len(created))
+			}
+			acct.recordTransfer(transfer)
+			created = append(created, transfer)
+		}
+	}
+	if len(deposits) == 0 {
+		if len(created) > 0 {
+			s.persist()
+		}
+		s.mu.Unlock()
+		return streams, created, nil
+	}
+	s.depositing[userID] = true
+	s.mu.Unlock()
+
+	// Deposits are network calls; other users and the settings tools don't
+	// wait on them.
+	for _, i := range deposits {
+		t := &created[i]
+		if err := depositSavings(ctx, s.liminalExecutor, userID, requestID, t.Amount, t.Currency); err != nil {
+			t.Status = "failed"
+			t.Error = err.Error()
+		} else {
+			t.Status = "executed"
+			log.Printf("💰 Payday auto-save of %.2f %s for user %s", t.Amount, t.Currency, userID)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.depositing, userID)
+	acct = s.account(userID)
+	for _, i := range deposits {
+		for j := range acct.Transfers {
+			if acct.Transfers[j].ID == created[i].ID {
+				acct.Transfers[j] = created[i]
+			}
+		}
+	}
+	s.persist()
+	return streams, created, nil
+}
+
+func createAutosaveRuleTool(autosave *autosaveService) core.Tool {
+	return tools.New("configure_payday_autosave").
+		Description("Manage \"pay yourself first\" rules that save part of each paycheck as soon as it lands. Actions: list, add, update, remove. Adding or enabling a rule requires opt_in=true after the user explicitly agrees.").
+		Schema(tools.ObjectSchema(map[string]interface{}{
+			"action":           tools.StringProperty("One of: list, add, update, remove (default: list)"),
+			"rule_id":          tools.StringProperty("Rule to update or remove"),
+			"source":           tools.StringProperty("Only apply to paychecks from this counterparty (default: any detected paycheck)"),
+			"percent":          tools.NumberProperty("Percentage of the paycheck to save, e.g. 20"),
+			"fixed_amount":     tools.NumberProperty("Fixed amount to save per paycheck (used when percent is not set)"),
+			"max_per_transfer": tools.NumberProperty("Cap for a single transfer"),
+			"max_per_month":    tools.NumberProperty("Cap for the total saved by this rule per calendar month"),
+			"mode":             tools.StringProperty("propose (ask each time, default) or execute (deposit automatically)"),
+			"currency":         tools.StringProperty("Currency for the savings deposit (default: USD)"),
+			"enabled":          tools.BooleanProperty("Enable or pause the rule"),
+			"opt_in":           tools.BooleanProperty("Must be true when adding or enabling a rule - confirms the user explicitly agreed"),
+		})).
+		Handler(func(ctx context.Context, toolParams *core.ToolParams) (*core.ToolResult, error) {
+			var params struct {
+				Action         string   `json:"action"`
+				RuleID         string   `json:"rule_id"`
+				Source         *string  `json:"source"`
+				Percent        *float64 `json:"percent"`
+				FixedAmount    *float64 `json:"fixed_amount"`
+				MaxPerTransfer *float64 `json:"max_per_transfer"`
+				MaxPerMonth    *float64 `json:"max_per_month"`
+				Mode           *string  `json:"mode"`
+				Currency       *string  `json:"currency"`
+				Enabled        *bool    `json:"enabled"`
+				OptIn          bool     `json:"opt_in"`
+			}
+			if err := json.Unmarshal(toolParams.Input, &params); err != nil {
+				return &core.ToolResult{
+					Success: false,
+					Error:   fmt.Sprintf("invalid input: %v", err),
+				}, nil
+			}
+			if params.Action == "" {
+				params.Action = "list"
+			}
+
+			autosave.mu.Lock()
+			defer autosave.mu.Unlock()
+			acct := autosave.account(toolParams.UserID)
+
+			findRule := func() int {
+				for i, r := range acct.Rules {
+					if r.ID == params.RuleID {
+						return i
+					}
+				}
+				return -1
+			}
+
+			apply := func(rule *AutosaveRule, wasEnabled bool) string {
+				if params.Source != nil {
+					rule.Source = strings.ToLower(strings.TrimSpace(*params.Source))
+				}
+				if params.Percent != nil {
+					rule.Percent = *params.Percent
+				}
+				if params.FixedAmount != nil {
+					rule.FixedAmount = *params.FixedAmount
+				}
+				if params.MaxPerTransfer != nil {
+					rule.MaxPerTransfer = *params.MaxPerTransfer
+				}
+				if params.MaxPerMonth != nil {
+					rule.MaxPerMonth = *params.MaxPerMonth
+				}
+				if params.Mode != nil {
+					rule.Mode = *params.Mode
+				}
+				if params.Currency != nil && *params.Currency != "" {
+					rule.Currency = *params.Currency
+				}
+				if params.Enabled != nil {
+					rule.Enabled = *params.Enabled
+				}
+
+				switch {
+				case rule.Mode != "propose" && rule.Mode != "execute":
+					return "mode must be propose or execute"
+				case rule.Percent <= 0 && rule.FixedAmount <= 0:
+					return "set either percent or fixed_amount"
+				case rule.Percent > 100:
+					return "percent cannot exceed 100"
+				case rule.MaxPerTransfer < 0 || rule.MaxPerMonth < 0:
+					return "caps cannot be negative"
+				case rule.Enabled && !wasEnabled && !params.OptIn:
+					return "the user must explicitly opt in (opt_in=true) before a rule is enabled"
+				case rule.Mode == "execute" && params.Mode != nil && !params.OptIn:
+					return "the user must explicitly opt in (opt_in=true) to automatic deposits"
+				}
+				return ""
+			}
+
+			switch params.Action {
+			case "list":
+			case "add":
+				rule := AutosaveRule{Mode: "propose", Currency: "USD", Enabled: true, CreatedAt: time.Now()}
+				if msg := apply(&rule, false); msg != "" {
+					return &core.ToolResult{Success: false, Error: msg}, nil
+				}
+				acct.NextID++
+				rule.ID = fmt.Sprintf("rule-%d", acct.NextID)
+				acct.Rules = append(acct.Rules, rule)
+			case "update":
+				i := findRule()
+				if i < 0 {
+					return &core.ToolResult{Success: false, Error: fmt.Sprintf("rule %q not found", params.RuleID)}, nil
+				}
+				rule := acct.Rules[i]
+				wasEnabled := rule.Enabled
+				if msg := apply(&rule, wasEnabled); msg != "" {
+					return &core.ToolResult{Success: false, Error: msg}, nil
+				}
+				if rule.Enabled && !wasEnabled {
+					// Re-enabled rules don't reach back to paychecks that landed while paused.
+					rule.CreatedAt = time.Now()
+				}
+				acct.Rules[i] = rule
+			case "remove":
+				i := findRule()
+				if i < 0 {
+					return &core.ToolResult{Success: false, Error: fmt.Sprintf("rule %q not found", params.RuleID)}, nil
+				}
+				acct.Rules = append(acct.Rules[:i], acct.Rules[i+1:]...)
+			default:
+				return &core.ToolResult{
+					Success: false,
+					Error:   fmt.Sprintf("unknown action %q", params.Action),
+				}, nil
+			}
+
+			if params.Action != "list" {
+				autosave.persist()
+			}
+
+			return &core.ToolResult{
+				Success: true,
+				Data: map[string]interface{}{
+					"rules": acct.Rules,
+				},
+			}, nil
+		}).
+		Build()
+}
+
+func createAutosaveCheckTool(autosave *autosaveService) core.Tool {
+	return tools.New("check_payday_autosave").
+		Description("Detect recurring income (paychecks) and apply the user's pay-yourself-first rules to any new paycheck. Returns detected income streams, transfers proposed or executed, and recent history. Also used to mark a proposed transfer as confirmed or declined.").
+		Schema(tools.ObjectSchema(map[string]interface{}{
+			"transfer_id": tools.StringProperty("A proposed transfer the user just responded to"),
+			"decision":    tools.StringProperty("confirmed (after deposit_savings succeeded) or declined"),
+		})).
+		Handler(func(ctx context.Context, toolParams *core.ToolParams) (*core.ToolResult, error) {
+			var params struct {
+				TransferID string `json:"transfer_id"`
+				Decision   string `json:"decision"`
+			}
+			if err := json.Unmarshal(toolParams.Input, &params); err != nil {
+				return &core.ToolResult{
+					Success: false,
+					Error:   fmt.Sprintf("invalid input: %v", err),
+				}, nil
+			}
+
+			if params.TransferID != "" {
+				if params.Decision != "confirmed" && params.Decision != "declined" {
+					return &core.ToolResult{Success: false, Error: "decision must be confirmed or declined"}, nil
+				}
+				autosave.mu.Lock()
+				acct := autosave.account(toolParams.UserID)
+				found := false
+				for i := range acct.Transfers {
+					if acct.Transfers[i].ID == params.TransferID && acct.Transfers[i].Status == "proposed" {
+						acct.Transfers[i].Status = params.Decision
+						found = true
+					}
+				}
+				if found {
+					autosave.persist()
+				}
+				autosave.mu.Unlock()
+				if !found {
+					return &core.ToolResult{Success: false, Error: fmt.Sprintf("no proposed transfer %q", params.TransferID)}, nil
+				}
+			}
+
+			streams, created, err := autosave.process(ctx, toolParams.UserID, toolParams.RequestID)
+			if err != nil {
+				return &core.ToolResult{
+					Success: false,
+					Error:   err.Error(),
+				}, nil
+			}
+
+			autosave.mu.Lock()
+			acct := autosave.account(toolParams.UserID)
+			var awaiting []AutosaveTransfer
+			for _, t := range acct.Transfers {
+				if t.Status == "proposed" {
+					awaiting = append(awaiting, t)
+				}
+			}
+			history := acct.Transfers
+			if len(history) > 10 {
+				history = history[len(history)-10:]
+			}
+			rules := acct.Rules
+			autosave.mu.Unlock()
+
+			result := map[string]interface{}{
+				"income_streams":    streams,
+				"rules":             rules,
+				"new_transfers":     created,
+				"awaiting_decision": awaiting,
+				"recent_transfers":  history,
+				"checked_at":        time.Now().Format(time.RFC3339),
+			}
+			if len(awaiting) > 0 {
+				result["note"] = "Offer each awaiting transfer to the user; on yes call deposit_savings, then check_payday_autosave with decision=confirmed."
+			}
+
+			return &core.ToolResult{
+				Success: true,
+				Data:    result,
+			}, nil
+		}).
+		Build()
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// ISO 20022 CAMT.053 IMPORTER
+// ============================================================================
+// camt.053 is the XML end-of-day statement European banks send business
+// customers (and, under SEPA, increasingly everyone). Each <Ntry> is a
+// booking on the account: amount, credit/debit mark, booking and value
+// dates, and in <NtryDtls> the underlying transfers with the counterparty
+// and remittance information. A batch booking carries several <TxDtls>,
+// each of which becomes its own transaction.
+//
+// Versions 001.02 through 001.08 are read alike; element names are matched
+// without their namespace, and the parties moved under <Pty> in later
+// versions are looked for in both places. Only booked entries are imported -
+// pending and informational ones can still change.
+
+type camtAmount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+type camtParty struct {
+	Name    string `xml:"Nm"`
+	PtyName string `xml:"Pty>Nm"` // 001.08 and later
+}
+
+func (p *camtParty) name() string {
+	if p == nil {
+		return ""
+	}
+	return firstNonEmpty(strings.TrimSpace(p.Name), strings.TrimSpace(p.PtyName))
+}
+
+type camtAccount struct {
+	IBAN  string `xml:"Id>IBAN"`
+	Other string `xml:"Id>Othr>Id"`
+}
+
+func (a *camtAccount) id() string {
+	if a == nil {
+		return ""
+	}
+	return firstNonEmpty(a.IBAN, a.Other)
+}
+
+type camtBankCode struct {
+	Domain    string `xml:"Domn>Cd"`
+	Family    string `xml:"Domn>Fmly>Cd"`
+	SubFamily string `xml:"Domn>Fmly>SubFmlyCd"`
+}
+
+type camtTxDetails struct {
+	EndToEndID   string       `xml:"Refs>EndToEndId"`
+	ServicerRef  string       `xml:"Refs>AcctSvcrRef"`
+	Amount       *camtAmount  `xml:"Amt"`
+	AmountDetail *camtAmount  `xml:"AmtDtls>TxAmt>Amt"`
+	CreditDebit  string       `xml:"CdtDbtInd"`
+	BankCode     camtBankCode `xml:"BkTxCd"`
+	Debtor       *camtParty   `xml:"RltdPties>Dbtr"`
+	DebtorAcct   *camtAccount `xml:"RltdPties>DbtrAcct"`
+	Creditor     *camtParty   `xml:"RltdPties>Cdtr"`
+	CreditorAcct *camtAccount `xml:"RltdPties>CdtrAcct"`
+	Unstructured []string     `xml:"RmtInf>Ustrd"`
+	Reference    string       `xml:"RmtInf>Strd>CdtrRefInf>Ref"`
+	Additional   string       `xml:"AddtlTxInf"`
+}
+
+type camtEntry struct {
+	Ref         string          `xml:"NtryRef"`
+	Amount      camtAmount      `xml:"Amt"`
+	CreditDebit string          `xml:"CdtDbtInd"`
+	Reversal    bool            `xml:"RvslInd"`
+	Status      camtStatus      `xml:"Sts"`
+	Booking     camtDate        `xml:"BookgDt"`
+	Value       camtDate        `xml:"ValDt"`
+	ServicerRef string          `xml:"AcctSvcrRef"`
+	BankCode    camtBankCode    `xml:"BkTxCd"`
+	Details     []camtTxDetails `xml:"NtryDtls>TxDtls"`
+	Additional  string          `xml:"AddtlNtryInf"`
+}
+
+// camtStatus is BOOK, PDNG or INFO - as text up to 001.07, in <Cd> after.
+type camtStatus struct {
+	Text string `xml:",chardata"`
+	Code string `xml:"Cd"`
+}
+
+type camtStatementAccount struct {
+	camtAccount
+	Currency string `xml:"Ccy"`
+}
+
+// camtCategories maps ISO bank transaction codes (sub-family, then
+// family) to a category; the rest leave it empty.
+var camtCategories = map[string]string{
+	"CWDL": "cash",
+	"INTR": "interest",
+	"CHRG": "fees",
+	"COMM": "fees",
+}
+
+// importCAMT053 reads every statement in a camt.053 document.
+func importCAMT053(data []byte) (*ImportResult, error) {
+	result := &ImportResult{Format: "camt053", Encoding: "utf-8", DateFormat: "ISO 8601"}
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		raw, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		text, encoding, err := decodeCSV(raw, charset)
+		result.Encoding = encoding
+		return strings.NewReader(text), err
+	}
+
+	var (
+		account  string
+		currency = "EUR"
+		accounts = make(map[string]bool)
+		pending  int
+		sawStmt  bool
+	)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			line, _ := d.InputPos()
+			return nil, fmt.Errorf("invalid XML at line %d: %w", line, err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "Stmt", "Rpt", "Ntfctn":
+			// Rpt (camt.052) and Ntfctn (camt.054) share the entry layout.
+			sawStmt = true
+			account, currency = "", "EUR"
+		case "Acct":
+			var acct camtStatementAccount
+			if err := d.DecodeElement(&acct, &start); err != nil {
+				return nil, fmt.Errorf("invalid account: %w", err)
+			}
+			account = acct.id()
+			if acct.Currency != "" {
+				currency = acct.Currency
+			}
+			if account != "" {
+				accounts[account] = true
+			}
+		case "Ntry":
+			line, _ := d.InputPos()
+			var entry camtEntry
+			if err := d.DecodeElement(&entry, &start); err != nil {
+				result.Errors = append(result.Errors, RowError{Line: line, Error: err.Error()})
+				continue
+			}
+			status := strings.ToUpper(firstNonEmpty(strings.TrimSpace(entry.Status.Code), strings.TrimSpace(entry.Status.Text)))
+			if status != "" && status != "BOOK" {
+				pending++
+				continue
+			}
+			convertCAMTEntry(result, entry, line, account, currency)
+		}
+	}
+	if !sawStmt {
+		return nil, errors.New("no <Stmt> element; is this a camt.053 file?")
+	}
+	if pending > 0 {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("entries skipped because they aren't booked yet: %d", pending))
+	}
+	for acct := range accounts {
+		result.Accounts = append(result.Accounts, acct)
+	}
+	sort.Strings(result.Accounts)
+	return result, nil
+}
+
+// convertCAMTEntry adds an entry's transactions, or a RowError at the line
+// it starts on.
+func convertCAMTEntry(result *ImportResult, entry camtEntry, line int, account, currency string) {
+	fail := func(format string, args ...interface{}) {
+		result.Errors = append(result.Errors, RowError{Line: line, Error: fmt.Sprintf(format, args...)})
+	}
+	booked, err := parseCAMTDate(entry.Booking)
+	if err != nil {
+		if booked, err = parseCAMTDate(entry.Value); err != nil {
+			fail("booking date: %v", err)
+			return
+		}
+	}
+	valueDate, _ := parseCAMTDate(entry.Value)
+
+	// A single TxDtls is the entry itself; several split a batch booking
+	// and carry their own amounts.
+	details := entry.Details
+	if len(details) == 0 {
+		details = []camtTxDetails{{}}
+	}
+	batch := len(details) > 1
+	for i, tx := range details {
+		amt := entry.Amount
+		if batch {
+			switch {
+			case tx.Amount != nil:
+				amt = *tx.Amount
+			case tx.AmountDetail != nil:
+				amt = *tx.AmountDetail
+			default:
+				fail("batch transaction %d has no amount", i+1)
+				continue
+			}
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(amt.Value), 64)
+		if err != nil {
+			fail("amount %q is not a number", amt.Value)
+			continue
+		}
+		amount := round2(math.Abs(v))
+		if amount == 0 {
+			fail("amount is zero")
+			continue
+		}
+
+		mark := strings.ToUpper(firstNonEmpty(tx.CreditDebit, entry.CreditDebit))
+		if mark != "DBIT" && mark != "CRDT" {
+			fail("credit/debit indicator %q should be CRDT or DBIT", mark)
+			continue
+		}
+		// On a reversal the mark is already the way the money moved back
+		// (CRDT undoes a debit), so it needs no flipping.
+		out := mark == "DBIT"
+		txType := "receive"
+		party, partyAcct := tx.Debtor, tx.DebtorAcct
+		if out {
+			txType = "send"
+			party, partyAcct = tx.Creditor, tx.CreditorAcct
+		}
+
+		remittance := strings.TrimSpace(strings.Join(tx.Unstructured, " "))
+		description := firstNonEmpty(remittance, tx.Reference, strings.TrimSpace(tx.Additional), strings.TrimSpace(entry.Additional))
+		code := tx.BankCode
+		if code.Domain == "" {
+			code = entry.BankCode
+		}
+		category := firstNonEmpty(camtCategories[code.SubFamily], camtCategories[code.Family])
+		if entry.Reversal {
+			category = "reversal"
+		}
+
+		t := map[string]interface{}{
+			"timestamp":    booked.Format(time.RFC3339),
+			"type":         txType,
+			"amount":       amount,
+			"currency":     strings.ToUpper(firstNonEmpty(amt.Currency, currency)),
+			"counterparty": firstNonEmpty(party.name(), description),
+			"description":  description,
+			"category":     category,
+		}
+		if !valueDate.IsZero() {
+			t["value_date"] = valueDate.Format("2006-01-02")
+		}
+		if acct := partyAcct.id(); acct != "" {
+			t["counterparty_account"] = acct
+		}
+		if account != "" {
+			t["account"] = account
+		}
+		if ref := firstNonEmpty(tx.ServicerRef, entry.ServicerRef, entry.Ref); ref != "" {
+			id := "camt:" + account + ":" + ref
+			if batch && tx.ServicerRef == "" {
+				id += ":" + strconv.Itoa(i+1)
+			}
+			t["id"] = id
+		}
+		result.Transactions = append(result.Transactions, t)
+	}
+}
+
+// parseCAMTDate reads an ISODate or ISODateTime.
+func parseCAMTDate(d camtDate) (time.Time, error) {
+	if s := strings.TrimSpace(d.DateTime); s != "" {
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02T15:04:05"} {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("%q is not an ISO date-time", s)
+	}
+	s := strings.TrimSpace(d.Date)
+	if s == "" {
+		return time.Time{}, errors.New("missing")
+	}
+	// Some banks append a time zone offset to plain dates.
+	if len(s) > 10 {
+		s = s[:10]
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not an ISO date", d.Date)
+	}
+	return t, nil
+}
@@ -0,0 +1,7 @@
+package main
+
+import "testing"
+
+func TestCamt053Fixtures(t *testing.T) {
+	checkImportFixtures(t, "camt053_*.xml")
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+)
+
+// ============================================================================
+// RECORD AND REPLAY
+// ============================================================================
+// LIMINAL_MODE=record wraps the HTTP executor and writes every request and
+// response to the cassette at LIMINAL_CASSETTE, confirmations and
+// cancellations included. LIMINAL_MODE=replay serves the cassette back with
+// no network, and scenarios with a cassette (harness_test.go) run the tools
+// against one, so tool parsing of real Liminal responses is checked in CI
+// without credentials.
+//
+// Cassettes are scrubbed before they touch disk: JWTs and token fields are
+// redacted, and personal fields (IDs, names, emails, phone numbers, @tags,
+// counterparties, free-text descriptions and notes) are replaced with
+// placeholders numbered in the order they are first seen (@user_1, email-2,
+// note-1...), so relationships between transactions survive but nothing in
+// the file can be hashed back to the original. Amounts, dates, currencies,
+// types and categories are kept - they are what the tools parse.
+
+// Cassette is a recorded session.
+type Cassette struct {
+	Version      int           `json:"version"`
+	RecordedAt   time.Time     `json:"recorded_at"`
+	Source       string        `json:"source,omitempty"` // the Liminal base URL, or "simulator"
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded executor call.
+type Interaction struct {
+	Tool     string          `json:"tool"`
+	Write    bool            `json:"write,omitempty"`
+	Input    json.RawMessage `json:"input"`
+	Success  bool            `json:"success"`
+	Data     json.RawMessage `json:"data,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Duration string          `json:"duration,omitempty"`
+}
+
+const cassetteVersion = 1
+
+// Confirmations and cancellations are recorded as interactions with these
+// tool names and the confirmation ID as input.
+const (
+	confirmInteraction = "confirm"
+	cancelInteraction  = "cancel"
+)
+
+// confirmationRequest is how a confirmation or cancellation is recorded and
+// looked up.
+func confirmationRequest(tool, userID, confirmationID string) *core.ExecuteRequest {
+	input, _ := json.Marshal(map[string]string{"confirmation_id": confirmationID})
+	return &core.ExecuteRequest{UserID: userID, Tool: tool, Input: input}
+}
+
+func loadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	if c.Version != cassetteVersion {
+		return nil, fmt.Errorf("cassette %s has version %d, want %d", path, c.Version, cassetteVersion)
+	}
+	return &c, nil
+}
+
+// save writes the cassette to a temporary file next to path and renames it
+// into place, so a crash mid-write never leaves a truncated cassette.
+func (c *Cassette) save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ----------------------------------------------------------------------------
+// Scrubbing
+// ----------------------------------------------------------------------------
+
+var (
+	jwtPattern   = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	tagPattern   = regexp.MustCompile(`(^|[^A-Za-z0-9._%+-])@([A-Za-z0-9_]+)`)
+)
+
+// secretKeys are redacted outright.
+var secretKeys = map[string]bool{
+	"token": true, "access_token": true, "refresh_token": true, "id_token": true,
+	"authorization": true, "jwt": true, "password": true, "secret": true,
+}
+
+// personalKeys are replaced with pseudonyms.
+var personalKeys = map[string]bool{
+	"name": true, "first_name": true, "last_name": true, "display_name": true,
+	"email": true, "phone": true, "phone_number": true, "address": true,
+	"id": true, "user_id": true, "wallet_address": true, "iban": true, "account_number": true,
+	"counterparty": true, "description": true, "note": true, "memo": true,
+}
+
+// scrubber replaces personal values with placeholders. A recording numbers
+// them per prefix in the order they are first seen, so the same person gets
+// the same placeholder throughout the cassette; the mapping lives only in
+// memory. A scrubber with no map (maskScrubber) gives every value of a kind
+// the same placeholder, which is how replay matches live calls to recorded
+// ones without knowing the mapping.
+type scrubber struct {
+	mu   sync.Mutex
+	seen map[string]string // prefix + lowercased value -> placeholder
+	next map[string]int    // prefix -> last number handed out
+}
+
+func newScrubber() *scrubber {
+	return &scrubber{seen: make(map[string]string), next: make(map[string]int)}
+}
+
+// maskScrubber turns values into their kind only ("@user_*", "note-*").
+var maskScrubber = &scrubber{}
+
+// pseudonym returns the placeholder for value.
+func (sc *scrubber) pseudonym(prefix, value string) string {
+	if sc.seen == nil {
+		return prefix + "*"
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	key := prefix + strings.ToLower(value)
+	if p, ok := sc.seen[key]; ok {
+		return p
+	}
+	sc.next[prefix]++
+	p := fmt.Sprintf("%s%d", prefix, sc.next[prefix])
+	sc.seen[key] = p
+	return p
+}
+
+func (sc *scrubber) scrubString(s string) string {
+	s = jwtPattern.ReplaceAllString(s, "[REDACTED_JWT]")
+	// Tags before emails, so the domain of a scrubbed email isn't taken for a tag.
+	s = tagPattern.ReplaceAllStringFunc(s, func(m string) string {
+		at := strings.IndexByte(m, '@')
+		// An underscore, not a hyphen, so the placeholder is a whole tag.
+		return m[:at+1] + sc.pseudonym("user_", m[at+1:])
+	})
+	s = emailPattern.ReplaceAllStringFunc(s, func(m string) string { return sc.pseudonym("email-", m) + "@example.com" })
+	return s
+}
+
+func (sc *scrubber) scrubValue(key string, v interface{}) interface{} {
+	lower := strings.ToLower(key)
+	switch val := v.(type) {
+	case map[string]interface{}:
+		// Sorted keys number placeholders the same way on every run.
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := make(map[string]interface{}, len(val))
+		for _, k := range keys {
+			out[k] = sc.scrubValue(k, val[k])
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, child := range val {
+			out[i] = sc.scrubValue(key, child)
+		}
+		return out
+	case string:
+		switch {
+		case secretKeys[lower]:
+			return "[REDACTED]"
+		case personalKeys[lower] && strings.HasPrefix(val, "@"):
+			return sc.scrubString(val) // keep it recognisable as an @tag
+		case personalKeys[lower]:
+			return sc.pseudonym(lower+"-", val)
+		}
+		return sc.scrubString(val)
+	}
+	return v
+}
+
+// scrubJSON returns a scrubbed, canonical copy of a JSON document (map keys
+// sorted), so it can also be used as a lookup key.
+func (sc *scrubber) scrubJSON(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return json.RawMessage("{}")
+	}
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return json.RawMessage(`"[UNPARSEABLE]"`)
+	}
+	out, _ := json.Marshal(sc.scrubValue("", v))
+	return out
+}
+
+// ----------------------------------------------------------------------------
+// Recording executor
+// ----------------------------------------------------------------------------
+
+// recordingExecutor passes calls through to the real executor and appends a
+// scrubbed copy of each to the cassette.
+type recordingExecutor struct {
+	inner core.ToolExecutor
+	path  string
+	scrub *scrubber
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+func newRecordingExecutor(inner core.ToolExecutor, path, source string) *recordingExecutor {
+	return &recordingExecutor{
+		inner:    inner,
+		path:     path,
+		scrub:    newScrubber(),
+		cassette: Cassette{Version: cassetteVersion, RecordedAt: time.Now().UTC(), Source: source},
+	}
+}
+
+func (r *recordingExecutor) record(req *core.ExecuteRequest, write bool, started time.Time, resp *core.ExecuteResponse, err error) {
+	in := Interaction{
+		Tool:     req.Tool,
+		Write:    write,
+		Input:    r.scrub.scrubJSON(req.Input),
+		Duration: time.Since(started).Round(time.Millisecond).String(),
+	}
+	switch {
+	case err != nil:
+		in.Error = r.scrub.scrubString(err.Error())
+	case resp != nil:
+		in.Success = resp.Success
+		in.Error = r.scrub.scrubString(resp.Error)
+		if len(resp.Data) > 0 {
+			in.Data = r.scrub.scrubJSON(resp.Data)
+		}
+	default:
+		in.Success = true // a cancellation, which only returns an error
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+	// Rewrite on every call so a killed process still leaves a usable cassette.
+	if err := r.cassette.save(r.path); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Failed to write cassette %s: %v\n", r.path, err)
+	}
+}
+
+func (r *recordingExecutor) Execute(ctx context.Context, req *core.ExecuteRequest) (*core.ExecuteResponse, error) {
+	started := time.Now()
+	resp, err := r.inner.Execute(ctx, req)
+	r.record(req, false, started, resp, err)
+	return resp, err
+}
+
+func (r *recordingExecutor) ExecuteWrite(ctx context.Context, req *core.ExecuteRequest) (*core.ExecuteResponse, error) {
+	started := time.Now()
+	resp, err := r.inner.ExecuteWrite(ctx, req)
+	r.record(req, true, started, resp, err)
+	return resp, err
+}
+
+func (r *recordingExecutor) Confirm(ctx context.Context, userID, confirmationID string) (*core.ExecuteResponse, error) {
+	started := time.Now()
+	resp, err := r.inner.Confirm(ctx, userID, confirmationID)
+	r.record(confirmationRequest(confirmInteraction, userID, confirmationID), true, started, resp, err)
+	return resp, err
+}
+
+func (r *recordingExecutor) Cancel(ctx context.Context, userID, confirmationID string) error {
+	started := time.Now()
+	err := r.inner.Cancel(ctx, userID, confirmationID)
+	r.record(confirmationRequest(cancelInteraction, userID, confirmationID), true, started, nil, err)
+	return err
+}
+
+// ----------------------------------------------------------------------------
+// Replay executor
+// ----------------------------------------------------------------------------
+
+// replayExecutor serves recorded responses. Calls are matched on tool and
+// masked input - personal values only count by kind, since the recording's
+// placeholders can't be worked out from a live value - so two sends of the
+// same amount to different @tags match the same recordings. Repeated
+// matching calls are served in recorded order, and the last one is repeated
+// once the queue runs out.
+type replayExecutor struct {
+	mu     sync.Mutex
+	queues map[string][]Interaction
+	served map[string]int
+}
+
+func newReplayExecutor(c *Cassette) *replayExecutor {
+	r := &replayExecutor{
+		queues: make(map[string][]Interaction),
+		served: make(map[string]int),
+	}
+	for _, in := range c.Interactions {
+		// Placeholders mask like the values they replaced.
+		key := replayKey(in.Tool, in.Write, maskScrubber.scrubJSON(in.Input))
+		r.queues[key] = append(r.queues[key], in)
+	}
+	return r
+}
+
+// replayKey identifies a call by tool and masked, canonical input.
+func replayKey(tool string, write bool, input json.RawMessage) string {
+	return fmt.Sprintf("%s|%t|%s", tool, write, input)
+}
+
+var errNoInteraction = errors.New("no recorded interaction")
+
+func (r *replayExecutor) serve(req *core.ExecuteRequest, write bool) (*core.ExecuteResponse, error) {
+	key := replayKey(req.Tool, write, maskScrubber.scrubJSON(req.Input))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	queue := r.queues[key]
+	if len(queue) == 0 {
+		return nil, fmt.Errorf("%w for %s %s", errNoInteraction, req.Tool, maskScrubber.scrubJSON(req.Input))
+	}
+	i := r.served[key]
+	if i >= len(queue) {
+		i = len(queue) - 1
+	}
+	r.served[key]++
+
+	in := queue[i]
+	return &core.ExecuteResponse{Success: in.Success, Data: in.Data, Error: in.Error}, nil
+}
+
+func (r *replayExecutor) Execute(ctx context.Context, req *core.ExecuteRequest) (*core.ExecuteResponse, error) {
+	return r.serve(req, false)
+}
+
+// ExecuteWrite replays the recorded write, with the confirmation ID it was
+// given then; Confirm and Cancel replay what that ID got. Replays never move
+// money.
+func (r *replayExecutor) ExecuteWrite(ctx context.Context, req *core.ExecuteRequest) (*core.ExecuteResponse, error) {
+	return r.serve(req, true)
+}
+
+func (r *replayExecutor) Confirm(ctx context.Context, userID, confirmationID string) (*core.ExecuteResponse, error) {
+	return r.serve(confirmationRequest(confirmInteraction, userID, confirmationID), true)
+}
+
+func (r *replayExecutor) Cancel(ctx context.Context, userID, confirmationID string) error {
+	resp, err := r.serve(confirmationRequest(cancelInteraction, userID, confirmationID), true)
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return errors.New(resp.Error)
+	}
+	return nil
+}
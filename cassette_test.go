@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+)
+
+// echoExecutor answers every call with its own input.
+type echoExecutor struct{}
+
+func (echoExecutor) Execute(ctx context.Context, req *core.ExecuteRequest) (*core.ExecuteResponse, error) {
+	return &core.ExecuteResponse{Success: true, Data: req.Input}, nil
+}
+
+func (e echoExecutor) ExecuteWrite(ctx context.Context, req *core.ExecuteRequest) (*core.ExecuteResponse, error) {
+	return e.Execute(ctx, req)
+}
+
+func (echoExecutor) Confirm(ctx context.Context, userID, confirmationID string) (*core.ExecuteResponse, error) {
+	return &core.ExecuteResponse{Success: true}, nil
+}
+
+func (echoExecutor) Cancel(ctx context.Context, userID, confirmationID string) error { return nil }
+
+// TestCassetteNumbersPlaceholders checks a recording replaces personal
+// values with numbered placeholders that keep people apart, writes nothing
+// of the originals, and still replays for the live values.
+func TestCassetteNumbersPlaceholders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	rec := newRecordingExecutor(echoExecutor{}, path, "test")
+	ctx := context.Background()
+	calls := []string{
+		`{"recipient":"@carol","amount":"20.00","note":"dinner with carol@example.org"}`,
+		`{"recipient":"@dave","amount":"20.00","note":"rent"}`,
+		`{"recipient":"@carol","amount":"5.00","note":"coffee"}`,
+	}
+	for _, input := range calls {
+		if _, err := rec.ExecuteWrite(ctx, &core.ExecuteRequest{UserID: "u", Tool: "send_money", Input: json.RawMessage(input)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"carol", "dave", "dinner", "rent", "coffee"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("cassette contains %q", secret)
+		}
+	}
+	if matches, _ := filepath.Glob(path + ".*.tmp"); len(matches) > 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+
+	cassette, err := loadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recipients []string
+	for _, in := range cassette.Interactions {
+		var input struct {
+			Recipient string `json:"recipient"`
+		}
+		if err := json.Unmarshal(in.Input, &input); err != nil {
+			t.Fatal(err)
+		}
+		recipients = append(recipients, input.Recipient)
+	}
+	if want := []string{"@user_1", "@user_2", "@user_1"}; strings.Join(recipients, " ") != strings.Join(want, " ") {
+		t.Errorf("recipients %v, want %v", recipients, want)
+	}
+
+	replay := newReplayExecutor(cassette)
+	resp, err := replay.ExecuteWrite(ctx, &core.ExecuteRequest{UserID: "u", Tool: "send_money", Input: json.RawMessage(`{"recipient":"@carol","amount":"5.00","note":"coffee"}`)})
+	if err != nil || !resp.Success {
+		t.Fatalf("replay of a recorded send failed: %v %v", err, resp)
+	}
+}
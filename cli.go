@@ -0,0 +1,413 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/tools"
+	"gopkg.in/yaml.v3"
+)
+
+// ============================================================================
+// COMMAND LINE
+// ============================================================================
+// With no arguments the binary runs the server. Subcommands are offline
+// utilities that never need an Anthropic key:
+//
+//   neurapay generate [flags]       write a synthetic persona history as CSV
+//   neurapay analyze <analysis>     spending, personality, recurring or counterparties on a statement
+//   neurapay config check           print the effective config, secrets masked
+//   neurapay prompt preview         print the system prompt a version produces
+//   neurapay import <file>          read a bank statement (CSV, OFX, QIF, camt.053, MT940)
+//   neurapay export [flags]         write transactions as CSV, JSON, OFX or an HTML/PDF report
+
+const cliUsage = `Usage:
+  neurapay                          run the server
+  neurapay generate [flags]         generate a persona's transactions as CSV
+  neurapay analyze spending|personality|recurring|counterparties [--csv file] [--days N] [--heatmap] [--tz zone] [--format json|table]
+  neurapay config check [--config file]  validate and print the effective config
+  neurapay prompt preview [--version v] [--user id] [--config file]  print the assembled system prompt
+  neurapay prompt versions          list the built-in prompt versions
+  neurapay import [--preset p] [--mapping file] [--user id] [-o out.csv] <file>  read a bank statement
+  neurapay import presets           list the CSV presets
+  neurapay export [--csv file] [--user id] [--format csv|json|ofx|html|pdf] [--month YYYY-MM] [filters] [-o file]  export transactions or a monthly report
+`
+
+// runCLI runs a subcommand and returns the process exit code.
+func runCLI(args []string) int {
+	switch args[0] {
+	case "generate":
+		return runGenerateCommand(args[1:])
+	case "analyze":
+		return runAnalyzeCommand(args[1:])
+	case "config":
+		if len(args) > 1 && args[1] == "check" {
+			return runConfigCheckCommand(args[2:])
+		}
+	case "prompt":
+		if len(args) > 1 && args[1] == "preview" {
+			return runPromptPreviewCommand(args[2:])
+		}
+		if len(args) > 1 && args[1] == "versions" {
+			for _, v := range promptVersions() {
+				fmt.Println(v)
+			}
+			return 0
+		}
+	case "import":
+		return runImportCommand(args[1:])
+	case "export":
+		return runExportCommand(args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(cliUsage)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], cliUsage)
+	return 2
+}
+
+func runGenerateCommand(args []string) int {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	archetype := fs.String("archetype", "reward_seeker", "persona: "+strings.Join(personaNames(), ", "))
+	income := fs.String("income", "", "weekly, biweekly, monthly or irregular (default: the persona's)")
+	currency := fs.String("currency", "USD", "currency code")
+	days := fs.Int("days", 30, "days of history")
+	seed := fs.Int64("seed", 1, "random seed; the same seed gives the same history")
+	end := fs.String("end", "", "last day of history, YYYY-MM-DD (default: today)")
+	out := fs.String("o", "", "output file (default: stdout)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg := GeneratorConfig{Archetype: *archetype, Income: *income, Currency: *currency, Days: *days, Seed: *seed}
+	if *end != "" {
+		t, err := time.Parse("2006-01-02", *end)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ -end must be YYYY-MM-DD: %v\n", err)
+			return 2
+		}
+		cfg.End = t
+	}
+	transactions, err := generateTransactions(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 2
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := writeTransactionsCSV(w, transactions); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+	if *out != "" {
+		fmt.Fprintf(os.Stderr, "✅ Wrote %d %s transactions to %s\n", len(transactions), *archetype, *out)
+	}
+	return 0
+}
+
+func runConfigCheckCommand(args []string) int {
+	fs := flag.NewFlagSet("config check", flag.ContinueOnError)
+	path := fs.String("config", "", "config file (default: NEURAPAY_CONFIG or ./neurapay.yaml)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := loadConfig(*path, os.Getenv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+
+	source := cfg.source
+	if source == "" {
+		source = "none (defaults and environment)"
+	}
+	fmt.Printf("# Config file: %s\n", source)
+	if len(cfg.overrides) > 0 {
+		fmt.Printf("# Environment overrides: %s\n", strings.Join(cfg.overrides, ", "))
+	}
+	masked := cfg.masked()
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	if err := enc.Encode(&masked); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+	enc.Close()
+
+	if err := cfg.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "\n❌ %v\n", err)
+		return 1
+	}
+	fmt.Fprintln(os.Stderr, "\n✅ Config is valid")
+	return 0
+}
+
+func runPromptPreviewCommand(args []string) int {
+	fs := flag.NewFlagSet("prompt preview", flag.ContinueOnError)
+	path := fs.String("config", "", "config file (default: NEURAPAY_CONFIG or ./neurapay.yaml)")
+	version := fs.String("version", "", "built-in prompt version (default: the configured one)")
+	userID := fs.String("user", "", "also show the start_session context for this user")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := loadConfig(*path, os.Getenv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+	if *version != "" {
+		cfg.Model.PromptVersion = *version
+		cfg.Model.SystemPromptFile = ""
+	}
+	cfg.apply()
+
+	// Tools are only built to read their names and descriptions; nothing
+	// runs, so no executor is needed.
+	log.SetOutput(io.Discard)
+	registry := newToolRegistry(cfg.Tools)
+	registry.register("BANKING TOOLS", tools.LiminalTools(nil))
+	custom := newToolset(nil, nil, cfg)
+	registry.register("NEURAPAY TOOLS", custom.tools)
+	log.SetOutput(os.Stderr)
+
+	prompt, err := newPromptBuilder(cfg, registry, custom.contexts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+	out, err := prompt.preview(*userID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+	fmt.Println(out)
+	fmt.Fprintf(os.Stderr, "\n# prompt %s, %d tools, %d characters\n", prompt.version, len(registry.tools), len(out))
+	return 0
+}
+
+func runImportCommand(args []string) int {
+	if len(args) > 0 && args[0] == "presets" {
+		for _, name := range csvPresetNames() {
+			fmt.Println(name)
+		}
+		return 0
+	}
+
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	preset := fs.String("preset", "", "CSV preset (default: detect from the header)")
+	mappingPath := fs.String("mapping", "", "YAML file with a CSV mapping, instead of a preset")
+	out := fs.String("o", "", "write the transactions as transactions.csv to this file")
+	format := fs.String("format", "table", "report as table or json")
+	userID := fs.String("user", "", "add the statement to this user's imported statements")
+	configPath := fs.String("config", "", "config file (default: NEURAPAY_CONFIG or ./neurapay.yaml)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "❌ import needs exactly one file\n\n%s", cliUsage)
+		return 2
+	}
+	path := fs.Arg(0)
+
+	// The config has the CSV mappings and the data dir --user writes to.
+	cfg, err := loadConfig(*configPath, os.Getenv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+	cfg.apply()
+
+	var mapping *CSVMapping
+	if *mappingPath != "" {
+		raw, err := os.ReadFile(*mappingPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			return 1
+		}
+		mapping = &CSVMapping{}
+		if err := yaml.Unmarshal(raw, mapping); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ failed to parse mapping %s: %v\n", *mappingPath, err)
+			return 1
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+	var result *ImportResult
+	if mapping != nil {
+		result, err = importCSV(data, *preset, mapping)
+	} else {
+		result, err = importStatement(path, data, *preset)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+
+	var stored *StatementFile
+	if *userID != "" {
+		if len(result.Transactions) == 0 {
+			fmt.Fprintln(os.Stderr, "❌ no transactions could be read from the file")
+			return 1
+		}
+		log.SetOutput(io.Discard)
+		file, err := newStatementStore().add(*userID, path, data, result)
+		log.SetOutput(os.Stderr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			return 1
+		}
+		stored = &file
+	}
+
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		if err := writeTransactionsCSV(f, result.Transactions); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			return 1
+		}
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		report := struct {
+			*ImportResult
+			Imported  int            `json:"imported"`
+			Statement *StatementFile `json:"statement,omitempty"`
+		}{result, len(result.Transactions), stored}
+		if report.Errors == nil {
+			report.Errors = []RowError{}
+		}
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			return 1
+		}
+	} else {
+		fmt.Printf("Format:      %s\n", strings.ToUpper(result.Format))
+		if result.Format == "csv" {
+			fmt.Printf("Preset:      %s\n", result.Preset)
+		}
+		fmt.Printf("Encoding:    %s\n", result.Encoding)
+		if result.Format == "csv" {
+			fmt.Printf("Delimiter:   %q\n", result.Delimiter)
+			fmt.Printf("Header line: %d\n", result.HeaderLine)
+		}
+		fmt.Printf("Date format: %s\n", result.DateFormat)
+		if len(result.Accounts) > 0 {
+			fmt.Printf("Accounts:    %s\n", strings.Join(result.Accounts, ", "))
+		}
+		fmt.Printf("Imported:    %d transactions\n", len(result.Transactions))
+		if stored != nil {
+			fmt.Printf("Stored for:  %s as %s (%d new, %d already imported)\n", *userID, stored.ID, stored.Added, stored.Duplicates)
+		}
+		for _, w := range result.Warnings {
+			fmt.Printf("⚠️  %s\n", w)
+		}
+		if len(result.Errors) > 0 {
+			fmt.Printf("\nSkipped %d rows:\n", len(result.Errors))
+			for _, e := range result.Errors {
+				fmt.Printf("  line %d: %s\n", e.Line, e.Error)
+			}
+		}
+	}
+	if *out != "" {
+		fmt.Fprintf(os.Stderr, "✅ Wrote %d transactions to %s\n", len(result.Transactions), *out)
+	}
+	return 0
+}
+
+func runExportCommand(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	csvPath := fs.String("csv", "", "statement file to export from (default: data.csv_path)")
+	userID := fs.String("user", "", "add this user's imported statements")
+	format := fs.String("format", "csv", strings.Join(exportFormats, ", "))
+	month := fs.String("month", "", "calendar month, YYYY-MM (reports default to the latest)")
+	var filter TransactionFilter
+	fs.StringVar(&filter.From, "from", "", "first day, YYYY-MM-DD")
+	fs.StringVar(&filter.To, "to", "", "last day, YYYY-MM-DD")
+	fs.StringVar(&filter.Type, "type", "", "send or receive")
+	fs.StringVar(&filter.Category, "category", "", "only this category")
+	fs.StringVar(&filter.Counterparty, "counterparty", "", "only this @tag, or counterparties containing this text")
+	fs.Float64Var(&filter.MinAmount, "min-amount", 0, "only amounts of at least this")
+	fs.Float64Var(&filter.MaxAmount, "max-amount", 0, "only amounts of at most this")
+	include := fs.String("include", "", "analyses for json, html and pdf: "+strings.Join(exportSections, ",")+" (default: all)")
+	out := fs.String("o", "", "output file (default: stdout)")
+	configPath := fs.String("config", "", "config file (default: NEURAPAY_CONFIG or ./neurapay.yaml)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := loadConfig(*configPath, os.Getenv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+	cfg.apply()
+
+	// The importers and the statement store log for the server.
+	log.SetOutput(io.Discard)
+	transactions, err := loadTransactionsFromCSV(firstNonEmpty(*csvPath, csvTransactionsPath))
+	if err == nil && *userID != "" {
+		transactions, _ = newStatementStore().withStatements(*userID, transactions, time.Time{})
+	}
+	log.SetOutput(os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+
+	export, err := buildExport(transactions, ExportRequest{
+		Format:  *format,
+		Filter:  filter,
+		Month:   *month,
+		Include: strings.Split(*include, ","),
+	}, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := export.write(w); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+	if *out != "" {
+		fmt.Fprintf(os.Stderr, "✅ Wrote %d transactions (%s) to %s\n", export.Count, export.Period, *out)
+	}
+	return 0
+}
@@ -0,0 +1,473 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ============================================================================
+// CONFIGURATION FILE
+// ============================================================================
+// Settings come from three layers, later ones winning:
+//
+//   1. built-in defaults (defaultConfig)
+//   2. a YAML file: NEURAPAY_CONFIG, or ./neurapay.yaml when it exists
+//   3. environment variables (configEnv) - the names the README has always
+//      documented, so existing .env files keep working
+//
+// The result is validated before the server starts; `neurapay config check`
+// prints the effective config with secrets masked.
+
+// Config is the effective NeuraPay configuration.
+type Config struct {
+	Server        ServerConfig       `yaml:"server"`
+	Model         ModelConfig        `yaml:"model"`
+	Liminal       LiminalConfig      `yaml:"liminal"`
+	Data          DataConfig         `yaml:"data"`
+	Monitor       MonitorConfig      `yaml:"monitor"`
+	Notifications NotificationConfig `yaml:"notifications"`
+	Tools         ToolsConfig        `yaml:"tools"`
+	Policy        PolicyConfig       `yaml:"policy"`
+
+	source    string   // file the config was read from, if any
+	overrides []string // environment variables that were applied
+}
+
+// ServerConfig covers the listeners and local state.
+type ServerConfig struct {
+	Port      string `yaml:"port"`
+	APIPort   string `yaml:"api_port"`
+	DataDir   string `yaml:"data_dir"`
+	PublicURL string `yaml:"public_url"` // where users reach the companion API; for download links
+}
+
+// ModelConfig covers the Anthropic model.
+type ModelConfig struct {
+	AnthropicKey     string `yaml:"anthropic_key"`
+	Name             string `yaml:"name"`
+	MaxTokens        int    `yaml:"max_tokens"`
+	PromptVersion    string `yaml:"prompt_version"`     // built-in template from prompts/
+	SystemPromptFile string `yaml:"system_prompt_file"` // replaces the built-in prompt
+}
+
+// LiminalConfig selects and configures the executor.
+type LiminalConfig struct {
+	Mode              string `yaml:"mode"` // http, simulator, record or replay
+	BaseURL           string `yaml:"base_url"`
+	SimulatorFixtures string `yaml:"simulator_fixtures"`
+	Cassette          string `yaml:"cassette"`
+	RefreshURL        string `yaml:"refresh_url"`
+	CredentialsKey    string `yaml:"credentials_key"`
+}
+
+// DataConfig covers where transactions come from.
+type DataConfig struct {
+	CSVPath          string                `yaml:"csv_path"`
+	CSVPreset        string                `yaml:"csv_preset"`        // layout of csv_path (importer.go); empty = detect
+	CSVMappings      map[string]CSVMapping `yaml:"csv_mappings"`      // extra presets, by name
+	TransactionLimit int                   `yaml:"transaction_limit"` // rows fetched per get_transactions call
+	Cache            CacheConfig           `yaml:"cache"`
+}
+
+// CacheConfig covers the local transaction cache (txcache.go).
+type CacheConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	MaxAge  string `yaml:"max_age"` // serve without syncing for this long; 0 syncs every read
+}
+
+// MonitorConfig covers the background monitor.
+type MonitorConfig struct {
+	Interval string `yaml:"interval"`
+}
+
+// NotificationConfig covers the email and push channels.
+type NotificationConfig struct {
+	SMTP SMTPConfig `yaml:"smtp"`
+	Push PushConfig `yaml:"push"`
+}
+
+// SMTPConfig is the email channel's server.
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+// PushConfig is the push relay.
+type PushConfig struct {
+	RelayURL string `yaml:"relay_url"`
+	Token    string `yaml:"token"`
+}
+
+// ToolsConfig decides which tools are registered and who may use them; see
+// registry.go.
+type ToolsConfig struct {
+	Disabled []string            `yaml:"disabled"`
+	Testing  bool                `yaml:"testing"` // register testing-only tools like get_csv_transactions
+	Cohorts  map[string][]string `yaml:"cohorts"` // cohort name -> user IDs
+	Flags    map[string]ToolFlag `yaml:"flags"`   // tool name -> who gets it
+}
+
+// ToolFlag rolls a tool out to some users. A user gets the tool if any rule
+// matches; a flag with no rules hides it from everyone.
+type ToolFlag struct {
+	Users   []string `yaml:"users"`
+	Cohorts []string `yaml:"cohorts"`
+	Percent int      `yaml:"percent"` // share of users, by a stable hash of the user ID
+}
+
+// PolicyConfig holds the money movement limits the prompt states. Zero means
+// no limit.
+type PolicyConfig struct {
+	MaxTransfer   float64 `yaml:"max_transfer"`   // largest single money movement; enforced by policyExecutor
+	LargeTransfer float64 `yaml:"large_transfer"` // above this the assistant double-checks with the user
+}
+
+// defaultConfig is what NeuraPay runs with when nothing is configured.
+func defaultConfig() *Config {
+	return &Config{
+		Server: ServerConfig{Port: "8080", APIPort: "8081", DataDir: "data"},
+		Model:  ModelConfig{Name: "claude-sonnet-4-20250514", MaxTokens: 4096, PromptVersion: defaultPromptVersion},
+		Liminal: LiminalConfig{
+			Mode:              "http",
+			BaseURL:           "https://api.liminal.cash",
+			SimulatorFixtures: "fixtures/simulator",
+		},
+		Data:          DataConfig{CSVPath: "transactions.csv", TransactionLimit: 100, Cache: CacheConfig{Enabled: true, MaxAge: "2m"}},
+		Monitor:       MonitorConfig{Interval: "5m"},
+		Notifications: NotificationConfig{SMTP: SMTPConfig{Port: "587"}},
+	}
+}
+
+// configEnv maps environment variables onto config fields.
+var configEnv = []struct {
+	name string
+	set  func(c *Config, v string) error
+}{
+	{"PORT", func(c *Config, v string) error { c.Server.Port = v; return nil }},
+	{"API_PORT", func(c *Config, v string) error { c.Server.APIPort = v; return nil }},
+	{"NEURAPAY_DATA_DIR", func(c *Config, v string) error { c.Server.DataDir = v; return nil }},
+	{"NEURAPAY_PUBLIC_URL", func(c *Config, v string) error { c.Server.PublicURL = v; return nil }},
+	{"ANTHROPIC_API_KEY", func(c *Config, v string) error { c.Model.AnthropicKey = v; return nil }},
+	{"NEURAPAY_MODEL", func(c *Config, v string) error { c.Model.Name = v; return nil }},
+	{"NEURAPAY_MAX_TOKENS", func(c *Config, v string) error { return setInt(&c.Model.MaxTokens, v) }},
+	{"NEURAPAY_PROMPT_VERSION", func(c *Config, v string) error { c.Model.PromptVersion = v; return nil }},
+	{"NEURAPAY_SYSTEM_PROMPT_FILE", func(c *Config, v string) error { c.Model.SystemPromptFile = v; return nil }},
+	{"LIMINAL_MODE", func(c *Config, v string) error { c.Liminal.Mode = v; return nil }},
+	{"LIMINAL_BASE_URL", func(c *Config, v string) error { c.Liminal.BaseURL = v; return nil }},
+	{"LIMINAL_SIMULATOR_FIXTURES", func(c *Config, v string) error { c.Liminal.SimulatorFixtures = v; return nil }},
+	{"LIMINAL_CASSETTE", func(c *Config, v string) error { c.Liminal.Cassette = v; return nil }},
+	{"LIMINAL_REFRESH_URL", func(c *Config, v string) error { c.Liminal.RefreshURL = v; return nil }},
+	{"NEURAPAY_CREDENTIALS_KEY", func(c *Config, v string) error { c.Liminal.CredentialsKey = v; return nil }},
+	{"NEURAPAY_CSV_PATH", func(c *Config, v string) error { c.Data.CSVPath = v; return nil }},
+	{"NEURAPAY_CSV_PRESET", func(c *Config, v string) error { c.Data.CSVPreset = v; return nil }},
+	{"NEURAPAY_TRANSACTION_LIMIT", func(c *Config, v string) error { return setInt(&c.Data.TransactionLimit, v) }},
+	{"NEURAPAY_CACHE_ENABLED", func(c *Config, v string) error { return setBool(&c.Data.Cache.Enabled, v) }},
+	{"NEURAPAY_CACHE_MAX_AGE", func(c *Config, v string) error { c.Data.Cache.MaxAge = v; return nil }},
+	{"MONITOR_INTERVAL", func(c *Config, v string) error { c.Monitor.Interval = v; return nil }},
+	{"SMTP_HOST", func(c *Config, v string) error { c.Notifications.SMTP.Host = v; return nil }},
+	{"SMTP_PORT", func(c *Config, v string) error { c.Notifications.SMTP.Port = v; return nil }},
+	{"SMTP_USERNAME", func(c *Config, v string) error { c.Notifications.SMTP.Username = v; return nil }},
+	{"SMTP_PASSWORD", func(c *Config, v string) error { c.Notifications.SMTP.Password = v; return nil }},
+	{"SMTP_FROM", func(c *Config, v string) error { c.Notifications.SMTP.From = v; return nil }},
+	{"PUSH_RELAY_URL", func(c *Config, v string) error { c.Notifications.Push.RelayURL = v; return nil }},
+	{"PUSH_RELAY_TOKEN", func(c *Config, v string) error { c.Notifications.Push.Token = v; return nil }},
+	{"NEURAPAY_DISABLED_TOOLS", func(c *Config, v string) error {
+		c.Tools.Disabled = nil
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				c.Tools.Disabled = append(c.Tools.Disabled, name)
+			}
+		}
+		return nil
+	}},
+	{"NEURAPAY_TESTING_TOOLS", func(c *Config, v string) error { return setBool(&c.Tools.Testing, v) }},
+	{"NEURAPAY_MAX_TRANSFER", func(c *Config, v string) error { return setFloat(&c.Policy.MaxTransfer, v) }},
+	{"NEURAPAY_LARGE_TRANSFER", func(c *Config, v string) error { return setFloat(&c.Policy.LargeTransfer, v) }},
+}
+
+func setInt(dst *int, v string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("must be a whole number, got %q", v)
+	}
+	*dst = n
+	return nil
+}
+
+func setFloat(dst *float64, v string) error {
+	f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil {
+		return fmt.Errorf("must be a number, got %q", v)
+	}
+	*dst = f
+	return nil
+}
+
+func setBool(dst *bool, v string) error {
+	b, err := strconv.ParseBool(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("must be true or false, got %q", v)
+	}
+	*dst = b
+	return nil
+}
+
+// loadConfig builds the effective config. path may be empty, in which case
+// NEURAPAY_CONFIG or ./neurapay.yaml is used if present.
+func loadConfig(path string, getenv func(string) string) (*Config, error) {
+	cfg := defaultConfig()
+
+	explicit := path != ""
+	if !explicit {
+		path = getenv("NEURAPAY_CONFIG")
+		explicit = path != ""
+	}
+	if path == "" {
+		path = "neurapay.yaml"
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true) // a misspelled key is an error, not a silent default
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+		}
+		cfg.source = path
+	case explicit || !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var problems []string
+	for _, env := range configEnv {
+		v := getenv(env.name)
+		if v == "" {
+			continue
+		}
+		if err := env.set(cfg, v); err != nil {
+			problems = append(problems, fmt.Sprintf("%s %v", env.name, err))
+			continue
+		}
+		cfg.overrides = append(cfg.overrides, env.name)
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid environment:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return cfg, nil
+}
+
+// CacheMaxAge is the parsed cache max age; validate guarantees it.
+func (c *Config) CacheMaxAge() time.Duration {
+	d, _ := time.ParseDuration(c.Data.Cache.MaxAge)
+	return d
+}
+
+// MonitorInterval is the parsed monitor interval; validate guarantees it.
+func (c *Config) MonitorInterval() time.Duration {
+	d, _ := time.ParseDuration(c.Monitor.Interval)
+	return d
+}
+
+// validate checks the whole config and reports every problem at once.
+func (c *Config) validate() error {
+	var problems []string
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	checkPort := func(field, v string) {
+		if n, err := strconv.Atoi(v); err != nil || n < 1 || n > 65535 {
+			fail("%s must be a port number between 1 and 65535, got %q", field, v)
+		}
+	}
+	checkPort("server.port", c.Server.Port)
+	checkPort("server.api_port", c.Server.APIPort)
+	if c.Server.Port == c.Server.APIPort {
+		fail("server.port and server.api_port must differ, both are %s", c.Server.Port)
+	}
+	if c.Server.DataDir == "" {
+		fail("server.data_dir is required")
+	}
+
+	if c.Model.AnthropicKey == "" {
+		fail("model.anthropic_key is required (or set ANTHROPIC_API_KEY)")
+	}
+	if c.Model.Name == "" {
+		fail("model.name is required")
+	}
+	if c.Model.MaxTokens < 1 || c.Model.MaxTokens > 64000 {
+		fail("model.max_tokens must be between 1 and 64000, got %d", c.Model.MaxTokens)
+	}
+	if f := c.Model.SystemPromptFile; f != "" {
+		if _, err := os.Stat(f); err != nil {
+			fail("model.system_prompt_file: %v", err)
+		}
+	} else {
+		versions := promptVersions()
+		if i := sort.SearchStrings(versions, c.Model.PromptVersion); i == len(versions) || versions[i] != c.Model.PromptVersion {
+			fail("model.prompt_version must be one of %s, got %q", strings.Join(versions, ", "), c.Model.PromptVersion)
+		}
+	}
+
+	checkURL := func(field, v string, required bool) {
+		if v == "" {
+			if required {
+				fail("%s is required", field)
+			}
+			return
+		}
+		if u, err := url.Parse(v); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("%s must be an http(s) URL, got %q", field, v)
+		}
+	}
+	switch c.Liminal.Mode {
+	case "http", "record":
+		checkURL("liminal.base_url", c.Liminal.BaseURL, true)
+		if c.Liminal.Mode == "record" && c.Liminal.Cassette == "" {
+			fail("liminal.cassette is required when liminal.mode is record")
+		}
+	case "simulator":
+		if info, err := os.Stat(c.Liminal.SimulatorFixtures); err != nil || !info.IsDir() {
+			fail("liminal.simulator_fixtures must be a directory, got %q", c.Liminal.SimulatorFixtures)
+		}
+	case "replay":
+		if c.Liminal.Cassette == "" {
+			fail("liminal.cassette is required when liminal.mode is replay")
+		} else if _, err := os.Stat(c.Liminal.Cassette); err != nil {
+			fail("liminal.cassette: %v", err)
+		}
+	default:
+		fail("liminal.mode must be http, simulator, record or replay, got %q", c.Liminal.Mode)
+	}
+	checkURL("liminal.refresh_url", c.Liminal.RefreshURL, false)
+	checkURL("server.public_url", c.Server.PublicURL, false)
+
+	if c.Data.CSVPath == "" {
+		fail("data.csv_path is required")
+	}
+	mappings := make([]string, 0, len(c.Data.CSVMappings))
+	for name := range c.Data.CSVMappings {
+		mappings = append(mappings, name)
+	}
+	sort.Strings(mappings)
+	for _, name := range mappings {
+		if err := c.Data.CSVMappings[name].check(); err != nil {
+			fail("data.csv_mappings.%s: %v", name, err)
+		}
+	}
+	if p := c.Data.CSVPreset; p != "" {
+		_, builtin := csvPresets[p]
+		if _, custom := c.Data.CSVMappings[p]; !builtin && !custom {
+			fail("data.csv_preset must be a built-in preset (%s) or one of data.csv_mappings, got %q", strings.Join(csvPresetOrder, ", "), p)
+		}
+	}
+	if c.Data.TransactionLimit < 1 || c.Data.TransactionLimit > 1000 {
+		fail("data.transaction_limit must be between 1 and 1000, got %d", c.Data.TransactionLimit)
+	}
+	if d, err := time.ParseDuration(c.Data.Cache.MaxAge); err != nil || d < 0 {
+		fail("data.cache.max_age must be a duration like 2m (0 to always sync), got %q", c.Data.Cache.MaxAge)
+	}
+
+	if d, err := time.ParseDuration(c.Monitor.Interval); err != nil || d <= 0 {
+		fail("monitor.interval must be a positive duration like 5m, got %q", c.Monitor.Interval)
+	}
+
+	if c.Notifications.SMTP.Host != "" {
+		checkPort("notifications.smtp.port", c.Notifications.SMTP.Port)
+		if c.Notifications.SMTP.From == "" {
+			fail("notifications.smtp.from is required when notifications.smtp.host is set")
+		}
+	}
+	checkURL("notifications.push.relay_url", c.Notifications.Push.RelayURL, false)
+
+	seen := make(map[string]bool)
+	for _, name := range c.Tools.Disabled {
+		if seen[name] {
+			fail("tools.disabled lists %q twice", name)
+		}
+		seen[name] = true
+	}
+	flagged := make([]string, 0, len(c.Tools.Flags))
+	for name := range c.Tools.Flags {
+		flagged = append(flagged, name)
+	}
+	sort.Strings(flagged)
+	for _, name := range flagged {
+		flag := c.Tools.Flags[name]
+		if seen[name] {
+			fail("tools.flags.%s: tool is also in tools.disabled", name)
+		}
+		if flag.Percent < 0 || flag.Percent > 100 {
+			fail("tools.flags.%s.percent must be between 0 and 100, got %d", name, flag.Percent)
+		}
+		for _, cohort := range flag.Cohorts {
+			if _, ok := c.Tools.Cohorts[cohort]; !ok {
+				fail("tools.flags.%s: unknown cohort %q", name, cohort)
+			}
+		}
+	}
+
+	if c.Policy.MaxTransfer < 0 || c.Policy.LargeTransfer < 0 {
+		fail("policy limits cannot be negative")
+	}
+	if c.Policy.MaxTransfer > 0 && c.Policy.LargeTransfer > c.Policy.MaxTransfer {
+		fail("policy.large_transfer (%.2f) is above policy.max_transfer (%.2f)", c.Policy.LargeTransfer, c.Policy.MaxTransfer)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// masked returns a copy that is safe to print.
+func (c *Config) masked() Config {
+	m := *c
+	m.Model.AnthropicKey = maskSecret(c.Model.AnthropicKey)
+	m.Liminal.CredentialsKey = maskSecret(c.Liminal.CredentialsKey)
+	m.Notifications.SMTP.Password = maskSecret(c.Notifications.SMTP.Password)
+	m.Notifications.Push.Token = maskSecret(c.Notifications.Push.Token)
+	return m
+}
+
+// maskSecret keeps just enough of a secret to tell two apart.
+func maskSecret(s string) string {
+	switch {
+	case s == "":
+		return ""
+	case len(s) <= 8:
+		return "****"
+	}
+	return "****" + s[len(s)-4:]
+}
+
+// Settings the tools read directly; apply sets them from the config.
+var (
+	csvTransactionsPath   = "transactions.csv"
+	transactionFetchLimit = 100
+	configuredDataDir     string
+	exportBaseURL         = "http://localhost:8081"
+)
+
+// apply makes the config's data settings visible to the tools.
+func (c *Config) apply() {
+	csvTransactionsPath = c.Data.CSVPath
+	csvPreset = c.Data.CSVPreset
+	customCSVMappings = c.Data.CSVMappings
+	transactionFetchLimit = c.Data.TransactionLimit
+	configuredDataDir = c.Server.DataDir
+	exportBaseURL = firstNonEmpty(c.Server.PublicURL, "http://localhost:"+c.Server.APIPort)
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+	"github.com/becomeliminal/nim-go-sdk/tools"
+)
+
+// ============================================================================
+// COUNTERPARTY INSIGHTS
+// ============================================================================
+// Who the money goes to and comes from. analyzeTransactions works by
+// category; for P2P-heavy Liminal users the people matter more than the
+// categories: the roommate who pays back half the rent, the friend who owes
+// for dinner, the merchant whose bills doubled. Counterparties are matched
+// with normalizeCounterparty and counted per currency; @tags are Liminal
+// users, everything else is a merchant or a bank.
+
+// CounterpartyStats is the money exchanged with one counterparty in one
+// currency over the period.
+type CounterpartyStats struct {
+	Counterparty string    `json:"counterparty"`
+	Currency     string    `json:"currency"`
+	P2P          bool      `json:"p2p,omitempty"` // an @tag
+	Sent         float64   `json:"sent"`
+	Received     float64   `json:"received"`
+	Net          float64   `json:"net"` // received minus sent
+	Volume       float64   `json:"volume"`
+	Payments     int       `json:"payments"` // sends
+	Receipts     int       `json:"receipts"`
+	Category     string    `json:"category,omitempty"`
+	Last         time.Time `json:"last"`
+}
+
+// count is the number of transactions either way.
+func (s *CounterpartyStats) count() int { return s.Payments + s.Receipts }
+
+// ReciprocalRelationship is a counterparty money flows to and from
+// regularly - a roommate, a partner, a friend you take turns paying.
+type ReciprocalRelationship struct {
+	Counterparty string  `json:"counterparty"`
+	Currency     string  `json:"currency"`
+	Sent         float64 `json:"sent"`
+	Received     float64 `json:"received"`
+	Net          float64 `json:"net"`
+	Transactions int     `json:"transactions"`
+	Balance      float64 `json:"balance"` // smaller side over larger side, 1 = perfectly even
+	Pattern      string  `json:"pattern"` // even, you_pay_first or they_pay_first
+	Note         string  `json:"note"`
+}
+
+// VolumeChange is a counterparty whose volume moved sharply against the
+// previous period of the same length.
+type VolumeChange struct {
+	Counterparty string   `json:"counterparty"`
+	Currency     string   `json:"currency"`
+	Previous     float64  `json:"previous"`
+	Current      float64  `json:"current"`
+	ChangePct    *float64 `json:"change_pct,omitempty"` // nil for new counterparties
+	Direction    string   `json:"direction"`            // up, down, new or stopped
+	Note         string   `json:"note"`
+}
+
+// CounterpartyReport is the result of analyzeCounterparties.
+type CounterpartyReport struct {
+	PeriodDays     int                      `json:"period_days"`
+	From           time.Time                `json:"from"`
+	To             time.Time                `json:"to"`
+	Counterparties int                      `json:"counterparties"`
+	ByVolume       []CounterpartyStats      `json:"top_by_volume"`
+	ByFrequency    []CounterpartyStats      `json:"top_by_frequency"`
+	P2PNetFlow     []CounterpartyStats      `json:"p2p_net_flow"`
+	Reciprocal     []ReciprocalRelationship `json:"reciprocal"`
+	Changes        []VolumeChange           `json:"volume_changes"`
+	Compared       bool                     `json:"compared_with_previous_period"`
+	Note           string                   `json:"note,omitempty"`
+}
+
+const (
+	// counterpartyChangeRatio is how far volume must move (as a share of the
+	// previous period) to be flagged.
+	counterpartyChangeRatio = 0.5
+	// counterpartyChangeMin ignores changes smaller than this amount, so a
+	// $4 coffee becoming two is not news.
+	counterpartyChangeMin = 25.0
+	// reciprocalMinBalance is how even the flows must be for a merchant
+	// (not an @tag) to count as reciprocal rather than the odd refund.
+	reciprocalMinBalance = 0.25
+)
+
+// counterpartyStats totals the transactions in [from, until) per
+// counterparty and currency.
+func counterpartyStats(transactions []map[string]interface{}, from, until time.Time) map[string]*CounterpartyStats {
+	type named struct {
+		name string
+		at   time.Time
+	}
+	stats := make(map[string]*CounterpartyStats)
+	latest := make(map[string]named)
+	categories := make(map[string]map[string]int)
+	for _, tx := range transactions {
+		ts, ok := txTime(tx)
+		if !ok || ts.Before(from) || !ts.Before(until) || isSavingsTransfer(tx) {
+			continue
+		}
+		raw := strings.TrimSpace(txString(tx, "counterparty"))
+		if raw == "" {
+			continue
+		}
+		currency := strings.ToUpper(firstNonEmpty(txString(tx, "currency"), "USD"))
+		key := normalizeCounterparty(raw) + "\x00" + currency
+		s := stats[key]
+		if s == nil {
+			s = &CounterpartyStats{Currency: currency, P2P: strings.HasPrefix(raw, "@")}
+			stats[key] = s
+			categories[key] = make(map[string]int)
+		}
+		// Show the spelling the user saw most recently.
+		if n := latest[key]; ts.After(n.at) || n.name == "" {
+			latest[key] = named{raw, ts}
+		}
+		amount := txAmount(tx)
+		if txString(tx, "type") == "send" {
+			s.Sent += amount
+			s.Payments++
+		} else {
+			s.Received += amount
+			s.Receipts++
+		}
+		if c := txString(tx, "category"); c != "" {
+			categories[key][c]++
+		}
+		if ts.After(s.Last) {
+			s.Last = ts
+		}
+	}
+	for key, s := range stats {
+		s.Counterparty = latest[key].name
+		s.Sent, s.Received = round2(s.Sent), round2(s.Received)
+		s.Net = round2(s.Received - s.Sent)
+		s.Volume = round2(s.Sent + s.Received)
+		best := 0
+		for c, n := range categories[key] {
+			if n > best || (n == best && c < s.Category) {
+				s.Category, best = c, n
+			}
+		}
+	}
+	return stats
+}
+
+// analyzeCounterparties reports on the days up to until, ranking the top
+// limit counterparties and comparing with the days before when the history
+// reaches back that far.
+func analyzeCounterparties(transactions []map[string]interface{}, days, limit int, until time.Time) CounterpartyReport {
+	from := until.AddDate(0, 0, -days)
+	report := CounterpartyReport{PeriodDays: days, From: from, To: until}
+	current := counterpartyStats(transactions, from, until)
+	report.Counterparties = len(current)
+	if len(current) == 0 {
+		report.Note = fmt.Sprintf("No transactions with a counterparty in the last %d days", days)
+		return report
+	}
+
+	all := make([]CounterpartyStats, 0, len(current))
+	for _, s := range current {
+		all = append(all, *s)
+	}
+	top := func(less func(a, b CounterpartyStats) bool) []CounterpartyStats {
+		sorted := append([]CounterpartyStats(nil), all...)
+		sort.Slice(sorted, func(i, j int) bool {
+			if less(sorted[i], sorted[j]) {
+				return true
+			}
+			if less(sorted[j], sorted[i]) {
+				return false
+			}
+			return sorted[i].Counterparty < sorted[j].Counterparty
+		})
+		if len(sorted) > limit {
+			sorted = sorted[:limit]
+		}
+		return sorted
+	}
+	report.ByVolume = top(func(a, b CounterpartyStats) bool { return a.Volume > b.Volume })
+	report.ByFrequency = top(func(a, b CounterpartyStats) bool { return a.count() > b.count() })
+
+	report.P2PNetFlow = []CounterpartyStats{}
+	for _, s := range all {
+		if s.P2P {
+			report.P2PNetFlow = append(report.P2PNetFlow, s)
+		}
+	}
+	sort.Slice(report.P2PNetFlow, func(i, j int) bool {
+		a, b := report.P2PNetFlow[i], report.P2PNetFlow[j]
+		if math.Abs(a.Net) != math.Abs(b.Net) {
+			return math.Abs(a.Net) > math.Abs(b.Net)
+		}
+		return a.Counterparty < b.Counterparty
+	})
+
+	report.Reciprocal = []ReciprocalRelationship{}
+	for _, s := range all {
+		if r, ok := reciprocal(s); ok {
+			report.Reciprocal = append(report.Reciprocal, r)
+		}
+	}
+	sort.Slice(report.Reciprocal, func(i, j int) bool {
+		a, b := report.Reciprocal[i], report.Reciprocal[j]
+		if a.Transactions != b.Transactions {
+			return a.Transactions > b.Transactions
+		}
+		return a.Counterparty < b.Counterparty
+	})
+
+	// Volume changes need the whole previous period in the history.
+	report.Changes = []VolumeChange{}
+	previousFrom := from.AddDate(0, 0, -days)
+	earliest := until
+	for _, tx := range transactions {
+		if ts, ok := txTime(tx); ok && ts.Before(earliest) {
+			earliest = ts
+		}
+	}
+	if earliest.After(previousFrom.AddDate(0, 0, 1)) {
+		report.Note = fmt.Sprintf("History starts %s, too recent to compare with the %d days before", earliest.Format("2006-01-02"), days)
+		return report
+	}
+	report.Compared = true
+	report.Changes = volumeChanges(counterpartyStats(transactions, previousFrom, from), current, days)
+	return report
+}
+
+// reciprocal reports whether money flows both ways with s, and how.
+func reciprocal(s CounterpartyStats) (ReciprocalRelationship, bool) {
+	if s.Payments == 0 || s.Receipts == 0 || s.count() < 3 {
+		return ReciprocalRelationship{}, false
+	}
+	balance := math.Min(s.Sent, s.Received) / math.Max(s.Sent, s.Received)
+	// A merchant paying back once is a refund, not a relationship.
+	if !s.P2P && (s.Receipts < 2 || balance < reciprocalMinBalance) {
+		return ReciprocalRelationship{}, false
+	}
+	r := ReciprocalRelationship{
+		Counterparty: s.Counterparty,
+		Currency:     s.Currency,
+		Sent:         s.Sent,
+		Received:     s.Received,
+		Net:          s.Net,
+		Transactions: s.count(),
+		Balance:      round2(balance),
+	}
+	switch {
+	case balance >= 0.8:
+		r.Pattern = "even"
+		r.Note = fmt.Sprintf("You and %s pay each other about evenly (%s out, %s back) - sounds like shared costs with a roommate or partner", s.Counterparty, formatMoney(s.Sent, s.Currency), formatMoney(s.Received, s.Currency))
+	case s.Sent > s.Received:
+		r.Pattern = "you_pay_first"
+		r.Note = fmt.Sprintf("You usually pay and %s pays part back: %s out, %s back, %s still on your side", s.Counterparty, formatMoney(s.Sent, s.Currency), formatMoney(s.Received, s.Currency), formatMoney(s.Sent-s.Received, s.Currency))
+	default:
+		r.Pattern = "they_pay_first"
+		r.Note = fmt.Sprintf("%s usually pays and you pay part back: %s in, %s out, %s more received than sent", s.Counterparty, formatMoney(s.Received, s.Currency), formatMoney(s.Sent, s.Currency), formatMoney(s.Received-s.Sent, s.Currency))
+	}
+	return r, true
+}
+
+// volumeChanges compares two periods and keeps the sharp moves, biggest
+// first.
+func volumeChanges(previous, current map[string]*CounterpartyStats, days int) []VolumeChange {
+	keys := make(map[string]bool)
+	for k := range previous {
+		keys[k] = true
+	}
+	for k := range current {
+		keys[k] = true
+	}
+
+	changes := []VolumeChange{}
+	for key := range keys {
+		var before, now float64
+		name, currency := "", ""
+		if s := previous[key]; s != nil {
+			before, name, currency = s.Volume, s.Counterparty, s.Currency
+		}
+		if s := current[key]; s != nil {
+			now, name, currency = s.Volume, s.Counterparty, s.Currency
+		}
+		if math.Abs(now-before) < counterpartyChangeMin {
+			continue
+		}
+		c := VolumeChange{Counterparty: name, Currency: currency, Previous: before, Current: now}
+		switch {
+		case before == 0:
+			c.Direction = "new"
+			c.Note = fmt.Sprintf("New: %s with %s in the last %d days, nothing the %d days before", formatMoney(now, currency), name, days, days)
+		case now == 0:
+			c.Direction = "stopped"
+			c.Note = fmt.Sprintf("Stopped: nothing with %s in the last %d days, %s the %d days before", name, days, formatMoney(before, currency), days)
+		default:
+			pct := (now - before) / before
+			if math.Abs(pct) < counterpartyChangeRatio {
+				continue
+			}
+			rounded := math.Round(pct * 100)
+			c.ChangePct = &rounded
+			c.Direction = "up"
+			if pct < 0 {
+				c.Direction = "down"
+			}
+			c.Note = fmt.Sprintf("Volume with %s is %s %.0f%% on the %d days before (%s to %s)", name, c.Direction, math.Abs(rounded), days, formatMoney(before, currency), formatMoney(now, currency))
+		}
+		changes = append(changes, c)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := math.Abs(changes[i].Current-changes[i].Previous), math.Abs(changes[j].Current-changes[j].Previous)
+		if a != b {
+			return a > b
+		}
+		return changes[i].Counterparty < changes[j].Counterparty
+	})
+	return changes
+}
+
+// ============================================================================
+// CUSTOM TOOL: ANALYZE COUNTERPARTIES
+// ============================================================================
+
+func createCounterpartyAnalyzerTool(liminalExecutor core.ToolExecutor, statements *statementStore) core.Tool {
+	return tools.New("analyze_counterparties").
+		Description("Analyze who the user pays and who pays them: ranks people (@tags) and merchants by volume and by number of transactions, shows the net flow with each @tag, finds reciprocal relationships where money goes both ways (roommates, partners, friends splitting costs), and flags counterparties whose volume changed sharply against the previous period. Includes the bank statements the user imported.").
+		Schema(tools.ObjectSchema(map[string]interface{}{
+			"days":               tools.IntegerProperty("Number of days to analyze; changes compare with the same number of days before (default: 30)"),
+			"limit":              tools.IntegerProperty("Counterparties in each ranking (default: 10, max: 50)"),
+			"include_statements": tools.BooleanProperty("Include bank statements the user imported (default: true)"),
+			"refresh":            tools.BooleanProperty("Fetch the latest transactions from Liminal instead of the local cache (default: false)"),
+			"use_csv":            tools.BooleanProperty("Use local CSV file instead of API (for testing, default: false)"),
+		})).
+		Handler(func(ctx context.Context, toolParams *core.ToolParams) (*core.ToolResult, error) {
+			var params struct {
+				Days              int   `json:"days"`
+				Limit             int   `json:"limit"`
+				IncludeStatements *bool `json:"include_statements"`
+				Refresh           bool  `json:"refresh"`
+				UseCSV            bool  `json:"use_csv"`
+			}
+			if err := json.Unmarshal(toolParams.Input, &params); err != nil {
+				return &core.ToolResult{
+					Success: false,
+					Error:   fmt.Sprintf("invalid input: %v", err),
+				}, nil
+			}
+			if params.Days == 0 {
+				params.Days = 30
+			}
+			if params.Limit == 0 {
+				params.Limit = 10
+			}
+			if params.Days < 0 || params.Days > 365 {
+				return &core.ToolResult{Success: false, Error: "days must be between 1 and 365"}, nil
+			}
+			if params.Limit < 0 || params.Limit > 50 {
+				return &core.ToolResult{Success: false, Error: "limit must be between 1 and 50"}, nil
+			}
+			if params.Refresh {
+				ctx = withFreshTransactions(ctx)
+			}
+
+			transactions, err := loadToolTransactions(ctx, liminalExecutor, toolParams, params.UseCSV)
+			if err != nil {
+				return &core.ToolResult{Success: false, Error: err.Error()}, nil
+			}
+			now := time.Now()
+			statementCount := 0
+			if params.IncludeStatements == nil || *params.IncludeStatements {
+				transactions, statementCount = statements.withStatements(toolParams.UserID, transactions, now.AddDate(0, 0, -2*params.Days))
+			}
+			if params.UseCSV {
+				// Fixtures are not dated today; end at their newest transaction.
+				now = newestTransaction(transactions, now)
+			}
+
+			report := analyzeCounterparties(transactions, params.Days, params.Limit, now)
+			return &core.ToolResult{
+				Success: true,
+				Data: map[string]interface{}{
+					"analysis":    report,
+					"data_source": map[string]interface{}{"csv": params.UseCSV, "api": !params.UseCSV, "statements": statementCount},
+				},
+			}, nil
+		}).
+		Build()
+}
+
+// newestTransaction is the time just after the newest transaction, or
+// fallback when none has a time.
+func newestTransaction(transactions []map[string]interface{}, fallback time.Time) time.Time {
+	var newest time.Time
+	for _, tx := range transactions {
+		if ts, ok := txTime(tx); ok && ts.After(newest) {
+			newest = ts
+		}
+	}
+	if newest.IsZero() {
+		return fallback
+	}
+	return newest.Add(time.Second)
+}
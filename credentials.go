@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// STORED CREDENTIALS
+// ============================================================================
+// Interactive tool calls are authenticated by the JWT the SDK forwards from
+// the WebSocket session. Background work (the monitor) has no session, so
+// users who opt in hand us their token pair through the companion API. Tokens
+// are encrypted at rest with NEURAPAY_CREDENTIALS_KEY and refreshed through
+// LIMINAL_REFRESH_URL before they expire.
+
+// Credentials is a user's token pair for background access.
+type Credentials struct {
+	UserID       string    `json:"user_id"`
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// credentialRefreshWindow is how long before expiry tokens are refreshed.
+const credentialRefreshWindow = 5 * time.Minute
+
+var errNoCredentials = errors.New("no stored credentials")
+
+// credentialStore keeps encrypted credentials per user.
+type credentialStore struct {
+	store      *jsonStore
+	aead       cipher.AEAD
+	refreshURL string
+	client     *http.Client
+
+	mu    sync.Mutex
+	blobs map[string]string // user ID -> base64(nonce || ciphertext)
+}
+
+// newCredentialStore returns nil when no encryption key is configured; the
+// monitor then stays off rather than storing tokens in plain text.
+func newCredentialStore(key, refreshURL string) (*credentialStore, error) {
+	if key == "" {
+		return nil, nil
+	}
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to init credential cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init credential cipher: %w", err)
+	}
+
+	s := &credentialStore{
+		store:      newJSONStore("credentials.json"),
+		aead:       aead,
+		refreshURL: refreshURL,
+		client:     &http.Client{Timeout: 15 * time.Second},
+		blobs:      make(map[string]string),
+	}
+	if err := s.store.load(&s.blobs); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// put encrypts and stores credentials for a user.
+func (s *credentialStore) put(creds Credentials) error {
+	creds.UpdatedAt = time.Now()
+	plain, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := s.aead.Seal(nonce, nonce, plain, []byte(creds.UserID))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[creds.UserID] = base64.StdEncoding.EncodeToString(sealed)
+	return s.store.save(s.blobs)
+}
+
+// get decrypts the stored credentials for a user.
+func (s *credentialStore) get(userID string) (Credentials, error) {
+	s.mu.Lock()
+	blob, ok := s.blobs[userID]
+	s.mu.Unlock()
+	if !ok {
+		return Credentials{}, errNoCredentials
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(blob)
+	if err != nil || len(sealed) < s.aead.NonceSize() {
+		return Credentials{}, fmt.Errorf("corrupt credentials for %s", userID)
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	plain, err := s.aead.Open(nil, nonce, ciphertext, []byte(userID))
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to decrypt credentials for %s: %w", userID, err)
+	}
+
+	var creds Credentials
+	if err := json.Unmarshal(plain, &creds); err != nil {
+		return Credentials{}, err
+	}
+	return creds, nil
+}
+
+// remove forgets a user's credentials.
+func (s *credentialStore) remove(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.blobs, userID)
+	return s.store.save(s.blobs)
+}
+
+func (s *credentialStore) has(userID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.blobs[userID]
+	return ok
+}
+
+// fresh returns usable credentials, refreshing them first if they are about
+// to expire.
+func (s *credentialStore) fresh(ctx context.Context, userID string) (Credentials, error) {
+	creds, err := s.get(userID)
+	if err != nil {
+		return creds, err
+	}
+	if creds.ExpiresAt.IsZero() || time.Until(creds.ExpiresAt) > credentialRefreshWindow {
+		return creds, nil
+	}
+	if s.refreshURL == "" || creds.RefreshToken == "" {
+		if time.Now().After(creds.ExpiresAt) {
+			return creds, fmt.Errorf("credentials for %s expired and cannot be refreshed", userID)
+		}
+		return creds, nil
+	}
+
+	body, _ := json.Marshal(map[string]string{"refresh_token": creds.RefreshToken})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.refreshURL, bytes.NewReader(body))
+	if err != nil {
+		return creds, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return creds, fmt.Errorf("token refresh failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return creds, fmt.Errorf("token refresh failed: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var refreshed struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&refreshed); err != nil {
+		return creds, fmt.Errorf("failed to parse token refresh response: %w", err)
+	}
+	creds.AccessToken = refreshed.AccessToken
+	if refreshed.RefreshToken != "" {
+		creds.RefreshToken = refreshed.RefreshToken
+	}
+	creds.ExpiresAt = time.Now().Add(time.Duration(refreshed.ExpiresIn) * time.Second)
+	if err := s.put(creds); err != nil {
+		log.Printf("⚠️  Failed to save refreshed credentials for %s: %v", userID, err)
+	}
+	return creds, nil
+}
+
+// jwtSubject extracts the unverified "sub" and "exp" claims from a JWT. The
+// token itself is verified by Liminal when it is used.
+func jwtSubject(token string) (string, time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", time.Time{}, errors.New("malformed token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", time.Time{}, errors.New("malformed token payload")
+	}
+	var claims struct {
+		Sub string `json:"sub"`
+		Exp int64  `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", time.Time{}, errors.New("malformed token claims")
+	}
+	var exp time.Time
+	if claims.Exp > 0 {
+		exp = time.Unix(claims.Exp, 0)
+	}
+	return claims.Sub, exp, nil
+}
+
+// ----------------------------------------------------------------------------
+// Attaching credentials to background calls
+// ----------------------------------------------------------------------------
+
+type bearerTokenKey struct{}
+
+// withBearerToken marks ctx so Liminal requests made with it carry the
+// given access token.
+func withBearerToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, bearerTokenKey{}, token)
+}
+
+// liminalHTTPClient is the client the Liminal HTTP executor sends requests
+// with. Only it attaches stored tokens; every other client in the process
+// (notification webhooks, relays, token refresh) uses its own transport and
+// never sees them.
+func liminalHTTPClient() *http.Client {
+	return &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &bearerTransport{base: http.DefaultTransport},
+	}
+}
+
+// bearerTransport adds the token from the request context to requests that
+// are not already authenticated. Interactive calls already carry the session
+// JWT and pass through untouched.
+type bearerTransport struct {
+	base http.RoundTripper
+}
+
+func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, _ := req.Context().Value(bearerTokenKey{}).(string)
+	if token == "" || req.Header.Get("Authorization") != "" {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.base.RoundTrip(req)
+}
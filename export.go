@@ -0,0 +1,811 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+	"github.com/becomeliminal/nim-go-sdk/tools"
+)
+
+// ============================================================================
+// TRANSACTION EXPORT
+// ============================================================================
+// export_transactions (and `neurapay export`) write the user's transactions,
+// filtered, in one of five formats:
+//
+//   csv   the transactions.csv schema, so an export can be analyzed again
+//   json  the transactions plus the analysis: spending breakdown, money
+//         personality and budget status
+//   ofx   an OFX 2 statement per account and currency, for desktop finance
+//         apps (ofx.go reads it back)
+//   html  a monthly report rendered from reports/monthly.html.tmpl
+//   pdf   the same report as a PDF (pdf.go)
+//
+// Exports the tool writes are kept per user under <data_dir>/exports and
+// downloaded from the companion API through an unguessable link that expires
+// after exportTTL.
+
+//go:embed reports/*.tmpl
+var reportFiles embed.FS
+
+// exportContentTypes lists the formats and what they are served as.
+var exportContentTypes = map[string]string{
+	"csv":  "text/csv; charset=utf-8",
+	"json": "application/json",
+	"ofx":  "application/x-ofx",
+	"html": "text/html; charset=utf-8",
+	"pdf":  "application/pdf",
+}
+
+var exportFormats = []string{"csv", "json", "ofx", "html", "pdf"}
+
+// exportSections are the analyses the json, html and pdf formats include.
+var exportSections = []string{"spending", "personality", "budget"}
+
+// exportTTL is how long a download link works.
+const exportTTL = 7 * 24 * time.Hour
+
+// maxExportsPerUser bounds the files kept for one user; the oldest go first.
+const maxExportsPerUser = 20
+
+// ExportRequest is what to export and how.
+type ExportRequest struct {
+	Format  string
+	Filter  TransactionFilter
+	Month   string   // YYYY-MM; sets From and To
+	Include []string // analyses for json, html and pdf; default all
+}
+
+// Export is a rendered-to-be set of transactions with their analysis.
+type Export struct {
+	Format       string                   `json:"format"`
+	Period       string                   `json:"period"`
+	Month        string                   `json:"month,omitempty"`
+	Filter       TransactionFilter        `json:"filter"`
+	GeneratedAt  time.Time                `json:"generated_at"`
+	Count        int                      `json:"count"`
+	Analysis     *ExportAnalysis          `json:"analysis,omitempty"`
+	Transactions []map[string]interface{} `json:"transactions"`
+}
+
+// ExportAnalysis is the analysis of the exported transactions.
+type ExportAnalysis struct {
+	Spending    map[string]interface{} `json:"spending,omitempty"`
+	Personality *ExportPersonality     `json:"personality,omitempty"`
+	Budget      *BudgetStatus          `json:"budget,omitempty"`
+}
+
+// ExportPersonality is analyze_money_personality's result, or why there is
+// none.
+type ExportPersonality struct {
+	Archetype  string             `json:"archetype,omitempty"`
+	Confidence float64            `json:"confidence,omitempty"`
+	Scores     map[string]float64 `json:"scores,omitempty"`
+	Traits     []string           `json:"traits,omitempty"`
+	Strategies []string           `json:"strategies,omitempty"`
+	Note       string             `json:"note,omitempty"`
+}
+
+// CategorySpend is one line of the spending breakdown.
+type CategorySpend struct {
+	Category string  `json:"category"`
+	Amount   float64 `json:"amount"`
+	Count    int     `json:"count"`
+	Share    float64 `json:"share"` // percent of spending
+}
+
+// BudgetStatus sets the period's income against where it went: bills
+// (recurring payments), everyday spending and savings.
+type BudgetStatus struct {
+	Currency    string  `json:"currency"`
+	Income      float64 `json:"income"`
+	Bills       float64 `json:"bills"`
+	Spending    float64 `json:"spending"`
+	Saved       float64 `json:"saved"`
+	LeftOver    float64 `json:"left_over"`
+	SavingsRate float64 `json:"savings_rate"` // percent of income saved or left over
+	Status      string  `json:"status"`       // on_track, tight, overspent or no_income
+	Summary     string  `json:"summary"`
+}
+
+// buildExport selects and analyzes the transactions req asks for. history
+// is everything known about the user; recurring bills are detected in it.
+func buildExport(history []map[string]interface{}, req ExportRequest, now time.Time) (*Export, error) {
+	req.Format = strings.ToLower(firstNonEmpty(req.Format, "csv"))
+	if _, ok := exportContentTypes[req.Format]; !ok {
+		return nil, fmt.Errorf("format should be one of %s, got %q", strings.Join(exportFormats, ", "), req.Format)
+	}
+	include := make(map[string]bool)
+	for _, section := range req.Include {
+		section = strings.ToLower(strings.TrimSpace(section))
+		switch section {
+		case "":
+		case "spending", "personality", "budget":
+			include[section] = true
+		default:
+			return nil, fmt.Errorf("include should list %s, got %q", strings.Join(exportSections, ", "), section)
+		}
+	}
+	if len(include) == 0 {
+		for _, section := range exportSections {
+			include[section] = true
+		}
+	}
+
+	filter := req.Filter
+	report := req.Format == "html" || req.Format == "pdf"
+	if req.Month == "" && report && filter.From == "" && filter.To == "" {
+		// A report covers a month; the latest one with activity by default.
+		all, err := filter.apply(history)
+		if err != nil {
+			return nil, err
+		}
+		if len(all) == 0 {
+			return nil, errors.New("no transactions match the filters")
+		}
+		last, _ := txTime(all[len(all)-1])
+		req.Month = last.Format("2006-01")
+	}
+	if err := filter.setMonth(req.Month); err != nil {
+		return nil, err
+	}
+	transactions, err := filter.apply(history)
+	if err != nil {
+		return nil, err
+	}
+	if len(transactions) == 0 {
+		return nil, errors.New("no transactions match the filters")
+	}
+
+	e := &Export{
+		Format:       req.Format,
+		Period:       exportPeriod(filter, req.Month, transactions),
+		Month:        req.Month,
+		Filter:       filter,
+		GeneratedAt:  now,
+		Count:        len(transactions),
+		Transactions: transactions,
+	}
+	if req.Format == "csv" || req.Format == "ofx" {
+		return e, nil
+	}
+
+	e.Analysis = &ExportAnalysis{}
+	if include["spending"] {
+		spending := analyzeTransactions(transactions, exportDays(filter, transactions))
+		spending["categories"] = categoryBreakdown(transactions)
+		e.Analysis.Spending = spending
+	}
+	if include["personality"] {
+		personality := &ExportPersonality{}
+		if len(transactions) < minPersonalityTransactions {
+			personality.Note = fmt.Sprintf("Needs at least %d transactions, the export has %d", minPersonalityTransactions, len(transactions))
+		} else {
+			scores := calculatePersonalityScores(transactions)
+			archetype := matchArchetype(scores)
+			personality.Archetype = archetype.Type
+			personality.Confidence = round2(archetype.Confidence)
+			personality.Scores = scores
+			personality.Traits = archetype.Traits
+			personality.Strategies = archetype.Strategies
+		}
+		e.Analysis.Personality = personality
+	}
+	if include["budget"] {
+		budget := computeBudgetStatus(transactions, history)
+		e.Analysis.Budget = &budget
+	}
+	return e, nil
+}
+
+// exportPeriod names the period an export covers.
+func exportPeriod(filter TransactionFilter, month string, transactions []map[string]interface{}) string {
+	if month != "" {
+		start, _ := time.Parse("2006-01", month)
+		return start.Format("January 2006")
+	}
+	from, to := filter.From, filter.To
+	if from == "" {
+		first, _ := txTime(transactions[0])
+		from = first.Format("2006-01-02")
+	}
+	if to == "" {
+		last, _ := txTime(transactions[len(transactions)-1])
+		to = last.Format("2006-01-02")
+	}
+	return from + " to " + to
+}
+
+// exportDays is how many days the period covers, for daily averages.
+func exportDays(filter TransactionFilter, transactions []map[string]interface{}) int {
+	from, until, _ := filter.bounds()
+	if from.IsZero() {
+		from, _ = txTime(transactions[0])
+	}
+	if until.IsZero() {
+		last, _ := txTime(transactions[len(transactions)-1])
+		until = last.AddDate(0, 0, 1)
+	}
+	days := int(math.Ceil(until.Sub(from).Hours() / 24))
+	if days < 1 {
+		days = 1
+	}
+	return days
+}
+
+// categoryBreakdown totals spending by category, largest first.
+func categoryBreakdown(transactions []map[string]interface{}) []CategorySpend {
+	byCategory := make(map[string]*CategorySpend)
+	total := 0.0
+	for _, tx := range transactions {
+		if txString(tx, "type") != "send" {
+			continue
+		}
+		name := firstNonEmpty(txString(tx, "category"), "uncategorized")
+		c := byCategory[name]
+		if c == nil {
+			c = &CategorySpend{Category: name}
+			byCategory[name] = c
+		}
+		c.Amount += txAmount(tx)
+		c.Count++
+		total += txAmount(tx)
+	}
+	out := make([]CategorySpend, 0, len(byCategory))
+	for _, c := range byCategory {
+		c.Amount = round2(c.Amount)
+		if total > 0 {
+			c.Share = math.Round(c.Amount/total*1000) / 10
+		}
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Amount != out[j].Amount {
+			return out[i].Amount > out[j].Amount
+		}
+		return out[i].Category < out[j].Category
+	})
+	return out
+}
+
+// computeBudgetStatus works out the budget in the period's main currency.
+// Payments to counterparties detectRecurring finds in history count as
+// bills, transfers to savings as saved and the rest as spending.
+func computeBudgetStatus(transactions, history []map[string]interface{}) BudgetStatus {
+	currency := mainCurrency(transactions)
+	bills := make(map[string]bool)
+	for _, series := range detectRecurring(history, "send") {
+		bills[normalizeCounterparty(series.Counterparty)] = true
+	}
+
+	b := BudgetStatus{Currency: currency}
+	for _, tx := range transactions {
+		if !strings.EqualFold(firstNonEmpty(txString(tx, "currency"), "USD"), currency) {
+			continue
+		}
+		amount := txAmount(tx)
+		switch {
+		case txString(tx, "type") == "receive":
+			b.Income += amount
+		case isSavingsTransfer(tx):
+			b.Saved += amount
+		case bills[normalizeCounterparty(txString(tx, "counterparty"))]:
+			b.Bills += amount
+		default:
+			b.Spending += amount
+		}
+	}
+	b.Income, b.Bills, b.Spending, b.Saved = round2(b.Income), round2(b.Bills), round2(b.Spending), round2(b.Saved)
+	b.LeftOver = round2(b.Income - b.Bills - b.Spending - b.Saved)
+
+	switch {
+	case b.Income == 0:
+		b.Status = "no_income"
+		b.Summary = fmt.Sprintf("No income this period; %s went out.", formatMoney(b.Bills+b.Spending+b.Saved, currency))
+		return b
+	case b.LeftOver < 0:
+		b.Status = "overspent"
+		b.Summary = fmt.Sprintf("Spent %s more than came in.", formatMoney(-b.LeftOver, currency))
+	default:
+		b.Status = "on_track"
+		b.Summary = fmt.Sprintf("Kept %s of %s income.", formatMoney(b.LeftOver+b.Saved, currency), formatMoney(b.Income, currency))
+	}
+	b.SavingsRate = math.Round((b.LeftOver+b.Saved)/b.Income*1000) / 10
+	if b.Status == "on_track" && b.SavingsRate < 10 {
+		b.Status = "tight"
+	}
+	return b
+}
+
+// mainCurrency is the currency most of the transactions are in.
+func mainCurrency(transactions []map[string]interface{}) string {
+	counts := make(map[string]int)
+	best := "USD"
+	for _, tx := range transactions {
+		c := strings.ToUpper(firstNonEmpty(txString(tx, "currency"), "USD"))
+		counts[c]++
+		if counts[c] > counts[best] || (counts[c] == counts[best] && c < best) {
+			best = c
+		}
+	}
+	return best
+}
+
+// currencySymbols are the currencies shown with a symbol rather than a code.
+var currencySymbols = map[string]string{"USD": "$", "EUR": "€", "GBP": "£"}
+
+// formatMoney formats an amount for people: $1,234.50 or 1,234.50 CHF.
+func formatMoney(amount float64, currency string) string {
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	s := fmt.Sprintf("%.2f", amount)
+	whole, cents := s[:len(s)-3], s[len(s)-3:]
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+	if symbol, ok := currencySymbols[strings.ToUpper(currency)]; ok {
+		return sign + symbol + whole + cents
+	}
+	return sign + whole + cents + " " + strings.ToUpper(currency)
+}
+
+// fileName is what the export is saved and downloaded as.
+func (e *Export) fileName() string {
+	period := "all"
+	switch {
+	case e.Month != "":
+		period = e.Month
+	case e.Filter.From != "" || e.Filter.To != "":
+		period = firstNonEmpty(e.Filter.From, "start") + "_" + firstNonEmpty(e.Filter.To, "today")
+	}
+	return fmt.Sprintf("neurapay-%s.%s", period, e.Format)
+}
+
+// write renders the export in its format.
+func (e *Export) write(w io.Writer) error {
+	switch e.Format {
+	case "csv":
+		return writeTransactionsCSV(w, e.Transactions)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(e)
+	case "ofx":
+		return writeOFX(w, e.Transactions, e.GeneratedAt)
+	case "html":
+		return reportTemplate.ExecuteTemplate(w, "monthly.html.tmpl", e.report())
+	case "pdf":
+		return writeReportPDF(w, e.report())
+	}
+	return fmt.Errorf("unknown export format %q", e.Format)
+}
+
+// ============================================================================
+// OFX WRITER
+// ============================================================================
+
+// ofxTypes is ofxCategories the other way round.
+var ofxTypes = map[string]string{"interest": "INT", "fees": "FEE", "cash": "ATM"}
+
+var ofxEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// writeOFX writes an OFX 2.1.1 response with one statement per account and
+// currency. Transactions without an account are filed under NEURAPAY.
+func writeOFX(w io.Writer, transactions []map[string]interface{}, now time.Time) error {
+	type statement struct {
+		account, currency string
+		transactions      []map[string]interface{}
+	}
+	var statements []*statement
+	byKey := make(map[string]*statement)
+	for _, tx := range transactions {
+		account := firstNonEmpty(txString(tx, "account"), "NEURAPAY")
+		currency := strings.ToUpper(firstNonEmpty(txString(tx, "currency"), "USD"))
+		key := account + "\x00" + currency
+		if byKey[key] == nil {
+			byKey[key] = &statement{account: account, currency: currency}
+			statements = append(statements, byKey[key])
+		}
+		byKey[key].transactions = append(byKey[key].transactions, tx)
+	}
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="no"?>` + "\n")
+	b.WriteString(`<?OFX OFXHEADER="200" VERSION="211" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>` + "\n")
+	b.WriteString("<OFX>\n<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>")
+	fmt.Fprintf(&b, "<DTSERVER>%s</DTSERVER><LANGUAGE>ENG</LANGUAGE></SONRS></SIGNONMSGSRSV1>\n", ofxDate(now))
+	b.WriteString("<BANKMSGSRSV1>\n")
+	for i, s := range statements {
+		fmt.Fprintf(&b, "<STMTTRNRS><TRNUID>%d</TRNUID><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>\n", i+1)
+		fmt.Fprintf(&b, "<STMTRS><CURDEF>%s</CURDEF>\n", ofxEscaper.Replace(s.currency))
+		fmt.Fprintf(&b, "<BANKACCTFROM><BANKID>NEURAPAY</BANKID><ACCTID>%s</ACCTID><ACCTTYPE>CHECKING</ACCTTYPE></BANKACCTFROM>\n", ofxEscaper.Replace(truncate(s.account, 22)))
+		first, _ := txTime(s.transactions[0])
+		last, _ := txTime(s.transactions[len(s.transactions)-1])
+		fmt.Fprintf(&b, "<BANKTRANLIST><DTSTART>%s</DTSTART><DTEND>%s</DTEND>\n", ofxDate(first), ofxDate(last))
+
+		// FITIDs must be unique in the account; identical transactions get
+		// a counter so an importer keeps both.
+		seen := make(map[string]int)
+		var balance string
+		for _, tx := range s.transactions {
+			sum := sha256.Sum256([]byte(txID(tx)))
+			fitID := hex.EncodeToString(sum[:8])
+			if seen[fitID]++; seen[fitID] > 1 {
+				fitID = fmt.Sprintf("%s-%d", fitID, seen[fitID])
+			}
+			amount := txAmount(tx)
+			trnType := "CREDIT"
+			if txString(tx, "type") == "send" {
+				amount, trnType = -amount, "DEBIT"
+			}
+			if t, ok := ofxTypes[txString(tx, "category")]; ok {
+				trnType = t
+			}
+			ts, _ := txTime(tx)
+			b.WriteString("<STMTTRN>")
+			fmt.Fprintf(&b, "<TRNTYPE>%s</TRNTYPE><DTPOSTED>%s</DTPOSTED><TRNAMT>%.2f</TRNAMT><FITID>%s</FITID>", trnType, ofxDate(ts), amount, fitID)
+			if name := txString(tx, "counterparty"); name != "" {
+				fmt.Fprintf(&b, "<NAME>%s</NAME>", ofxEscaper.Replace(truncate(name, 32)))
+			}
+			if memo := txString(tx, "description"); memo != "" {
+				fmt.Fprintf(&b, "<MEMO>%s</MEMO>", ofxEscaper.Replace(truncate(memo, 255)))
+			}
+			b.WriteString("</STMTTRN>\n")
+			if v, ok := numberValue(tx["balance_after"]); ok {
+				balance = fmt.Sprintf("<LEDGERBAL><BALAMT>%.2f</BALAMT><DTASOF>%s</DTASOF></LEDGERBAL>\n", v, ofxDate(ts))
+			}
+		}
+		b.WriteString("</BANKTRANLIST>\n")
+		b.WriteString(balance)
+		b.WriteString("</STMTRS></STMTTRNRS>\n")
+	}
+	b.WriteString("</BANKMSGSRSV1>\n</OFX>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ofxDate formats a time the way parseOFXDate reads it.
+func ofxDate(t time.Time) string {
+	_, offset := t.Zone()
+	return fmt.Sprintf("%s[%+g]", t.Format("20060102150405"), float64(offset)/3600)
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
+
+// ============================================================================
+// MONTHLY REPORT
+// ============================================================================
+// The html and pdf formats share one view of the export.
+
+var reportTemplate = template.Must(template.New("reports").Funcs(template.FuncMap{
+	"money": formatMoney,
+}).ParseFS(reportFiles, "reports/*.tmpl"))
+
+// reportView is the export laid out for the report.
+type reportView struct {
+	Title        string
+	Period       string
+	GeneratedAt  string
+	Currency     string
+	Filters      []string
+	Figures      []reportFigure
+	Categories   []CategorySpend
+	Personality  *ExportPersonality
+	Budget       *BudgetStatus
+	Transactions []reportRow
+}
+
+type reportFigure struct {
+	Label string
+	Value string
+}
+
+type reportRow struct {
+	Date         string
+	Counterparty string
+	Description  string
+	Category     string
+	Amount       string // signed, with currency
+	Out          bool
+}
+
+// report lays the export out for the html and pdf formats.
+func (e *Export) report() reportView {
+	v := reportView{
+		Title:       "NeuraPay report - " + e.Period,
+		Period:      e.Period,
+		GeneratedAt: e.GeneratedAt.Format("2 January 2006 15:04 MST"),
+		Currency:    mainCurrency(e.Transactions),
+	}
+	f := e.Filter
+	if f.Type != "" {
+		v.Filters = append(v.Filters, "type "+f.Type)
+	}
+	if f.Category != "" {
+		v.Filters = append(v.Filters, "category "+f.Category)
+	}
+	if f.Counterparty != "" {
+		v.Filters = append(v.Filters, "counterparty contains \""+f.Counterparty+"\"")
+	}
+	if f.MinAmount > 0 {
+		v.Filters = append(v.Filters, "at least "+formatMoney(f.MinAmount, v.Currency))
+	}
+	if f.MaxAmount > 0 {
+		v.Filters = append(v.Filters, "at most "+formatMoney(f.MaxAmount, v.Currency))
+	}
+
+	var in, out float64
+	for _, tx := range e.Transactions {
+		if !strings.EqualFold(firstNonEmpty(txString(tx, "currency"), "USD"), v.Currency) {
+			continue
+		}
+		if txString(tx, "type") == "send" {
+			out += txAmount(tx)
+		} else {
+			in += txAmount(tx)
+		}
+	}
+	v.Figures = []reportFigure{
+		{"Money in", formatMoney(in, v.Currency)},
+		{"Money out", formatMoney(out, v.Currency)},
+		{"Net", formatMoney(in-out, v.Currency)},
+		{"Transactions", fmt.Sprint(len(e.Transactions))},
+	}
+	if a := e.Analysis; a != nil {
+		if a.Spending != nil {
+			v.Categories, _ = a.Spending["categories"].([]CategorySpend)
+		}
+		v.Personality = a.Personality
+		v.Budget = a.Budget
+	}
+
+	for _, tx := range e.Transactions {
+		ts, _ := txTime(tx)
+		amount := txAmount(tx)
+		outgoing := txString(tx, "type") == "send"
+		if outgoing {
+			amount = -amount
+		}
+		v.Transactions = append(v.Transactions, reportRow{
+			Date:         ts.Format("2006-01-02"),
+			Counterparty: txString(tx, "counterparty"),
+			Description:  txString(tx, "description"),
+			Category:     txString(tx, "category"),
+			Amount:       formatMoney(amount, firstNonEmpty(txString(tx, "currency"), "USD")),
+			Out:          outgoing,
+		})
+	}
+	return v
+}
+
+// ============================================================================
+// EXPORT STORE
+// ============================================================================
+// Each user's exports live in their own directory under <data_dir>/exports;
+// exports.json indexes them by download ID.
+
+// ExportFile is a saved export.
+type ExportFile struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Format       string    `json:"format"`
+	Period       string    `json:"period"`
+	Transactions int       `json:"transactions"`
+	Size         int       `json:"size"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+type exportStore struct {
+	store *jsonStore
+	dir   string
+
+	mu    sync.Mutex
+	users map[string][]ExportFile
+}
+
+func newExportStore() *exportStore {
+	s := &exportStore{
+		store: newJSONStore("exports.json"),
+		dir:   filepath.Join(dataDir(), "exports"),
+		users: make(map[string][]ExportFile),
+	}
+	if err := s.store.load(&s.users); err != nil {
+		log.Printf("⚠️  Exports not loaded: %v", err)
+	}
+	return s
+}
+
+// userDir is where a user's exports are written. User IDs are sanitized so
+// one can't point outside the exports directory.
+func (s *exportStore) userDir(userID string) string {
+	safe := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, userID)
+	return filepath.Join(s.dir, safe)
+}
+
+func (s *exportStore) path(userID string, f ExportFile) string {
+	return filepath.Join(s.userDir(userID), f.ID+"-"+f.Name)
+}
+
+// save renders the export into the user's directory. Expired exports, and
+// the oldest beyond maxExportsPerUser, are deleted on the way.
+func (s *exportStore) save(userID string, e *Export) (ExportFile, error) {
+	var buf bytes.Buffer
+	if err := e.write(&buf); err != nil {
+		return ExportFile{}, fmt.Errorf("failed to render export: %w", err)
+	}
+	var token [16]byte
+	if _, err := rand.Read(token[:]); err != nil {
+		return ExportFile{}, err
+	}
+	now := time.Now()
+	file := ExportFile{
+		ID:           hex.EncodeToString(token[:]),
+		Name:         e.fileName(),
+		Format:       e.Format,
+		Period:       e.Period,
+		Transactions: e.Count,
+		Size:         buf.Len(),
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(exportTTL),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.userDir(userID), 0o700); err != nil {
+		return ExportFile{}, fmt.Errorf("failed to create export dir: %w", err)
+	}
+	if err := os.WriteFile(s.path(userID, file), buf.Bytes(), 0o600); err != nil {
+		return ExportFile{}, fmt.Errorf("failed to write export: %w", err)
+	}
+
+	var kept []ExportFile
+	for _, f := range append(s.users[userID], file) {
+		if now.After(f.ExpiresAt) {
+			os.Remove(s.path(userID, f))
+			continue
+		}
+		kept = append(kept, f)
+	}
+	for len(kept) > maxExportsPerUser {
+		os.Remove(s.path(userID, kept[0]))
+		kept = kept[1:]
+	}
+	s.users[userID] = kept
+	if err := s.store.save(s.users); err != nil {
+		return ExportFile{}, err
+	}
+	return file, nil
+}
+
+// lookup finds an export by download ID, returning the file it's in.
+func (s *exportStore) lookup(id string) (ExportFile, string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for userID, files := range s.users {
+		for _, f := range files {
+			if f.ID == id {
+				return f, s.path(userID, f), true
+			}
+		}
+	}
+	return ExportFile{}, "", false
+}
+
+// exportURL is the download link for an export.
+func exportURL(id string) string {
+	return strings.TrimRight(exportBaseURL, "/") + "/v1/exports/" + id
+}
+
+// ============================================================================
+// CUSTOM TOOL: EXPORT TRANSACTIONS
+// ============================================================================
+
+func createExportTool(liminalExecutor core.ToolExecutor, statements *statementStore, exports *exportStore) core.Tool {
+	return tools.New("export_transactions").
+		Description("Export the user's transactions to a file they can download: csv, json (with spending breakdown, money personality and budget status), ofx (for Quicken and other finance apps), or a monthly report as html or pdf. Filter by dates, type, category, counterparty and amount. Returns a download link that works for 7 days.").
+		Schema(tools.ObjectSchema(map[string]interface{}{
+			"format":             tools.StringProperty("csv, json, ofx, html or pdf (default: csv)"),
+			"month":              tools.StringProperty("Calendar month to export, YYYY-MM (html and pdf reports default to the latest month)"),
+			"from":               tools.StringProperty("First day to include, YYYY-MM-DD"),
+			"to":                 tools.StringProperty("Last day to include, YYYY-MM-DD"),
+			"type":               tools.StringProperty("Only send or receive transactions"),
+			"category":           tools.StringProperty("Only this category"),
+			"counterparty":       tools.StringProperty("Only counterparties containing this text"),
+			"min_amount":         tools.NumberProperty("Only transactions of at least this amount"),
+			"max_amount":         tools.NumberProperty("Only transactions of at most this amount"),
+			"include":            tools.StringProperty("Comma-separated analyses for json, html and pdf: spending, personality, budget (default: all)"),
+			"include_statements": tools.BooleanProperty("Include bank statements the user imported (default: true)"),
+			"refresh":            tools.BooleanProperty("Fetch the latest transactions from Liminal instead of the local cache (default: false)"),
+			"use_csv":            tools.BooleanProperty("Use local CSV file instead of API (for testing, default: false)"),
+		})).
+		Handler(func(ctx context.Context, toolParams *core.ToolParams) (*core.ToolResult, error) {
+			var params struct {
+				TransactionFilter
+				Format            string `json:"format"`
+				Month             string `json:"month"`
+				Include           string `json:"include"`
+				IncludeStatements *bool  `json:"include_statements"`
+				Refresh           bool   `json:"refresh"`
+				UseCSV            bool   `json:"use_csv"`
+			}
+			if err := json.Unmarshal(toolParams.Input, &params); err != nil {
+				return &core.ToolResult{
+					Success: false,
+					Error:   fmt.Sprintf("invalid input: %v", err),
+				}, nil
+			}
+
+			if params.Refresh {
+				ctx = withFreshTransactions(ctx)
+			}
+			transactions, err := loadToolTransactions(ctx, liminalExecutor, toolParams, params.UseCSV)
+			if err != nil {
+				return &core.ToolResult{Success: false, Error: err.Error()}, nil
+			}
+			statementCount := 0
+			if params.IncludeStatements == nil || *params.IncludeStatements {
+				transactions, statementCount = statements.withStatements(toolParams.UserID, transactions, time.Time{})
+			}
+
+			export, err := buildExport(transactions, ExportRequest{
+				Format:  params.Format,
+				Filter:  params.TransactionFilter,
+				Month:   params.Month,
+				Include: strings.Split(params.Include, ","),
+			}, time.Now())
+			if err != nil {
+				return &core.ToolResult{Success: false, Error: err.Error()}, nil
+			}
+			file, err := exports.save(toolParams.UserID, export)
+			if err != nil {
+				log.Printf("⚠️  Failed to save export for %s: %v", toolParams.UserID, err)
+				return &core.ToolResult{Success: false, Error: "failed to save the export"}, nil
+			}
+			log.Printf("📦 Exported %d transactions for %s as %s", file.Transactions, toolParams.UserID, file.Name)
+
+			result := map[string]interface{}{
+				"export":       file,
+				"download_url": exportURL(file.ID),
+				"summary":      fmt.Sprintf("%s with %d transactions (%s). The link works until %s.", file.Name, file.Transactions, file.Period, file.ExpiresAt.Format("2 January")),
+				"data_source":  map[string]interface{}{"csv": params.UseCSV, "api": !params.UseCSV, "statements": statementCount},
+			}
+			if a := export.Analysis; a != nil && a.Budget != nil {
+				result["budget"] = a.Budget
+			}
+			return &core.ToolResult{Success: true, Data: result}, nil
+		}).
+		Build()
+}
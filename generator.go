@@ -0,0 +1,333 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// SYNTHETIC TRANSACTION GENERATOR
+// ============================================================================
+// Produces realistic transaction histories for a money personality, so
+// calculatePersonalityScores and matchArchetype can be exercised without
+// hand-crafted CSVs. Output uses the transactions.csv schema that
+// loadTransactionsFromCSV reads. The same config and seed always produce the
+// same history.
+//
+// Paydays and spending cadences run in real time over the whole window, so a
+// year of biweekly pay has 26 paychecks, not one month's worth stretched out.
+
+// GeneratorConfig selects what to generate.
+type GeneratorConfig struct {
+	Archetype string    // key of personaProfiles, e.g. "reward_seeker"
+	Income    string    // weekly, biweekly, monthly or irregular (default: the persona's)
+	Currency  string    // default: USD
+	Days      int       // default: 30
+	Seed      int64     // default: 1
+	End       time.Time // last day of history (default: today)
+}
+
+// purchaseKind is one kind of recurring spend in a persona.
+type purchaseKind struct {
+	category     string
+	merchants    []string
+	description  string
+	perWeek      float64 // random, on average
+	every        int     // or: fixed cadence in days, from a random first day
+	min, max     float64
+	afterPayday  bool // only in the days right after income lands
+	requireFunds bool // skipped rather than overdrawing
+}
+
+// personaProfile describes how one archetype earns, spends and saves.
+type personaProfile struct {
+	archetype     string  // matchArchetype type name
+	income        string  // default income pattern
+	monthlyIncome float64 // paid out per the income pattern
+	opening       float64 // opening wallet balance
+	purchases     []purchaseKind
+}
+
+// personaProfiles are the generator's personas, keyed by CLI name.
+var personaProfiles = map[string]personaProfile{
+	"reward_seeker": {
+		archetype:     "The Reward Seeker",
+		income:        "biweekly",
+		monthlyIncome: 4200,
+		opening:       3000,
+		purchases: []purchaseKind{
+			{category: "dining", merchants: []string{"The Local", "Sushi Go", "Taco Loco", "Brunch Club"}, description: "Treat yourself", perWeek: 5, min: 18, max: 95},
+			{category: "entertainment", merchants: []string{"Cinema City", "Concert Hall", "Arcade Bar"}, description: "Night out", perWeek: 2, min: 25, max: 160},
+			{category: "shopping", merchants: []string{"Urban Threads", "Gadget Hub", "Sneaker Spot"}, description: "Payday haul", perWeek: 3, min: 40, max: 280, afterPayday: true},
+			{category: "coffee", merchants: []string{"Blue Bottle", "Corner Cafe"}, description: "Coffee", perWeek: 4, min: 4, max: 9},
+		},
+	},
+	"safety_hoarder": {
+		archetype:     "The Safety Hoarder",
+		income:        "monthly",
+		monthlyIncome: 3800,
+		opening:       15000,
+		purchases: []purchaseKind{
+			{category: "groceries", merchants: []string{"FreshMart", "Budget Foods"}, description: "Groceries", every: 5, min: 35, max: 140},
+			{category: "utilities", merchants: []string{"City Power", "Water Co"}, description: "Utility bill", perWeek: 0.25, min: 60, max: 380},
+			{category: "shopping", merchants: []string{"Hardware Depot"}, description: "Household", perWeek: 0.25, min: 12, max: 90},
+			{category: "shopping", merchants: []string{"Appliance World"}, description: "Long-planned purchase", perWeek: 0.1, min: 300, max: 900},
+			{category: "savings", merchants: []string{"Savings Vault"}, description: "Transfer to savings", every: 7, min: 450, max: 700, requireFunds: true},
+		},
+	},
+	"impulse_optimizer": {
+		archetype:     "The Impulse Optimizer",
+		income:        "biweekly",
+		monthlyIncome: 3600,
+		opening:       900,
+		purchases: []purchaseKind{
+			{category: "coffee", merchants: []string{"Blue Bottle", "Corner Cafe", "Bean Machine"}, description: "Coffee run", perWeek: 7, min: 7, max: 11},
+			{category: "food", merchants: []string{"QuickBite", "Deli Express", "Noodle Bar"}, description: "Grab and go", perWeek: 6, min: 8, max: 13},
+			{category: "transport", merchants: []string{"RideNow", "ScootShare"}, description: "Ride", perWeek: 4, min: 8, max: 12},
+			{category: "shopping", merchants: []string{"OneClick Store"}, description: "One-click order", perWeek: 2, min: 9, max: 14},
+		},
+	},
+	"cyclical_spender": {
+		archetype:     "The Cyclical Spender",
+		income:        "monthly",
+		monthlyIncome: 3400,
+		opening:       220,
+		purchases: []purchaseKind{
+			{category: "shopping", merchants: []string{"Luxe Outlet", "Gadget Hub", "Travel Deals"}, description: "Spree", perWeek: 4, min: 250, max: 950, afterPayday: true, requireFunds: true},
+			{category: "dining", merchants: []string{"Steakhouse 21", "Rooftop Bar"}, description: "Celebration", perWeek: 2, min: 80, max: 260, afterPayday: true, requireFunds: true},
+			{category: "groceries", merchants: []string{"FreshMart"}, description: "Groceries", every: 4, min: 12, max: 45, requireFunds: true},
+			{category: "transport", merchants: []string{"Metro"}, description: "Transit", every: 5, min: 3, max: 8, requireFunds: true},
+		},
+	},
+	"strategic_planner": {
+		archetype:     "The Strategic Planner",
+		income:        "biweekly",
+		monthlyIncome: 5200,
+		opening:       6400,
+		purchases: []purchaseKind{
+			{category: "groceries", merchants: []string{"FreshMart", "Farmers Market"}, description: "Weekly budget: groceries", perWeek: 1, min: 58, max: 66},
+			{category: "household", merchants: []string{"Home Basics"}, description: "Weekly budget: household", perWeek: 0.5, min: 58, max: 66},
+			{category: "transport", merchants: []string{"Metro Pass", "Fuel Stop"}, description: "Weekly budget: transport", perWeek: 1, min: 58, max: 66},
+			{category: "savings", merchants: []string{"Savings Vault"}, description: "Scheduled savings transfer", every: 4, min: 60, max: 60},
+		},
+	},
+}
+
+// personaNames returns the persona keys in a stable order.
+func personaNames() []string {
+	names := make([]string, 0, len(personaProfiles))
+	for name := range personaProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// incomePaychecks is how many paychecks a year each pattern pays; monthly
+// pay lands on the same day of every month.
+var incomePaychecks = map[string]int{
+	"weekly":   52,
+	"biweekly": 26,
+	"monthly":  12,
+}
+
+// nextPayday returns the payday after day for a fixed income pattern.
+func nextPayday(income string, day time.Time) time.Time {
+	if income == "monthly" {
+		return day.AddDate(0, 1, 0)
+	}
+	return day.AddDate(0, 0, 365/incomePaychecks[income])
+}
+
+// generateTransactions builds a history for cfg, oldest first, with
+// balance_after kept consistent and never negative.
+func generateTransactions(cfg GeneratorConfig) ([]map[string]interface{}, error) {
+	profile, ok := personaProfiles[cfg.Archetype]
+	if !ok {
+		return nil, fmt.Errorf("unknown archetype %q (want one of: %s)", cfg.Archetype, strings.Join(personaNames(), ", "))
+	}
+	income := cfg.Income
+	if income == "" {
+		income = profile.income
+	}
+	if _, ok := incomePaychecks[income]; !ok && income != "irregular" {
+		return nil, fmt.Errorf("unknown income pattern %q (want weekly, biweekly, monthly or irregular)", income)
+	}
+	currency := strings.ToUpper(cfg.Currency)
+	if currency == "" {
+		currency = "USD"
+	}
+	days := cfg.Days
+	if days <= 0 {
+		days = 30
+	}
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = 1
+	}
+	end := cfg.End
+	if end.IsZero() {
+		end = time.Now()
+	}
+	end = time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+	start := end.AddDate(0, 0, -days+1)
+
+	rng := rand.New(rand.NewSource(seed))
+	at := func(day time.Time, fromHour, toHour int) time.Time {
+		minutes := rng.Intn((toHour - fromHour) * 60)
+		return day.Add(time.Duration(fromHour)*time.Hour + time.Duration(minutes)*time.Minute)
+	}
+	amount := func(min, max float64) float64 {
+		return math.Round((min+rng.Float64()*(max-min))*100) / 100
+	}
+
+	type event struct {
+		ts   time.Time
+		tx   map[string]interface{}
+		kind *purchaseKind
+	}
+	var events []event
+	newTx := func(txType string, value float64, counterparty, description, category string) map[string]interface{} {
+		return map[string]interface{}{
+			"type":         txType,
+			"amount":       value,
+			"currency":     currency,
+			"counterparty": counterparty,
+			"description":  description,
+			"category":     category,
+		}
+	}
+
+	// Income first, so purchases can key off paydays.
+	var paydays []time.Time
+	if perYear, ok := incomePaychecks[income]; ok {
+		paycheck := math.Round(profile.monthlyIncome*12/float64(perYear)*100) / 100
+		// The first payday falls inside the window even when it is shorter
+		// than the pay period.
+		phase := int(nextPayday(income, start).Sub(start).Hours() / 24)
+		if phase > days {
+			phase = days
+		}
+		for day := start.AddDate(0, 0, rng.Intn(phase)); !day.After(end); day = nextPayday(income, day) {
+			paydays = append(paydays, day)
+			events = append(events, event{ts: at(day, 6, 9), tx: newTx("receive", paycheck, "Acme Corp", "Salary", "income")})
+		}
+	} else {
+		// Gig work: payouts of varying size 4 to 10 days apart, about one a
+		// week on average.
+		mean := profile.monthlyIncome * 12 / 52
+		for day := start.AddDate(0, 0, rng.Intn(7)); !day.After(end); day = day.AddDate(0, 0, 4+rng.Intn(7)) {
+			paydays = append(paydays, day)
+			events = append(events, event{ts: at(day, 9, 20), tx: newTx("receive", amount(mean*0.3, mean*1.7), "GigPay", "Gig payout", "income")})
+		}
+	}
+	nearPayday := func(day time.Time) bool {
+		for _, p := range paydays {
+			if d := day.Sub(p).Hours() / 24; d >= 0 && d < 4 {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := range profile.purchases {
+		kind := &profile.purchases[i]
+		rate := kind.perWeek / 7
+		if kind.afterPayday {
+			// Concentrate the same weekly volume into the days after payday.
+			rate = kind.perWeek / 4
+		}
+		phase := 0
+		if kind.every > 0 {
+			phase = rng.Intn(kind.every)
+		}
+		for day, n := start, 0; !day.After(end); day, n = day.AddDate(0, 0, 1), n+1 {
+			count := 0
+			switch {
+			case kind.every > 0:
+				if n%kind.every == phase {
+					count = 1
+				}
+			case kind.afterPayday && !nearPayday(day):
+			default:
+				count = int(rate)
+				if rng.Float64() < rate-float64(count) {
+					count++
+				}
+			}
+			for j := 0; j < count; j++ {
+				merchant := kind.merchants[rng.Intn(len(kind.merchants))]
+				events = append(events, event{
+					ts:   at(day, 7, 22),
+					tx:   newTx("send", amount(kind.min, kind.max), merchant, kind.description, kind.category),
+					kind: kind,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].ts.Before(events[j].ts) })
+
+	balance := profile.opening
+	transactions := make([]map[string]interface{}, 0, len(events))
+	for _, e := range events {
+		value := e.tx["amount"].(float64)
+		if e.tx["type"] == "send" {
+			if value > balance {
+				if e.kind != nil && e.kind.requireFunds {
+					continue
+				}
+				value = math.Floor(balance*100) / 100
+				if value <= 0 {
+					continue
+				}
+				e.tx["amount"] = value
+			}
+			balance -= value
+		} else {
+			balance += value
+		}
+		balance = math.Round(balance*100) / 100
+		e.tx["timestamp"] = e.ts.Format(time.RFC3339)
+		e.tx["balance_after"] = balance
+		transactions = append(transactions, e.tx)
+	}
+	return transactions, nil
+}
+
+// transactionCSVColumns is the schema loadTransactionsFromCSV reads.
+var transactionCSVColumns = []string{"timestamp", "type", "amount", "currency", "counterparty", "description", "category", "balance_after"}
+
+// writeTransactionsCSV writes transactions in the transactions.csv schema.
+func writeTransactionsCSV(w io.Writer, transactions []map[string]interface{}) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(transactionCSVColumns); err != nil {
+		return err
+	}
+	for _, tx := range transactions {
+		row := make([]string, len(transactionCSVColumns))
+		for i, col := range transactionCSVColumns {
+			switch v := tx[col].(type) {
+			case float64:
+				row[i] = fmt.Sprintf("%.2f", v)
+			case nil:
+			default:
+				row[i] = fmt.Sprint(v)
+			}
+		}
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// minPersonalityTransactions is the floor analyze_money_personality enforces.
+const minPersonalityTransactions = 10
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// personaEnd is a fixed last day so every run generates the same histories.
+var personaEnd = time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+
+// TestPersonasClassifyAsTheirArchetype generates each persona for several
+// lengths of history, every income pattern and 20 seeds, reads it back
+// through loadTransactionsFromCSV and checks matchArchetype names the
+// persona's archetype.
+func TestPersonasClassifyAsTheirArchetype(t *testing.T) {
+	// loadTransactionsFromCSV logs every file it reads.
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := t.TempDir()
+	for _, name := range personaNames() {
+		for _, days := range []int{30, 60, 90, 180, 365} {
+			for _, income := range []string{"", "weekly", "biweekly", "monthly", "irregular"} {
+				t.Run(fmt.Sprintf("%s/%dd/%s", name, days, firstNonEmpty(income, "default")), func(t *testing.T) {
+					for seed := int64(1); seed <= 20; seed++ {
+						cfg := GeneratorConfig{Archetype: name, Income: income, Days: days, Seed: seed, End: personaEnd}
+						loaded := roundTripPersona(t, dir, cfg)
+						if len(loaded) < minPersonalityTransactions {
+							t.Fatalf("seed %d: only %d transactions", seed, len(loaded))
+						}
+						scores := calculatePersonalityScores(loaded)
+						if got, want := matchArchetype(scores).Type, personaProfiles[name].archetype; got != want {
+							t.Errorf("seed %d: classified as %s, want %s (%d transactions, scores %v)", seed, got, want, len(loaded), scores)
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+// TestGeneratorPaysOnRealCadences checks paychecks follow the income
+// pattern across the whole window rather than one month's worth.
+func TestGeneratorPaysOnRealCadences(t *testing.T) {
+	tests := []struct {
+		income   string
+		days     int
+		min, max int
+	}{
+		{"weekly", 365, 52, 53},
+		{"biweekly", 365, 26, 27},
+		{"monthly", 365, 12, 12},
+		{"biweekly", 14, 1, 1},
+		{"monthly", 7, 1, 1},
+		{"monthly", 90, 2, 3},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%dd", tt.income, tt.days), func(t *testing.T) {
+			for seed := int64(1); seed <= 20; seed++ {
+				generated, err := generateTransactions(GeneratorConfig{Archetype: "reward_seeker", Income: tt.income, Days: tt.days, Seed: seed, End: personaEnd})
+				if err != nil {
+					t.Fatal(err)
+				}
+				first := personaEnd.AddDate(0, 0, -tt.days+1)
+				paychecks := 0
+				for _, tx := range generated {
+					ts, ok := txTime(tx)
+					if !ok || ts.Before(first) || !ts.Before(personaEnd.AddDate(0, 0, 1)) {
+						t.Fatalf("seed %d: %v is outside the window", seed, tx["timestamp"])
+					}
+					if tx["category"] == "income" {
+						paychecks++
+					}
+				}
+				if paychecks < tt.min || paychecks > tt.max {
+					t.Errorf("seed %d: %d paychecks, want %d to %d", seed, paychecks, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+// roundTripPersona generates cfg, writes it as a CSV in dir and loads it
+// back the way the analysis tools read a statement.
+func roundTripPersona(t *testing.T, dir string, cfg GeneratorConfig) []map[string]interface{} {
+	t.Helper()
+	generated, err := generateTransactions(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s-%d-%d.csv", cfg.Archetype, firstNonEmpty(cfg.Income, "default"), cfg.Days, cfg.Seed))
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writeTransactionsCSV(f, generated)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadTransactionsFromCSV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loaded
+}
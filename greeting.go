@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+	"github.com/becomeliminal/nim-go-sdk/tools"
+)
+
+// ============================================================================
+// SESSION-START GREETING
+// ============================================================================
+// Grounds the first message of a conversation. Balance, savings, spare cash
+// and pending insights are fetched in parallel and condensed into one short
+// context block, so the greeting needs a single tool round-trip instead of
+// the model deciding to chain four.
+//
+// This is not a connection-open hook. server.Config has no connection or
+// session-start callback, and the server owns its listener (Run takes an
+// address, there is no handler to wrap), so nothing of ours runs between a
+// user connecting and the first model turn. The block is served by the
+// start_session tool instead and the system prompt asks for it first, which
+// still relies on the model making that call. Once the SDK exposes a
+// session-start hook, build should be called from it and its Block added to
+// the conversation directly.
+
+// greetingFetchTimeout bounds each parallel fetch so one slow call can't
+// hold up the greeting.
+const greetingFetchTimeout = 5 * time.Second
+
+// SessionContext is everything the model needs to greet the user.
+type SessionContext struct {
+	Currency    string     `json:"currency"`
+	Balance     *float64   `json:"balance,omitempty"`
+	Savings     *float64   `json:"savings,omitempty"`
+	SpareCash   *SpareCash `json:"spare_cash,omitempty"`
+	Insights    []Insight  `json:"insights"`
+	Unavailable []string   `json:"unavailable_tools,omitempty"` // flagged tools this user can't call yet
+	User        string     `json:"user_context,omitempty"`      // the prompt's per-user section
+	Errors      []string   `json:"errors,omitempty"`
+	Block       string     `json:"context"`
+}
+
+// sessionGreeter assembles the session context.
+type sessionGreeter struct {
+	liminalExecutor core.ToolExecutor
+	spareCash       *spareCashService
+	insights        *insightQueue
+
+	// prompt renders the per-user part of the system prompt and knows which
+	// tools feature flags hold back; main wires it once tools are registered.
+	prompt *promptBuilder
+}
+
+func newSessionGreeter(liminalExecutor core.ToolExecutor, spareCash *spareCashService, insights *insightQueue) *sessionGreeter {
+	return &sessionGreeter{
+		liminalExecutor: liminalExecutor,
+		spareCash:       spareCash,
+		insights:        insights,
+	}
+}
+
+// build fetches balances and transactions in parallel. Failed fetches are
+// listed in Errors and left out of the block rather than failing the greeting.
+func (g *sessionGreeter) build(ctx context.Context, toolParams *core.ToolParams) SessionContext {
+	var (
+		sc SessionContext
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	fail := func(what string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		sc.Errors = append(sc.Errors, fmt.Sprintf("%s: %v", what, err))
+	}
+
+	fetchBalance := func(tool string, dst *map[string]interface{}) {
+		defer wg.Done()
+		ctx, cancel := context.WithTimeout(ctx, greetingFetchTimeout)
+		defer cancel()
+		data, err := callLiminal(ctx, g.liminalExecutor, toolParams.UserID, toolParams.RequestID, tool, map[string]interface{}{})
+		if err != nil {
+			fail(tool, err)
+			return
+		}
+		*dst = data
+	}
+
+	var (
+		balanceData  map[string]interface{}
+		savingsData  map[string]interface{}
+		transactions []map[string]interface{}
+		txErr        error
+	)
+
+	wg.Add(3)
+	go fetchBalance("get_balance", &balanceData)
+	go fetchBalance("get_savings_balance", &savingsData)
+	go func() {
+		defer wg.Done()
+		ctx, cancel := context.WithTimeout(ctx, greetingFetchTimeout)
+		defer cancel()
+		transactions, txErr = fetchTransactions(ctx, g.liminalExecutor, toolParams.UserID, toolParams.RequestID, transactionFetchLimit)
+	}()
+	sc.Insights = g.insights.pending(toolParams.UserID, true)
+	if g.prompt != nil {
+		sc.Unavailable = g.prompt.registry.unavailableFor(toolParams.UserID)
+		user, err := g.prompt.user(toolParams.UserID)
+		if err != nil {
+			fail("user context", err)
+		}
+		sc.User = user
+	}
+	wg.Wait()
+
+	// Balances are shown in the currency the user mostly transacts in;
+	// other currencies' balances aren't converted into it.
+	sc.Currency = mainCurrency(transactions)
+	if balanceData != nil {
+		total := balanceTotal(balanceData, sc.Currency)
+		sc.Balance = &total
+	}
+	if savingsData != nil {
+		total := balanceTotal(savingsData, sc.Currency)
+		sc.Savings = &total
+	}
+	if txErr != nil {
+		fail("transactions", txErr)
+	} else if sc.Balance != nil {
+		spare := computeSpareCash(*sc.Balance, g.spareCash.buffer(toolParams.UserID), transactions, time.Now())
+		sc.SpareCash = &spare
+	}
+
+	sc.Block = sc.render(time.Now())
+	return sc
+}
+
+// render condenses the context into a few lines for the model.
+func (sc SessionContext) render(now time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[SESSION CONTEXT %s]\n", now.UTC().Format("2006-01-02 15:04 UTC"))
+
+	var money []string
+	if sc.Balance != nil {
+		money = append(money, "Wallet: "+formatMoney(*sc.Balance, sc.Currency))
+	}
+	if sc.Savings != nil {
+		money = append(money, "Savings: "+formatMoney(*sc.Savings, sc.Currency))
+	}
+	if len(money) > 0 {
+		b.WriteString(strings.Join(money, " | ") + "\n")
+	}
+
+	if s := sc.SpareCash; s != nil {
+		payday := s.NextPayday.Format("Jan 2")
+		if !s.PaydayDetected {
+			payday += ", estimated"
+		}
+		fmt.Fprintf(&b, "Spare cash: %s safe to save; ~%s/day to spend until payday (%s, %d days)\n",
+			formatMoney(s.SafeToSave, sc.Currency), formatMoney(s.SafeToSpendDaily, sc.Currency), payday, s.DaysToPayday)
+		if s.UpcomingBills > 0 {
+			fmt.Fprintf(&b, "Bills before payday: %s\n", formatMoney(s.UpcomingBills, sc.Currency))
+		}
+	}
+
+	if len(sc.Insights) > 0 {
+		fmt.Fprintf(&b, "Pending insights (%d):\n", len(sc.Insights))
+		for _, in := range sc.Insights {
+			fmt.Fprintf(&b, "- [%s] %s: %s\n", in.Severity, in.Title, in.Message)
+		}
+	}
+	if sc.User != "" {
+		b.WriteString("About the user:\n" + sc.User + "\n")
+	}
+	if len(sc.Unavailable) > 0 {
+		fmt.Fprintf(&b, "Tools not available for this user: %s\n", strings.Join(sc.Unavailable, ", "))
+	}
+	if len(sc.Errors) > 0 {
+		fmt.Fprintf(&b, "Unavailable: %s\n", strings.Join(sc.Errors, "; "))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func createStartSessionTool(greeter *sessionGreeter) core.Tool {
+	return tools.New("start_session").
+		Description("Call this FIRST in every new conversation, before greeting the user. Returns a compact context block with wallet and savings balances, spare cash until payday, and any proactive insights waiting for the user - all fetched in one go.").
+		Schema(tools.ObjectSchema(map[string]interface{}{})).
+		Handler(func(ctx context.Context, toolParams *core.ToolParams) (*core.ToolResult, error) {
+			return &core.ToolResult{
+				Success: true,
+				Data:    greeter.build(ctx, toolParams),
+			}, nil
+		}).
+		Build()
+}
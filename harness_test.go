@@ -0,0 +1,505 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+	"gopkg.in/yaml.v3"
+)
+
+// ============================================================================
+// SCRIPTED CONVERSATION HARNESS
+// ============================================================================
+// Replays scripted conversations against the tool layer with a fake model:
+// each scenario (YAML, see testdata/scenarios) lists the user's messages and
+// the tool_use blocks the model answers with. The harness executes those
+// calls by name on the tools the server registers - registerTools over the
+// same executor chain (wrapLiminalExecutor: policy cap, transaction cache),
+// so banking writes are held by ExecuteWrite until the user confirms - with
+// the offline simulator underneath, then checks the tool results, the
+// confirmation prompts and the final balances.
+// A scenario with a cassette runs against the recorded Liminal responses
+// instead (cassette.go), which catches tools that no longer parse them;
+// there is no simulator state, so it can't check final balances.
+//
+// Nothing calls Anthropic; TestScenarios runs testdata/scenarios under
+// `go test`.
+
+// Scenario is one scripted conversation.
+type Scenario struct {
+	Name     string         `yaml:"name"`
+	User     string         `yaml:"user"`     // default: demo-user
+	Fixtures string         `yaml:"fixtures"` // default: fixtures/simulator
+	Cassette string         `yaml:"cassette"` // replay this cassette instead of the simulator
+	Now      time.Time      `yaml:"now"`      // simulator clock (default: now)
+	Config   yaml.Node      `yaml:"config"`   // neurapay.yaml settings over the defaults, e.g. policy
+	Turns    []ScenarioTurn `yaml:"turns"`
+	Expect   FinalExpect    `yaml:"expect"`
+
+	path string
+}
+
+// ScenarioTurn is a user message and the model's scripted reply.
+type ScenarioTurn struct {
+	User    string      `yaml:"user"`
+	Confirm *bool       `yaml:"confirm"` // the user's answer to the pending confirmation prompt
+	Model   []ModelStep `yaml:"model"`
+}
+
+// ModelStep is one block of a scripted model reply: a tool call or text.
+type ModelStep struct {
+	ToolUse string                 `yaml:"tool_use"`
+	Input   map[string]interface{} `yaml:"input"`
+	Text    string                 `yaml:"text"`
+	Expect  *StepExpect            `yaml:"expect"`
+}
+
+// StepExpect checks the tool_result the model gets back.
+type StepExpect struct {
+	Success        *bool    `yaml:"success"`
+	Error          string   `yaml:"error"`        // substring of the error
+	Contains       []string `yaml:"contains"`     // substrings of the JSON result
+	Confirmation   string   `yaml:"confirmation"` // substring of the confirmation prompt
+	NoConfirmation bool     `yaml:"no_confirmation"`
+}
+
+// FinalExpect checks state after the last turn.
+type FinalExpect struct {
+	Wallet               map[string]float64 `yaml:"wallet"`
+	Savings              map[string]float64 `yaml:"savings"`
+	LiminalCalls         []CallExpect       `yaml:"liminal_calls"` // in order, other calls may interleave
+	NoUnconfirmedWrites  bool               `yaml:"no_unconfirmed_writes"`
+	PendingConfirmations *int               `yaml:"pending_confirmations"`
+}
+
+// CallExpect matches a call that reached Liminal. Input is matched as a
+// subset; numbers match numeric strings ("25.00" == 25).
+type CallExpect struct {
+	Tool      string                 `yaml:"tool"`
+	Input     map[string]interface{} `yaml:"input"`
+	Confirmed *bool                  `yaml:"confirmed"`
+}
+
+// loadScenarios reads every .yaml/.yml file under the given paths.
+func loadScenarios(paths []string) ([]*Scenario, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		err = filepath.Walk(p, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() && (strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")) {
+				files = append(files, path)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+
+	var scenarios []*Scenario
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var s Scenario
+		if err := yaml.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		s.path = f
+		if s.Name == "" {
+			s.Name = strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+		}
+		scenarios = append(scenarios, &s)
+	}
+	return scenarios, nil
+}
+
+// ----------------------------------------------------------------------------
+// Audit
+// ----------------------------------------------------------------------------
+
+// auditedCall is one call that reached the executor.
+type auditedCall struct {
+	Tool           string
+	Input          json.RawMessage
+	Write          bool // went through ExecuteWrite
+	ConfirmationID string
+	Confirmed      bool // the user confirmed it
+	Cancelled      bool
+}
+
+// moved reports whether the call moved money.
+func (c auditedCall) moved() bool {
+	return simWriteTools[c.Tool] && (!c.Write || c.Confirmed)
+}
+
+// auditExecutor records every call on its way to the simulator or cassette.
+type auditExecutor struct {
+	inner core.ToolExecutor
+
+	mu    sync.Mutex
+	calls []auditedCall
+}
+
+func (a *auditExecutor) log(call auditedCall) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.calls = append(a.calls, call)
+	return len(a.calls) - 1
+}
+
+func (a *auditExecutor) Execute(ctx context.Context, req *core.ExecuteRequest) (*core.ExecuteResponse, error) {
+	a.log(auditedCall{Tool: req.Tool, Input: req.Input})
+	return a.inner.Execute(ctx, req)
+}
+
+func (a *auditExecutor) ExecuteWrite(ctx context.Context, req *core.ExecuteRequest) (*core.ExecuteResponse, error) {
+	i := a.log(auditedCall{Tool: req.Tool, Input: req.Input, Write: true})
+	resp, err := a.inner.ExecuteWrite(ctx, req)
+	if err == nil && resp.Success {
+		var pending struct {
+			ConfirmationID string `json:"confirmation_id"`
+		}
+		if json.Unmarshal(resp.Data, &pending) == nil {
+			a.mu.Lock()
+			a.calls[i].ConfirmationID = pending.ConfirmationID
+			a.mu.Unlock()
+		}
+	}
+	return resp, err
+}
+
+func (a *auditExecutor) mark(confirmationID string, confirmed bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i := range a.calls {
+		if a.calls[i].ConfirmationID == confirmationID {
+			a.calls[i].Confirmed = confirmed
+			a.calls[i].Cancelled = !confirmed
+		}
+	}
+}
+
+func (a *auditExecutor) Confirm(ctx context.Context, userID, confirmationID string) (*core.ExecuteResponse, error) {
+	resp, err := a.inner.Confirm(ctx, userID, confirmationID)
+	if err == nil && resp.Success {
+		a.mark(confirmationID, true)
+	}
+	return resp, err
+}
+
+func (a *auditExecutor) Cancel(ctx context.Context, userID, confirmationID string) error {
+	err := a.inner.Cancel(ctx, userID, confirmationID)
+	if err == nil {
+		a.mark(confirmationID, false)
+	}
+	return err
+}
+
+// ----------------------------------------------------------------------------
+// Runner
+// ----------------------------------------------------------------------------
+
+// confirmationPrompt is a write waiting on the user.
+type confirmationPrompt struct {
+	ID      string
+	Tool    string
+	Summary string
+}
+
+// TestScenarios plays every scenario in testdata/scenarios.
+func TestScenarios(t *testing.T) {
+	scenarios, err := loadScenarios([]string{"testdata/scenarios"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scenarios) == 0 {
+		t.Fatal("no scenarios found")
+	}
+
+	// Tools log as they work; keep the output readable.
+	if !testing.Verbose() {
+		log.SetOutput(io.Discard)
+		defer log.SetOutput(os.Stderr)
+	}
+	for _, s := range scenarios {
+		s := s
+		t.Run(s.Name, func(t *testing.T) {
+			runScenario(context.Background(), t, s)
+		})
+	}
+}
+
+// runScenario plays one scenario with fresh simulator and local state.
+func runScenario(ctx context.Context, t *testing.T, s *Scenario) {
+	userID := firstNonEmpty(s.User, "demo-user")
+	var (
+		sim   *liminalSimulator
+		audit *auditExecutor
+	)
+	if s.Cassette != "" {
+		cassette, err := loadCassette(s.Cassette)
+		if err != nil {
+			t.Fatalf("cassette: %v", err)
+		}
+		audit = &auditExecutor{inner: newReplayExecutor(cassette)}
+	} else {
+		fixtures := s.Fixtures
+		if fixtures == "" {
+			fixtures = "fixtures/simulator"
+		}
+		var err error
+		sim, err = newSimulatorFromDir(fixtures)
+		if err != nil {
+			t.Fatalf("simulator: %v", err)
+		}
+		if !s.Now.IsZero() {
+			now := s.Now
+			sim.now = func() time.Time { return now }
+			sim.lastAccrual = now
+		}
+		audit = &auditExecutor{inner: sim}
+	}
+
+	cfg := defaultConfig()
+	if s.Config.Kind != 0 {
+		if err := s.Config.Decode(cfg); err != nil {
+			t.Fatalf("config: %v", err)
+		}
+	}
+
+	// Automations and the transaction cache persist to NEURAPAY_DATA_DIR;
+	// give each scenario its own.
+	t.Setenv("NEURAPAY_DATA_DIR", t.TempDir())
+	liminalExecutor, err := wrapLiminalExecutor(audit, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom := newToolset(liminalExecutor, nil, cfg)
+	registry, err := registerTools(cfg, liminalExecutor, custom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registered := make(map[string]core.Tool, len(registry.tools))
+	for _, tool := range registry.tools {
+		registered[tool.Name()] = tool
+	}
+
+	var pending []confirmationPrompt
+	for turnNo, turn := range s.Turns {
+		where := fmt.Sprintf("turn %d", turnNo+1)
+
+		if turn.Confirm != nil {
+			if len(pending) == 0 {
+				t.Errorf("%s: user answered a confirmation but none was pending", where)
+			}
+			for _, p := range pending {
+				if *turn.Confirm {
+					resp, err := liminalExecutor.Confirm(ctx, userID, p.ID)
+					if err != nil || !resp.Success {
+						t.Errorf("%s: confirming %s failed: %v", where, p.Tool, firstError(err, resp))
+					}
+				} else if err := liminalExecutor.Cancel(ctx, userID, p.ID); err != nil {
+					t.Errorf("%s: cancelling %s failed: %v", where, p.Tool, err)
+				}
+			}
+			pending = nil
+		}
+
+		for stepNo, step := range turn.Model {
+			if step.ToolUse == "" {
+				continue // plain text reply
+			}
+			where := fmt.Sprintf("%s step %d (%s)", where, stepNo+1, step.ToolUse)
+
+			tool := registered[step.ToolUse]
+			if tool == nil {
+				t.Errorf("%s: model called a tool that isn't registered", where)
+				continue
+			}
+			input, err := json.Marshal(step.Input)
+			if err != nil || step.Input == nil {
+				input = json.RawMessage("{}")
+			}
+			res, err := tool.Execute(ctx, &core.ToolParams{UserID: userID, Input: input, RequestID: where})
+			if err != nil {
+				res = &core.ToolResult{Success: false, Error: err.Error()}
+			}
+			data := resultData(res.Data)
+
+			// A write the executor held comes back with a confirmation
+			// for the user instead of a receipt.
+			var prompt *confirmationPrompt
+			if res.Success && simWriteTools[step.ToolUse] {
+				var held struct {
+					ConfirmationID string `json:"confirmation_id"`
+					Summary        string `json:"summary"`
+				}
+				if encoded, err := json.Marshal(data); err == nil && json.Unmarshal(encoded, &held) == nil && held.ConfirmationID != "" {
+					prompt = &confirmationPrompt{ID: held.ConfirmationID, Tool: step.ToolUse, Summary: held.Summary}
+					pending = append(pending, *prompt)
+				}
+			}
+
+			if step.Expect != nil {
+				checkStep(t, where, step.Expect, res.Success, data, res.Error, prompt)
+			}
+		}
+	}
+
+	checkFinal(t, s.Expect, sim, userID, audit, len(pending))
+}
+
+// resultData decodes raw JSON results (the banking tools return the
+// executor's response as is) so they compare like the custom tools' data.
+func resultData(data interface{}) interface{} {
+	raw, ok := data.(json.RawMessage)
+	if !ok {
+		return data
+	}
+	var decoded interface{}
+	if json.Unmarshal(raw, &decoded) != nil {
+		return data
+	}
+	return decoded
+}
+
+func firstError(err error, resp *core.ExecuteResponse) error {
+	if err != nil {
+		return err
+	}
+	if resp != nil && resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	return errors.New("unsuccessful")
+}
+
+func checkStep(t *testing.T, where string, want *StepExpect, success bool, data interface{}, errMsg string, prompt *confirmationPrompt) {
+	if want.Success != nil && *want.Success != success {
+		t.Errorf("%s: success = %t, want %t (error: %q)", where, success, *want.Success, errMsg)
+	}
+	if want.Error != "" && !strings.Contains(strings.ToLower(errMsg), strings.ToLower(want.Error)) {
+		t.Errorf("%s: error %q does not contain %q", where, errMsg, want.Error)
+	}
+	if len(want.Contains) > 0 {
+		encoded, _ := json.Marshal(data)
+		for _, sub := range want.Contains {
+			if !strings.Contains(string(encoded), sub) {
+				t.Errorf("%s: result does not contain %q", where, sub)
+			}
+		}
+	}
+	switch {
+	case want.Confirmation != "" && prompt == nil:
+		t.Errorf("%s: expected a confirmation prompt, got none", where)
+	case want.Confirmation != "" && !strings.Contains(prompt.Summary, want.Confirmation):
+		t.Errorf("%s: confirmation prompt %q does not contain %q", where, prompt.Summary, want.Confirmation)
+	case want.NoConfirmation && prompt != nil:
+		t.Errorf("%s: unexpected confirmation prompt %q", where, prompt.Summary)
+	}
+}
+
+func checkFinal(t *testing.T, want FinalExpect, sim *liminalSimulator, userID string, audit *auditExecutor, pending int) {
+	if sim == nil && len(want.Wallet)+len(want.Savings) > 0 {
+		t.Errorf("final: balances can't be checked against a cassette")
+	} else if sim != nil {
+		wallet, savings := sim.balances(userID)
+		for currency, amount := range want.Wallet {
+			if got := wallet[strings.ToUpper(currency)]; round2(got) != round2(amount) {
+				t.Errorf("final: wallet %s = %.2f, want %.2f", currency, got, amount)
+			}
+		}
+		for currency, amount := range want.Savings {
+			if got := savings[strings.ToUpper(currency)]; round2(got) != round2(amount) {
+				t.Errorf("final: savings %s = %.2f, want %.2f", currency, got, amount)
+			}
+		}
+	}
+
+	audit.mu.Lock()
+	calls := append([]auditedCall(nil), audit.calls...)
+	audit.mu.Unlock()
+
+	if want.NoUnconfirmedWrites {
+		for _, c := range calls {
+			if c.moved() && !c.Confirmed {
+				t.Errorf("final: %s %s moved money without user confirmation", c.Tool, c.Input)
+			}
+		}
+	}
+
+	next := 0
+	for _, wantCall := range want.LiminalCalls {
+		found := false
+		for next < len(calls) {
+			c := calls[next]
+			next++
+			if c.Tool == wantCall.Tool && inputMatches(wantCall.Input, c.Input) &&
+				(wantCall.Confirmed == nil || *wantCall.Confirmed == c.Confirmed) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("final: no %s call matching %v (in order)", wantCall.Tool, wantCall.Input)
+		}
+	}
+
+	if want.PendingConfirmations != nil && *want.PendingConfirmations != pending {
+		t.Errorf("final: %d confirmations pending, want %d", pending, *want.PendingConfirmations)
+	}
+}
+
+// inputMatches reports whether every key in want has an equal value in got.
+func inputMatches(want map[string]interface{}, got json.RawMessage) bool {
+	var actual map[string]interface{}
+	if json.Unmarshal(got, &actual) != nil {
+		return len(want) == 0
+	}
+	for k, v := range want {
+		if !valuesEqual(v, actual[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+func valuesEqual(want, got interface{}) bool {
+	if w, ok := numberValue(normalizeNumber(want)); ok {
+		g, ok := numberValue(got)
+		return ok && round2(w) == round2(g)
+	}
+	return fmt.Sprint(want) == fmt.Sprint(got)
+}
+
+// normalizeNumber converts YAML integers to float64 for numberValue.
+func normalizeNumber(v interface{}) interface{} {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	}
+	return v
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// SPENDING HEATMAPS
+// ============================================================================
+// When the money goes out: spend by weekday, hour of day and day of month,
+// in the user's timezone. Liminal and the fixtures send UTC timestamps, so
+// without one a Friday-night habit would land on Saturday morning. The patterns found in them ("62% of your
+// dining spend happens Fri-Sat after 8pm") back up analyze_spending and give
+// the money personality's triggers evidence from the user's own history.
+
+// HeatmapCell is the spend in one weekday, hour or day of the month.
+type HeatmapCell struct {
+	Label  string  `json:"label"`
+	Amount float64 `json:"amount"`
+	Count  int     `json:"count"`
+	Share  float64 `json:"share"` // percent of the total
+}
+
+// SpendingHeatmap is the temporal distribution of the user's spending.
+type SpendingHeatmap struct {
+	Currency   string        `json:"currency"`
+	Timezone   string        `json:"timezone"`
+	Total      float64       `json:"total"`
+	Weekday    []HeatmapCell `json:"by_weekday"` // Monday first
+	Hour       []HeatmapCell `json:"by_hour"`
+	DayOfMonth []HeatmapCell `json:"by_day_of_month"`
+	Insights   []string      `json:"insights"`
+}
+
+// heatmapWeekdays are the short weekday names, Monday first.
+var heatmapWeekdays = []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+// weekdayIndex maps a weekday to its place in heatmapWeekdays.
+func weekdayIndex(d time.Weekday) int {
+	return (int(d) + 6) % 7
+}
+
+// hourWindow is a part of the day a pattern can fall in.
+type hourWindow struct {
+	phrase string
+	hours  []int
+}
+
+// hourRange lists the hours from from up to, not including, to, wrapping
+// past midnight; from == to is the whole day.
+func hourRange(from, to int) []int {
+	out := []int{from}
+	for h := (from + 1) % 24; h != to; h = (h + 1) % 24 {
+		out = append(out, h)
+	}
+	return out
+}
+
+var heatmapHourWindows = []hourWindow{
+	{"", hourRange(0, 0)},
+	{"in the morning", hourRange(5, 12)},
+	{"at lunchtime", hourRange(12, 14)},
+	{"after 6pm", hourRange(18, 0)},
+	{"after 8pm", hourRange(20, 0)},
+	{"late at night", hourRange(22, 4)},
+}
+
+// dayWindow is a set of weekdays a pattern can fall on.
+type dayWindow struct {
+	phrase string
+	days   []int // heatmapWeekdays indexes
+}
+
+// heatmapDayWindows are every day, each single day, weekdays, and each pair
+// of consecutive days.
+var heatmapDayWindows = func() []dayWindow {
+	out := []dayWindow{{"", []int{0, 1, 2, 3, 4, 5, 6}}, {"on weekdays", []int{0, 1, 2, 3, 4}}}
+	for i, name := range heatmapWeekdays {
+		long := time.Weekday((i + 1) % 7).String()
+		out = append(out, dayWindow{"on " + long + "s", []int{i}})
+		next := (i + 1) % 7
+		out = append(out, dayWindow{name + "-" + heatmapWeekdays[next], []int{i, next}})
+	}
+	return out
+}()
+
+const (
+	// heatmapMinShare is the share of spend a pattern must cover.
+	heatmapMinShare = 0.5
+	// heatmapMinLift is how much more concentrated than an even spread a
+	// pattern must be to be worth saying.
+	heatmapMinLift = 2.0
+	// heatmapMinSends keeps patterns from resting on a handful of payments.
+	heatmapMinSends = 5
+)
+
+// heatmapLocation loads the IANA timezone to bucket spending in; "" is UTC.
+func heatmapLocation(name string) (*time.Location, error) {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q (use an IANA name such as Europe/London)", name)
+	}
+	return loc, nil
+}
+
+// userHeatmapLocation picks the timezone a tool reads spending times in: its
+// timezone input, else the one the user saved, else UTC.
+func userHeatmapLocation(contexts *userContextStore, userID, name string) (*time.Location, error) {
+	if name == "" && contexts != nil {
+		name = contexts.get(userID).Timezone
+	}
+	return heatmapLocation(name)
+}
+
+// spendingHeatmap builds the heatmaps from sends in the main currency,
+// leaving out savings transfers, with times read in loc. It returns nil
+// without any timed sends.
+func spendingHeatmap(transactions []map[string]interface{}, loc *time.Location) *SpendingHeatmap {
+	currency := mainCurrency(transactions)
+	type send struct {
+		at       time.Time
+		amount   float64
+		category string
+	}
+	var sends []send
+	for _, tx := range transactions {
+		ts, ok := txTime(tx)
+		if !ok || strings.ToUpper(firstNonEmpty(txString(tx, "currency"), "USD")) != currency || isSavingsTransfer(tx) {
+			continue
+		}
+		if txString(tx, "type") == "send" {
+			sends = append(sends, send{ts.In(loc), txAmount(tx), txString(tx, "category")})
+		}
+	}
+	if len(sends) == 0 {
+		return nil
+	}
+	var paydays []time.Time
+	for _, series := range detectRecurring(transactions, "receive") {
+		for _, tx := range transactions {
+			ts, ok := txTime(tx)
+			if ok && txString(tx, "type") == "receive" && normalizeCounterparty(txString(tx, "counterparty")) == series.Counterparty {
+				paydays = append(paydays, ts)
+			}
+		}
+	}
+
+	h := &SpendingHeatmap{
+		Currency:   currency,
+		Timezone:   loc.String(),
+		Weekday:    make([]HeatmapCell, 7),
+		Hour:       make([]HeatmapCell, 24),
+		DayOfMonth: make([]HeatmapCell, 31),
+	}
+	for i := range h.Weekday {
+		h.Weekday[i].Label = heatmapWeekdays[i]
+	}
+	for i := range h.Hour {
+		h.Hour[i].Label = fmt.Sprintf("%02d:00", i)
+	}
+	for i := range h.DayOfMonth {
+		h.DayOfMonth[i].Label = fmt.Sprint(i + 1)
+	}
+	for _, s := range sends {
+		h.Total += s.amount
+		for _, cell := range []*HeatmapCell{&h.Weekday[weekdayIndex(s.at.Weekday())], &h.Hour[s.at.Hour()], &h.DayOfMonth[s.at.Day()-1]} {
+			cell.Amount += s.amount
+			cell.Count++
+		}
+	}
+	for _, cells := range [][]HeatmapCell{h.Weekday, h.Hour, h.DayOfMonth} {
+		for i := range cells {
+			if h.Total > 0 {
+				cells[i].Share = math.Round(cells[i].Amount/h.Total*1000) / 10
+			}
+			cells[i].Amount = round2(cells[i].Amount)
+		}
+	}
+	h.Total = round2(h.Total)
+
+	// Where spending concentrates: overall, then the biggest categories.
+	h.Insights = []string{}
+	byCategory := make(map[string][]send)
+	categoryTotal := make(map[string]float64)
+	for _, s := range sends {
+		if s.category != "" {
+			byCategory[s.category] = append(byCategory[s.category], s)
+			categoryTotal[s.category] += s.amount
+		}
+	}
+	categories := make([]string, 0, len(byCategory))
+	for c := range byCategory {
+		categories = append(categories, c)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if categoryTotal[categories[i]] != categoryTotal[categories[j]] {
+			return categoryTotal[categories[i]] > categoryTotal[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+	groups := [][]send{sends}
+	labels := []string{"spending"}
+	for _, c := range categories {
+		if len(groups) == 4 {
+			break
+		}
+		groups = append(groups, byCategory[c])
+		labels = append(labels, c+" spend")
+	}
+	for i, group := range groups {
+		if len(group) < heatmapMinSends {
+			continue
+		}
+		var total float64
+		var at []time.Time
+		var amounts []float64
+		for _, s := range group {
+			total += s.amount
+			at = append(at, s.at)
+			amounts = append(amounts, s.amount)
+		}
+		if insight := concentration(at, amounts, total, labels[i]); insight != "" {
+			h.Insights = append(h.Insights, insight)
+		}
+	}
+
+	// The busiest day of the week, when it clearly stands out.
+	peak := 0
+	for i, cell := range h.Weekday {
+		if cell.Amount > h.Weekday[peak].Amount {
+			peak = i
+		}
+	}
+	if h.Weekday[peak].Share >= 25 && len(sends) >= heatmapMinSends {
+		h.Insights = append(h.Insights, fmt.Sprintf("%ss are your biggest spending day: %s, %.0f%% of the total", time.Weekday((peak+1)%7), formatMoney(h.Weekday[peak].Amount, currency), h.Weekday[peak].Share))
+	}
+
+	// Spending right after money comes in.
+	if len(paydays) > 0 {
+		after := 0.0
+		for _, s := range sends {
+			for _, p := range paydays {
+				if !s.at.Before(p) && s.at.Sub(p) < 72*time.Hour {
+					after += s.amount
+					break
+				}
+			}
+		}
+		first, last := sends[0].at, sends[0].at
+		for _, s := range sends {
+			if s.at.Before(first) {
+				first = s.at
+			}
+			if s.at.After(last) {
+				last = s.at
+			}
+		}
+		span := math.Max(last.Sub(first).Hours()/24, 1)
+		expected := math.Min(1, 3*float64(len(paydays))/span)
+		share := after / h.Total
+		if share >= 0.3 && share >= 1.5*expected {
+			h.Insights = append(h.Insights, fmt.Sprintf("%.0f%% of your spending happens within 3 days of getting paid", share*100))
+		}
+	}
+
+	// Early in the month, when rent and paychecks land.
+	firstWeek := 0.0
+	for _, cell := range h.DayOfMonth[:7] {
+		firstWeek += cell.Share
+	}
+	if firstWeek >= 40 && len(sends) >= heatmapMinSends {
+		h.Insights = append(h.Insights, fmt.Sprintf("%.0f%% of your spending lands in the first week of the month", firstWeek))
+	}
+	return h
+}
+
+// concentration finds the narrowest weekday and time-of-day window holding
+// at least half of a group's spend, and says so - or "" when spending is
+// spread out.
+func concentration(at []time.Time, amounts []float64, total float64, label string) string {
+	if total <= 0 {
+		return ""
+	}
+	bestLift, bestShare, best := 0.0, 0.0, ""
+	for _, days := range heatmapDayWindows {
+		inDay := make(map[int]bool, len(days.days))
+		for _, d := range days.days {
+			inDay[d] = true
+		}
+		for _, hours := range heatmapHourWindows {
+			if days.phrase == "" && hours.phrase == "" {
+				continue
+			}
+			inHour := make(map[int]bool, len(hours.hours))
+			for _, hr := range hours.hours {
+				inHour[hr] = true
+			}
+			sum, count := 0.0, 0
+			for i, t := range at {
+				if inDay[weekdayIndex(t.Weekday())] && inHour[t.Hour()] {
+					sum += amounts[i]
+					count++
+				}
+			}
+			share := sum / total
+			expected := float64(len(days.days)) / 7 * float64(len(hours.hours)) / 24
+			lift := share / expected
+			if share < heatmapMinShare || lift < heatmapMinLift || count < 3 {
+				continue
+			}
+			if lift > bestLift || (lift == bestLift && share > bestShare) {
+				bestLift, bestShare = lift, share
+				best = strings.TrimSpace(days.phrase + " " + hours.phrase)
+			}
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf("%.0f%% of your %s happens %s", bestShare*100, label, best)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSpendingHeatmapUsesTimezone checks UTC timestamps are bucketed in the
+// user's timezone: Saturday 01:30 UTC is Friday 20:30 in New York.
+func TestSpendingHeatmapUsesTimezone(t *testing.T) {
+	var transactions []map[string]interface{}
+	for week := 0; week < 6; week++ {
+		ts := time.Date(2026, 1, 3, 1, 30, 0, 0, time.UTC).AddDate(0, 0, 7*week) // Saturdays
+		transactions = append(transactions, map[string]interface{}{
+			"type":      "send",
+			"amount":    40.0,
+			"currency":  "USD",
+			"category":  "dining",
+			"timestamp": ts.Format(time.RFC3339),
+		})
+	}
+
+	utc := spendingHeatmap(transactions, time.UTC)
+	if got := utc.Weekday[weekdayIndex(time.Saturday)].Count; got != 6 {
+		t.Errorf("UTC: %d sends on Saturday, want 6", got)
+	}
+
+	loc, err := heatmapLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := spendingHeatmap(transactions, loc)
+	if got := local.Weekday[weekdayIndex(time.Friday)].Count; got != 6 {
+		t.Errorf("New York: %d sends on Friday, want 6", got)
+	}
+	if got := local.Hour[20].Count; got != 6 {
+		t.Errorf("New York: %d sends at 20:00, want 6", got)
+	}
+	if local.Timezone != "America/New_York" {
+		t.Errorf("timezone %q, want America/New_York", local.Timezone)
+	}
+
+	if _, err := heatmapLocation("Mars/Olympus"); err == nil {
+		t.Error("unknown timezone accepted")
+	}
+}
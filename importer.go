@@ -0,0 +1,787 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// ============================================================================
+// CSV IMPORTER
+// ============================================================================
+// Turns bank CSV exports into NeuraPay transactions (the transactions.csv
+// schema: RFC 3339 timestamp, send/receive type, positive amount). A
+// CSVMapping says declaratively which columns hold what and which way the
+// sign points; named presets cover common exports and data.csv_mappings in
+// the config adds more. Encoding, delimiter, header row and date format are
+// detected when the mapping leaves them open.
+//
+// Bad rows don't fail the import - each one is reported with its line number
+// and the rest of the file is kept.
+
+// CSVMapping describes one CSV layout. Column names are matched
+// case-insensitively, and "Date|Posting Date" accepts either.
+type CSVMapping struct {
+	Delimiter  string `yaml:"delimiter" json:"delimiter,omitempty"`     // ",", ";", "\t" or "|"; empty = detect
+	Encoding   string `yaml:"encoding" json:"encoding,omitempty"`       // utf-8, utf-16le, utf-16be or windows-1252; empty = detect
+	Date       string `yaml:"date" json:"date"`                         // required
+	Time       string `yaml:"time" json:"time,omitempty"`               // when the time of day has its own column
+	DateFormat string `yaml:"date_format" json:"date_format,omitempty"` // Go layout, e.g. 02/01/2006; empty = detect
+
+	// Amounts come from one signed column, or from separate money-out and
+	// money-in columns.
+	Amount       string `yaml:"amount" json:"amount,omitempty"`
+	Sign         string `yaml:"sign" json:"sign,omitempty"` // negative_out (default) or positive_out, as on card statements
+	Debit        string `yaml:"debit" json:"debit,omitempty"`
+	Credit       string `yaml:"credit" json:"credit,omitempty"`
+	DecimalComma bool   `yaml:"decimal_comma" json:"decimal_comma,omitempty"` // 1.234,56; otherwise detected per value
+
+	// Direction, when set, decides send/receive instead of the sign.
+	Direction string   `yaml:"direction" json:"direction,omitempty"`
+	OutValues []string `yaml:"out_values" json:"out_values,omitempty"` // e.g. [DEBIT, DR]
+	InValues  []string `yaml:"in_values" json:"in_values,omitempty"`   // empty = anything not in out_values
+
+	Currency        string `yaml:"currency" json:"currency,omitempty"`
+	DefaultCurrency string `yaml:"default_currency" json:"default_currency,omitempty"` // when there's no currency column (default: USD)
+	Counterparty    string `yaml:"counterparty" json:"counterparty,omitempty"`         // falls back to the description
+	Description     string `yaml:"description" json:"description,omitempty"`
+	Category        string `yaml:"category" json:"category,omitempty"`
+	Balance         string `yaml:"balance" json:"balance,omitempty"`
+}
+
+// csvPresets are the built-in layouts. Detection tries them in
+// csvPresetOrder, most specific first.
+var csvPresets = map[string]CSVMapping{
+	"neurapay": {
+		Date:         "timestamp",
+		Amount:       "amount",
+		Direction:    "type",
+		OutValues:    []string{"send"},
+		InValues:     []string{"receive"},
+		Currency:     "currency",
+		Counterparty: "counterparty",
+		Description:  "description",
+		Category:     "category",
+		Balance:      "balance_after",
+	},
+	"chase": {
+		Date:        "Posting Date",
+		DateFormat:  "01/02/2006",
+		Amount:      "Amount",
+		Description: "Description",
+		Balance:     "Balance",
+	},
+	"bank_of_america": {
+		Date:        "Date",
+		DateFormat:  "01/02/2006",
+		Amount:      "Amount",
+		Description: "Description",
+		Balance:     "Running Bal.",
+	},
+	"monzo": {
+		Date:         "Date",
+		Time:         "Time",
+		DateFormat:   "02/01/2006",
+		Amount:       "Amount",
+		Currency:     "Currency",
+		Counterparty: "Name",
+		Description:  "Description",
+		Category:     "Category",
+	},
+	"revolut": {
+		Date:        "Completed Date",
+		Amount:      "Amount",
+		Currency:    "Currency",
+		Description: "Description",
+		Balance:     "Balance",
+	},
+	"signed_amount": {
+		Date:         "Date|Transaction Date|Posting Date|Booking Date|Value Date",
+		Amount:       "Amount|Transaction Amount",
+		Currency:     "Currency",
+		Counterparty: "Payee|Merchant|Counterparty|Name",
+		Description:  "Description|Details|Memo|Narrative|Reference",
+		Category:     "Category",
+		Balance:      "Balance|Running Balance",
+	},
+	"debit_credit": {
+		Date:         "Date|Transaction Date|Posting Date|Booking Date|Value Date",
+		Debit:        "Debit|Debit Amount|Paid Out|Money Out|Withdrawals|Out",
+		Credit:       "Credit|Credit Amount|Paid In|Money In|Deposits|In",
+		Currency:     "Currency",
+		Counterparty: "Payee|Merchant|Counterparty|Name",
+		Description:  "Description|Details|Memo|Narrative|Reference",
+		Category:     "Category",
+		Balance:      "Balance|Running Balance",
+	},
+}
+
+var csvPresetOrder = []string{"neurapay", "monzo", "revolut", "chase", "bank_of_america", "debit_credit", "signed_amount"}
+
+// genericCSVPresets match on their required columns alone; every other
+// preset is only detected when all the columns it names are there.
+var genericCSVPresets = map[string]bool{"signed_amount": true, "debit_credit": true}
+
+// Settings from the config (data.csv_preset, data.csv_mappings); apply
+// sets them.
+var (
+	csvPreset         string
+	customCSVMappings map[string]CSVMapping
+)
+
+// csvPresetNames lists the built-in presets and any from the config.
+func csvPresetNames() []string {
+	names := append([]string{}, csvPresetOrder...)
+	var custom []string
+	for name := range customCSVMappings {
+		if _, builtin := csvPresets[name]; !builtin {
+			custom = append(custom, name)
+		}
+	}
+	sort.Strings(custom)
+	return append(names, custom...)
+}
+
+// lookupCSVMapping finds a preset; config mappings win over built-ins.
+func lookupCSVMapping(name string) (CSVMapping, bool) {
+	if m, ok := customCSVMappings[name]; ok {
+		return m, true
+	}
+	m, ok := csvPresets[name]
+	return m, ok
+}
+
+// check reports what's missing for the mapping to be usable.
+func (m CSVMapping) check() error {
+	switch {
+	case m.Date == "":
+		return errors.New("date column is required")
+	case m.Amount == "" && (m.Debit == "" || m.Credit == ""):
+		return errors.New("amount, or both debit and credit, columns are required")
+	case m.Sign != "" && m.Sign != "negative_out" && m.Sign != "positive_out":
+		return fmt.Errorf("sign must be negative_out or positive_out, got %q", m.Sign)
+	case m.Direction != "" && len(m.OutValues) == 0:
+		return errors.New("out_values is required with a direction column")
+	}
+	if m.Delimiter != "" {
+		if _, ok := csvDelimiter(m.Delimiter); !ok {
+			return fmt.Errorf("delimiter must be one of , ; | or \\t, got %q", m.Delimiter)
+		}
+	}
+	if m.Encoding != "" {
+		if _, _, err := decodeCSV([]byte{}, m.Encoding); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RowError is a row that couldn't be imported.
+type RowError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// ImportResult is the outcome of importing one file, in any of the
+// statement formats (statements.go). Preset, Delimiter and HeaderLine are
+// only set for CSV.
+type ImportResult struct {
+	Format       string                   `json:"format"`
+	Preset       string                   `json:"preset,omitempty"`
+	Encoding     string                   `json:"encoding"`
+	Delimiter    string                   `json:"delimiter,omitempty"`
+	HeaderLine   int                      `json:"header_line,omitempty"`
+	DateFormat   string                   `json:"date_format"`
+	Accounts     []string                 `json:"accounts,omitempty"`
+	Transactions []map[string]interface{} `json:"-"`
+	Errors       []RowError               `json:"errors"`
+	Warnings     []string                 `json:"warnings,omitempty"`
+}
+
+// importCSV imports data with the named preset, or with mapping when it's
+// given, or with the first preset whose columns all appear in a header row.
+func importCSV(data []byte, preset string, mapping *CSVMapping) (*ImportResult, error) {
+	candidates := csvPresetNames()
+	switch {
+	case mapping != nil:
+		if err := mapping.check(); err != nil {
+			return nil, fmt.Errorf("invalid mapping: %w", err)
+		}
+		preset = "custom"
+		candidates = []string{preset}
+	case preset != "":
+		if _, ok := lookupCSVMapping(preset); !ok {
+			return nil, fmt.Errorf("unknown CSV preset %q (have: %s)", preset, strings.Join(csvPresetNames(), ", "))
+		}
+		candidates = []string{preset}
+	}
+	mappingFor := func(name string) CSVMapping {
+		if mapping != nil {
+			return *mapping
+		}
+		m, _ := lookupCSVMapping(name)
+		return m
+	}
+
+	// Decoding and splitting depend on the mapping only when it pins them;
+	// every built-in preset leaves them open.
+	var firstErr error
+	for _, name := range candidates {
+		m := mappingFor(name)
+		text, encoding, err := decodeCSV(data, m.Encoding)
+		if err != nil {
+			return nil, err
+		}
+		delim, ok := csvDelimiter(m.Delimiter)
+		if !ok {
+			delim = detectDelimiter(text)
+		}
+		records, lines, readErrs := readCSVRecords(text, delim)
+
+		strict := len(candidates) > 1 && !genericCSVPresets[name]
+		header, cols, err := findHeader(records, m, strict)
+		if err != nil {
+			if firstErr == nil || len(candidates) == 1 {
+				firstErr = err
+			}
+			continue
+		}
+		result := &ImportResult{
+			Format:     "csv",
+			Preset:     name,
+			Encoding:   encoding,
+			Delimiter:  string(delim),
+			HeaderLine: lines[header],
+		}
+		for _, e := range readErrs {
+			if e.Line > result.HeaderLine {
+				result.Errors = append(result.Errors, e)
+			}
+		}
+		convertCSVRows(result, m, cols, len(records[header]), records[header+1:], lines[header+1:])
+		sort.SliceStable(result.Errors, func(i, j int) bool { return result.Errors[i].Line < result.Errors[j].Line })
+		return result, nil
+	}
+	if len(candidates) > 1 {
+		return nil, fmt.Errorf("no preset matches this file's columns; pick one with --preset or add a mapping (presets: %s)", strings.Join(candidates, ", "))
+	}
+	return nil, firstErr
+}
+
+// ----------------------------------------------------------------------------
+// Encoding, delimiter and header detection
+// ----------------------------------------------------------------------------
+
+// windows1252 maps the 0x80-0x9F range, where it differs from Latin-1.
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// decodeCSV returns the file as UTF-8 text without a byte-order mark. With
+// no encoding given, a BOM decides, then valid UTF-8, then Windows-1252 -
+// what spreadsheet software on Windows writes.
+func decodeCSV(data []byte, encoding string) (string, string, error) {
+	enc := strings.ToLower(strings.ReplaceAll(encoding, "_", "-"))
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}) && (enc == "" || enc == "utf-8" || enc == "utf8"):
+		return string(data[3:]), "utf-8 (BOM)", nil
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}) && (enc == "" || strings.HasPrefix(enc, "utf-16")):
+		return decodeUTF16(data[2:], false), "utf-16le", nil
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}) && (enc == "" || strings.HasPrefix(enc, "utf-16")):
+		return decodeUTF16(data[2:], true), "utf-16be", nil
+	}
+	switch enc {
+	case "":
+		if utf8.Valid(data) {
+			return string(data), "utf-8", nil
+		}
+		return decodeWindows1252(data), "windows-1252", nil
+	case "utf-8", "utf8":
+		if !utf8.Valid(data) {
+			return "", "", errors.New("file is not valid UTF-8; try encoding windows-1252")
+		}
+		return string(data), "utf-8", nil
+	case "utf-16", "utf-16le":
+		return decodeUTF16(data, false), "utf-16le", nil
+	case "utf-16be":
+		return decodeUTF16(data, true), "utf-16be", nil
+	case "windows-1252", "cp1252", "latin1", "latin-1", "iso-8859-1":
+		return decodeWindows1252(data), "windows-1252", nil
+	}
+	return "", "", fmt.Errorf("unsupported encoding %q (use utf-8, utf-16le, utf-16be or windows-1252)", encoding)
+}
+
+func decodeUTF16(data []byte, bigEndian bool) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+func decodeWindows1252(data []byte) string {
+	var b strings.Builder
+	for _, c := range data {
+		if c >= 0x80 && c <= 0x9F {
+			b.WriteRune(windows1252[c-0x80])
+		} else {
+			b.WriteRune(rune(c))
+		}
+	}
+	return b.String()
+}
+
+func csvDelimiter(s string) (rune, bool) {
+	switch s {
+	case ",", ";", "|":
+		return rune(s[0]), true
+	case "\t", `\t`, "tab":
+		return '\t', true
+	}
+	return 0, false
+}
+
+// detectDelimiter picks the candidate that splits the first lines into the
+// most rows sharing the same, widest column count.
+func detectDelimiter(text string) rune {
+	best, bestScore := ',', 0
+	for _, delim := range []rune{',', ';', '\t', '|'} {
+		r := csv.NewReader(strings.NewReader(text))
+		r.Comma = delim
+		r.FieldsPerRecord = -1
+		r.LazyQuotes = true
+		widths := make(map[int]int)
+		for i := 0; i < 20; i++ {
+			rec, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil || len(rec) < 2 {
+				continue
+			}
+			widths[len(rec)]++
+		}
+		for width, rows := range widths {
+			if score := rows * width; score > bestScore {
+				best, bestScore = delim, score
+			}
+		}
+	}
+	return best
+}
+
+// readCSVRecords reads every record with its line number. Malformed rows
+// become RowErrors instead of stopping the read.
+func readCSVRecords(text string, delim rune) ([][]string, []int, []RowError) {
+	r := csv.NewReader(strings.NewReader(text))
+	r.Comma = delim
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	r.TrimLeadingSpace = true
+
+	var (
+		records [][]string
+		lines   []int
+		errs    []RowError
+	)
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var pe *csv.ParseError
+			if errors.As(err, &pe) {
+				errs = append(errs, RowError{Line: pe.Line, Error: pe.Err.Error()})
+				continue
+			}
+			errs = append(errs, RowError{Error: err.Error()})
+			break
+		}
+		line, _ := r.FieldPos(0)
+		records = append(records, rec)
+		lines = append(lines, line)
+	}
+	return records, lines, errs
+}
+
+// csvColumns is where each mapped field sits; -1 when absent.
+type csvColumns struct {
+	date, time, amount, debit, credit, direction  int
+	currency, counterparty, description, category int
+	balance                                       int
+}
+
+// findHeader looks for the first row (banks like to put a summary above the
+// table) naming every required column - or, when strict, every mapped one.
+func findHeader(records [][]string, m CSVMapping, strict bool) (int, csvColumns, error) {
+	for i, rec := range records {
+		if i >= 30 {
+			break
+		}
+		index := make(map[string]int, len(rec))
+		for j, name := range rec {
+			key := strings.ToLower(strings.TrimSpace(name))
+			if _, dup := index[key]; !dup {
+				index[key] = j
+			}
+		}
+		col := func(spec string) int {
+			if spec == "" {
+				return -1
+			}
+			for _, alt := range strings.Split(spec, "|") {
+				if j, ok := index[strings.ToLower(strings.TrimSpace(alt))]; ok {
+					return j
+				}
+			}
+			return -1
+		}
+		cols := csvColumns{
+			date: col(m.Date), time: col(m.Time), amount: col(m.Amount),
+			debit: col(m.Debit), credit: col(m.Credit), direction: col(m.Direction),
+			currency: col(m.Currency), counterparty: col(m.Counterparty),
+			description: col(m.Description), category: col(m.Category), balance: col(m.Balance),
+		}
+		if cols.date < 0 || (m.Direction != "" && cols.direction < 0) {
+			continue
+		}
+		if m.Amount != "" && cols.amount < 0 {
+			continue
+		}
+		if m.Amount == "" && (cols.debit < 0 || cols.credit < 0) {
+			continue
+		}
+		if strict && ((m.Time != "" && cols.time < 0) || (m.Currency != "" && cols.currency < 0) ||
+			(m.Counterparty != "" && cols.counterparty < 0) || (m.Description != "" && cols.description < 0) ||
+			(m.Category != "" && cols.category < 0) || (m.Balance != "" && cols.balance < 0)) {
+			continue
+		}
+		return i, cols, nil
+	}
+	return 0, csvColumns{}, fmt.Errorf("no header row with the mapped columns (date %q, amount %q, debit %q, credit %q)", m.Date, m.Amount, m.Debit, m.Credit)
+}
+
+// ----------------------------------------------------------------------------
+// Row conversion
+// ----------------------------------------------------------------------------
+
+// csvDateLayouts are tried in order; month-first before day-first, with
+// dayFirst pairing the ones that can read the same string both ways.
+var csvDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02",
+	"20060102",
+	"1/2/2006 15:04:05",
+	"1/2/2006 15:04",
+	"1/2/2006",
+	"2/1/2006 15:04:05",
+	"2/1/2006 15:04",
+	"2/1/2006",
+	"1/2/06",
+	"2/1/06",
+	"2.1.2006 15:04",
+	"2.1.2006",
+	"2.1.06",
+	"1-2-2006",
+	"2-1-2006",
+	"2 Jan 2006",
+	"2-Jan-2006",
+	"2-Jan-06",
+	"Jan 2, 2006",
+	"Jan 2 2006",
+}
+
+var dayFirst = map[string]string{
+	"1/2/2006 15:04:05": "2/1/2006 15:04:05",
+	"1/2/2006 15:04":    "2/1/2006 15:04",
+	"1/2/2006":          "2/1/2006",
+	"1/2/06":            "2/1/06",
+	"1-2-2006":          "2-1-2006",
+}
+
+// detectDateLayout picks the layout that reads the most values, warning when
+// every value would also read day-first.
+func detectDateLayout(values []string) (string, string) {
+	count := func(layout string) int {
+		n := 0
+		for _, v := range values {
+			if _, err := time.Parse(layout, v); err == nil {
+				n++
+			}
+		}
+		return n
+	}
+	best, bestCount := "", 0
+	for _, layout := range csvDateLayouts {
+		if n := count(layout); n > bestCount {
+			best, bestCount = layout, n
+		}
+	}
+	if alt, ok := dayFirst[best]; ok && count(alt) == bestCount {
+		return best, fmt.Sprintf("dates read as month-first (%s) but every one is also a valid day-first date; set date_format if that's wrong", best)
+	}
+	return best, ""
+}
+
+func convertCSVRows(result *ImportResult, m CSVMapping, cols csvColumns, headerWidth int, records [][]string, lines []int) {
+	get := func(rec []string, i int) string {
+		if i < 0 || i >= len(rec) {
+			return ""
+		}
+		return strings.TrimSpace(rec[i])
+	}
+
+	layout := m.DateFormat
+	if layout == "" {
+		var dates []string
+		for _, rec := range records {
+			if d := get(rec, cols.date); d != "" {
+				dates = append(dates, d)
+			}
+		}
+		var warning string
+		layout, warning = detectDateLayout(dates)
+		if warning != "" {
+			result.Warnings = append(result.Warnings, warning)
+		}
+	}
+	result.DateFormat = layout
+
+	outValues := make(map[string]bool, len(m.OutValues))
+	for _, v := range m.OutValues {
+		outValues[strings.ToLower(v)] = true
+	}
+	inValues := make(map[string]bool, len(m.InValues))
+	for _, v := range m.InValues {
+		inValues[strings.ToLower(v)] = true
+	}
+	currency := strings.ToUpper(firstNonEmpty(m.DefaultCurrency, "USD"))
+
+	// Rows wider than both the header and the usual row (some banks end
+	// every row with a delimiter) have an unquoted delimiter in a value, so
+	// their columns can't be trusted.
+	widths := make(map[int]int)
+	for _, rec := range records {
+		widths[len(rec)]++
+	}
+	maxWidth := headerWidth
+	for width, n := range widths {
+		if width > maxWidth && n > len(records)/2 {
+			maxWidth = width
+		}
+	}
+
+	for i, rec := range records {
+		line := lines[i]
+		blank := true
+		for _, f := range rec {
+			if strings.TrimSpace(f) != "" {
+				blank = false
+				break
+			}
+		}
+		if blank {
+			continue
+		}
+		fail := func(format string, args ...interface{}) {
+			result.Errors = append(result.Errors, RowError{Line: line, Error: fmt.Sprintf(format, args...)})
+		}
+		if len(rec) > maxWidth {
+			fail("%d fields where the header has %d - is a value missing its quotes?", len(rec), headerWidth)
+			continue
+		}
+
+		raw := get(rec, cols.date)
+		if raw == "" {
+			fail("missing date")
+			continue
+		}
+		if layout == "" {
+			fail("unrecognised date %q; set date_format", raw)
+			continue
+		}
+		ts, err := time.Parse(layout, raw)
+		if err != nil {
+			fail("date %q doesn't match %s", raw, layout)
+			continue
+		}
+		if clock := get(rec, cols.time); clock != "" {
+			for _, tl := range []string{"15:04:05", "15:04"} {
+				if t, err := time.Parse(tl, clock); err == nil {
+					ts = ts.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second)
+					break
+				}
+			}
+		}
+
+		var (
+			amount float64
+			out    bool
+		)
+		if cols.amount >= 0 {
+			v, err := parseAmount(get(rec, cols.amount), m.DecimalComma)
+			if err != nil {
+				fail("amount: %v", err)
+				continue
+			}
+			amount = math.Abs(v)
+			out = v < 0
+			if m.Sign == "positive_out" {
+				out = v > 0
+			}
+		} else {
+			debit, credit := get(rec, cols.debit), get(rec, cols.credit)
+			d, derr := parseAmount(debit, m.DecimalComma)
+			c, cerr := parseAmount(credit, m.DecimalComma)
+			switch {
+			case debit != "" && derr != nil:
+				fail("debit: %v", derr)
+				continue
+			case credit != "" && cerr != nil:
+				fail("credit: %v", cerr)
+				continue
+			case d != 0 && c != 0:
+				fail("both debit (%s) and credit (%s) are set", debit, credit)
+				continue
+			case d != 0:
+				amount, out = math.Abs(d), true
+			default:
+				amount = math.Abs(c)
+			}
+		}
+		if cols.direction >= 0 {
+			dir := strings.ToLower(get(rec, cols.direction))
+			switch {
+			case outValues[dir]:
+				out = true
+			case len(inValues) == 0 || inValues[dir]:
+				out = false
+			default:
+				fail("unknown direction %q", get(rec, cols.direction))
+				continue
+			}
+		}
+		amount = round2(amount)
+		if amount == 0 {
+			fail("amount is zero")
+			continue
+		}
+
+		txType := "receive"
+		if out {
+			txType = "send"
+		}
+		description := get(rec, cols.description)
+		tx := map[string]interface{}{
+			"timestamp":    ts.Format(time.RFC3339),
+			"type":         txType,
+			"amount":       amount,
+			"currency":     strings.ToUpper(firstNonEmpty(get(rec, cols.currency), currency)),
+			"counterparty": firstNonEmpty(get(rec, cols.counterparty), description),
+			"description":  description,
+			"category":     strings.ToLower(get(rec, cols.category)),
+		}
+		if b := get(rec, cols.balance); b != "" {
+			if v, err := parseAmount(b, m.DecimalComma); err == nil {
+				tx["balance_after"] = v
+			}
+		}
+		result.Transactions = append(result.Transactions, tx)
+	}
+}
+
+// parseAmount reads amounts the way banks print them: currency symbols,
+// thousands separators, decimal commas, (1.00) or 1.00- or 1.00 DR for
+// negatives.
+func parseAmount(s string, decimalComma bool) (float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, errors.New("empty")
+	}
+	orig := s
+	negative := false
+	upper := strings.ToUpper(s)
+	switch {
+	case strings.HasSuffix(upper, "DR"):
+		negative, s = true, strings.TrimSpace(s[:len(s)-2])
+	case strings.HasSuffix(upper, "CR"):
+		s = strings.TrimSpace(s[:len(s)-2])
+	}
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative, s = true, s[1:len(s)-1]
+	}
+	if strings.HasSuffix(s, "-") {
+		negative, s = true, s[:len(s)-1]
+	}
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9', r == '.', r == ',', r == '-', r == '+':
+			return r
+		}
+		return -1 // currency symbols, spaces, apostrophes
+	}, s)
+	if strings.HasPrefix(s, "-") {
+		negative, s = !negative, s[1:]
+	}
+	s = strings.TrimPrefix(s, "+")
+
+	dot, comma := strings.LastIndex(s, "."), strings.LastIndex(s, ",")
+	switch {
+	case decimalComma || (dot >= 0 && comma > dot):
+		s = strings.ReplaceAll(s, ".", "")
+		s = strings.Replace(s, ",", ".", 1)
+	case comma >= 0 && dot < 0 && strings.Count(s, ",") == 1 && len(s)-comma-1 <= 2:
+		s = strings.Replace(s, ",", ".", 1) // 12,50
+	default:
+		s = strings.ReplaceAll(s, ",", "")
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0, fmt.Errorf("%q is not a number", orig)
+	}
+	if negative {
+		v = -v
+	}
+	return v, nil
+}
+
+// logImportErrors reports skipped rows without flooding the log.
+func logImportErrors(path string, result *ImportResult) {
+	if len(result.Errors) == 0 {
+		return
+	}
+	shown := result.Errors
+	if len(shown) > 3 {
+		shown = shown[:3]
+	}
+	var parts []string
+	for _, e := range shown {
+		parts = append(parts, fmt.Sprintf("line %d: %s", e.Line, e.Error))
+	}
+	more := ""
+	if len(result.Errors) > len(shown) {
+		more = fmt.Sprintf(" (and %d more)", len(result.Errors)-len(shown))
+	}
+	log.Printf("⚠️  Skipped %d rows of %s: %s%s", len(result.Errors), path, strings.Join(parts, "; "), more)
+}
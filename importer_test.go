@@ -0,0 +1,7 @@
+package main
+
+import "testing"
+
+func TestCSVFixtures(t *testing.T) {
+	checkImportFixtures(t, "*.csv")
+}
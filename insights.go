@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+	"github.com/becomeliminal/nim-go-sdk/tools"
+)
+
+// ============================================================================
+// INSIGHT QUEUE
+// ============================================================================
+// Proactive insights produced in the background wait here until the user next
+// connects (the model picks them up with get_pending_insights). Subscribers
+// are told about every new insight so it can also be pushed out right away.
+
+// Insight is a proactive message for the user.
+type Insight struct {
+	ID          string                 `json:"id"`
+	Type        string                 `json:"type"`
+	Severity    string                 `json:"severity"` // info, warning, celebrate
+	Title       string                 `json:"title"`
+	Message     string                 `json:"message"`
+	Data        map[string]interface{} `json:"data,omitempty"`
+	DedupKey    string                 `json:"dedup_key,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+	DeliveredAt *time.Time             `json:"delivered_at,omitempty"`
+}
+
+// maxInsightsPerUser bounds the history kept for each user.
+const maxInsightsPerUser = 200
+
+// insightQueue stores insights per user.
+type insightQueue struct {
+	store *jsonStore
+
+	mu          sync.Mutex
+	insights    map[string][]Insight
+	subscribers []func(userID string, insight Insight)
+}
+
+func newInsightQueue() *insightQueue {
+	q := &insightQueue{
+		store:    newJSONStore("insights.json"),
+		insights: make(map[string][]Insight),
+	}
+	if err := q.store.load(&q.insights); err != nil {
+		log.Printf("⚠️  Insight queue not loaded: %v", err)
+	}
+	return q
+}
+
+// subscribe registers fn to be called for every newly queued insight.
+func (q *insightQueue) subscribe(fn func(userID string, insight Insight)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.subscribers = append(q.subscribers, fn)
+}
+
+// push queues an insight. Insights with a DedupKey already seen for the user
+// are dropped, so a trigger can fire on every poll without spamming.
+func (q *insightQueue) push(userID string, insight Insight) bool {
+	q.mu.Lock()
+	if insight.DedupKey != "" {
+		for _, existing := range q.insights[userID] {
+			if existing.DedupKey == insight.DedupKey {
+				q.mu.Unlock()
+				return false
+			}
+		}
+	}
+	insight.ID = fmt.Sprintf("ins-%d", time.Now().UnixNano())
+	insight.CreatedAt = time.Now()
+
+	list := append(q.insights[userID], insight)
+	if len(list) > maxInsightsPerUser {
+		list = list[len(list)-maxInsightsPerUser:]
+	}
+	q.insights[userID] = list
+	if err := q.store.save(q.insights); err != nil {
+		log.Printf("⚠️  Failed to save insight queue: %v", err)
+	}
+	subscribers := append([]func(userID string, insight Insight){}, q.subscribers...)
+	q.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(userID, insight)
+	}
+	return true
+}
+
+// pending returns undelivered insights, oldest first. When markDelivered is
+// set they are marked as delivered.
+func (q *insightQueue) pending(userID string, markDelivered bool) []Insight {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var out []Insight
+	now := time.Now()
+	list := q.insights[userID]
+	for i := range list {
+		if list[i].DeliveredAt != nil {
+			continue
+		}
+		out = append(out, list[i])
+		if markDelivered {
+			list[i].DeliveredAt = &now
+		}
+	}
+	if markDelivered && len(out) > 0 {
+		if err := q.store.save(q.insights); err != nil {
+			log.Printf("⚠️  Failed to save insight queue: %v", err)
+		}
+	}
+	return out
+}
+
+func createPendingInsightsTool(insights *insightQueue) core.Tool {
+	return tools.New("get_pending_insights").
+		Description("Get proactive insights NeuraPay noticed while the user was away (low balance forecasts, large transactions, interest earned, goals reached). Call this at the start of a conversation and weave them into the greeting.").
+		Schema(tools.ObjectSchema(map[string]interface{}{
+			"peek": tools.BooleanProperty("Return insights without marking them as delivered (default: false)"),
+		})).
+		Handler(func(ctx context.Context, toolParams *core.ToolParams) (*core.ToolResult, error) {
+			var params struct {
+				Peek bool `json:"peek"`
+			}
+			if err := json.Unmarshal(toolParams.Input, &params); err != nil {
+				return &core.ToolResult{
+					Success: false,
+					Error:   fmt.Sprintf("invalid input: %v", err),
+				}, nil
+			}
+
+			pending := insights.pending(toolParams.UserID, !params.Peek)
+			return &core.ToolResult{
+				Success: true,
+				Data: map[string]interface{}{
+					"insights": pending,
+					"count":    len(pending),
+				},
+			}, nil
+		}).
+		Build()
+}
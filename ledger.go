@@ -0,0 +1,800 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+	"github.com/becomeliminal/nim-go-sdk/tools"
+)
+
+// ============================================================================
+// SHARED EXPENSES LEDGER
+// ============================================================================
+// "I paid for dinner, @bob owes me half." Each user keeps their own ledger of
+// expenses shared with other people, optionally in groups ("flat", "ski
+// trip"). People are @tags, or plain names for friends who aren't on
+// Liminal; the user is "me". Balances are worked out in cents:
+//
+//   - pairwise: what each person owes the user directly, from the expenses
+//     they shared and the settlements between them
+//   - simplified: the fewest transfers that square everyone in a group,
+//     which may route money past the person who paid (@bob owes me, I owe
+//     @carol -> @bob pays @carol)
+//
+// Nothing moves money by itself: settle_up proposes send_money calls the
+// model puts to the user, and records the settlement once the user confirms
+// - the same propose-then-confirm flow as the payday auto-save. The user
+// only ever settles their own pairs, so every settlement squares the debt
+// it was proposed for; the simplified plan is a suggestion for the group,
+// since the user can't record a payment between two other people.
+
+// me is how the ledger's owner appears among the people in an expense.
+const me = "me"
+
+// SharedExpense is one bill split between people. Shares add up to Amount;
+// the payer's own share is included when they took part.
+type SharedExpense struct {
+	ID          string             `json:"id"`
+	Group       string             `json:"group,omitempty"`
+	Description string             `json:"description"`
+	PaidBy      string             `json:"paid_by"`
+	Amount      float64            `json:"amount"`
+	Currency    string             `json:"currency"`
+	Shares      map[string]float64 `json:"shares"`
+	Date        time.Time          `json:"date"`
+	CreatedAt   time.Time          `json:"created_at"`
+}
+
+// Settlement is a payment that squares (part of) a debt. Only confirmed
+// settlements count towards balances.
+type Settlement struct {
+	ID        string     `json:"id"`
+	From      string     `json:"from"`
+	To        string     `json:"to"`
+	Amount    float64    `json:"amount"`
+	Currency  string     `json:"currency"`
+	Group     string     `json:"group,omitempty"` // empty when settled across groups
+	Status    string     `json:"status"`          // proposed, confirmed, declined, replaced
+	CreatedAt time.Time  `json:"created_at"`
+	SettledAt *time.Time `json:"settled_at,omitempty"`
+}
+
+// Ledger is one user's shared expenses.
+type Ledger struct {
+	Expenses    []SharedExpense `json:"expenses"`
+	Settlements []Settlement    `json:"settlements"`
+	NextID      int             `json:"next_id"`
+}
+
+// Transfer is one payment in a simplified plan.
+type Transfer struct {
+	From     string  `json:"from"`
+	To       string  `json:"to"`
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
+// PairBalance is what one person owes the user (negative: the user owes
+// them) in one currency.
+type PairBalance struct {
+	With     string  `json:"with"`
+	Currency string  `json:"currency"`
+	Net      float64 `json:"net"`
+	Summary  string  `json:"summary"`
+}
+
+// Position is where a person stands in a group: positive when they are owed
+// money.
+type Position struct {
+	Person   string  `json:"person"`
+	Currency string  `json:"currency"`
+	Net      float64 `json:"net"`
+}
+
+// ledgerPerson normalizes a person's name; "me", "I" and "myself" are the
+// ledger's owner.
+func ledgerPerson(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "me", "i", "myself", "self":
+		return me
+	}
+	return name
+}
+
+// cents converts an amount to whole cents.
+func cents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// fromCents converts cents back to an amount.
+func fromCents(c int64) float64 {
+	return float64(c) / 100
+}
+
+// splitShares works out who owes what for an expense: an equal split
+// between people, or explicit shares like "@bob:30,me:20" (amounts) or
+// "@bob:60%,me:40%" that must add up to the amount or 100%. Cents left over
+// by an equal split go to the first people listed.
+func splitShares(amount int64, people []string, shares string) (map[string]int64, error) {
+	out := make(map[string]int64)
+	if strings.TrimSpace(shares) == "" {
+		if len(people) == 0 {
+			return nil, errors.New("no one to split with")
+		}
+		each, rest := amount/int64(len(people)), amount%int64(len(people))
+		for i, p := range people {
+			out[p] = each
+			if int64(i) < rest {
+				out[p]++
+			}
+		}
+		return out, nil
+	}
+
+	var (
+		percent   bool
+		total     int64
+		remaining = amount
+		order     []string
+	)
+	parsed := make(map[string]float64)
+	for _, part := range strings.Split(shares, ",") {
+		name, value, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("share %q should be person:amount", strings.TrimSpace(part))
+		}
+		person := ledgerPerson(name)
+		value = strings.TrimSpace(value)
+		isPercent := strings.HasSuffix(value, "%")
+		if len(order) > 0 && isPercent != percent {
+			return nil, errors.New("shares must be all amounts or all percentages")
+		}
+		percent = isPercent
+		v, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("share %q is not an amount", strings.TrimSpace(part))
+		}
+		if _, dup := parsed[person]; dup {
+			return nil, fmt.Errorf("%s is listed twice in shares", person)
+		}
+		parsed[person] = v
+		order = append(order, person)
+	}
+	for i, person := range order {
+		var c int64
+		switch {
+		case !percent:
+			c = cents(parsed[person])
+		case i == len(order)-1:
+			c = remaining // rounding lands on the last share
+		default:
+			c = int64(math.Round(float64(amount) * parsed[person] / 100))
+		}
+		out[person] = c
+		total += c
+		remaining -= c
+	}
+	if percent {
+		sum := 0.0
+		for _, v := range parsed {
+			sum += v
+		}
+		if math.Abs(sum-100) > 0.01 {
+			return nil, fmt.Errorf("percentages add up to %.2f%%, not 100%%", sum)
+		}
+	} else if total != amount {
+		return nil, fmt.Errorf("shares add up to %.2f, not %.2f", fromCents(total), fromCents(amount))
+	}
+	return out, nil
+}
+
+// pairwise returns what each person owes the user directly, per currency,
+// in cents. Only expenses and settlements in group count when group is set.
+func (l *Ledger) pairwise(group string) map[string]map[string]int64 {
+	owed := make(map[string]map[string]int64) // person -> currency -> cents
+	add := func(person, currency string, c int64) {
+		if owed[person] == nil {
+			owed[person] = make(map[string]int64)
+		}
+		owed[person][currency] += c
+	}
+	for _, e := range l.Expenses {
+		if group != "" && e.Group != group {
+			continue
+		}
+		for person, share := range e.Shares {
+			switch {
+			case person == e.PaidBy:
+			case e.PaidBy == me:
+				add(person, e.Currency, cents(share))
+			case person == me:
+				add(e.PaidBy, e.Currency, -cents(share))
+			}
+		}
+	}
+	for _, s := range l.Settlements {
+		if s.Status != "confirmed" || (group != "" && s.Group != group) {
+			continue
+		}
+		switch {
+		case s.From == me:
+			add(s.To, s.Currency, cents(s.Amount))
+		case s.To == me:
+			add(s.From, s.Currency, -cents(s.Amount))
+		}
+	}
+	return owed
+}
+
+// positions returns each person's net position per currency, in cents:
+// what they paid, for bills and in settlements, minus their shares and the
+// settlements they received.
+func (l *Ledger) positions(group string) map[string]map[string]int64 {
+	net := make(map[string]map[string]int64) // currency -> person -> cents
+	add := func(currency, person string, c int64) {
+		if net[currency] == nil {
+			net[currency] = make(map[string]int64)
+		}
+		net[currency][person] += c
+	}
+	for _, e := range l.Expenses {
+		if group != "" && e.Group != group {
+			continue
+		}
+		add(e.Currency, e.PaidBy, cents(e.Amount))
+		for person, share := range e.Shares {
+			add(e.Currency, person, -cents(share))
+		}
+	}
+	for _, s := range l.Settlements {
+		if s.Status != "confirmed" || (group != "" && s.Group != group) {
+			continue
+		}
+		add(s.Currency, s.From, cents(s.Amount))
+		add(s.Currency, s.To, -cents(s.Amount))
+	}
+	return net
+}
+
+// simplify turns net positions into the fewest transfers that settle them,
+// matching the largest debtor with the largest creditor until everyone is
+// square.
+func simplify(positions map[string]map[string]int64) []Transfer {
+	type balance struct {
+		person string
+		cents  int64
+	}
+	currencies := make([]string, 0, len(positions))
+	for c := range positions {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+
+	var out []Transfer
+	for _, currency := range currencies {
+		var debtors, creditors []balance
+		for person, c := range positions[currency] {
+			switch {
+			case c < 0:
+				debtors = append(debtors, balance{person, -c})
+			case c > 0:
+				creditors = append(creditors, balance{person, c})
+			}
+		}
+		largest := func(b []balance) func(i, j int) bool {
+			return func(i, j int) bool {
+				if b[i].cents != b[j].cents {
+					return b[i].cents > b[j].cents
+				}
+				return b[i].person < b[j].person
+			}
+		}
+		sort.Slice(debtors, largest(debtors))
+		sort.Slice(creditors, largest(creditors))
+		for len(debtors) > 0 && len(creditors) > 0 {
+			d, c := &debtors[0], &creditors[0]
+			amount := d.cents
+			if c.cents < amount {
+				amount = c.cents
+			}
+			out = append(out, Transfer{From: d.person, To: c.person, Amount: fromCents(amount), Currency: currency})
+			d.cents -= amount
+			c.cents -= amount
+			if d.cents == 0 {
+				debtors = debtors[1:]
+			}
+			if c.cents == 0 {
+				creditors = creditors[1:]
+			}
+			sort.Slice(debtors, largest(debtors))
+			sort.Slice(creditors, largest(creditors))
+		}
+	}
+	return out
+}
+
+// pairSummary says who owes whom in words.
+func pairSummary(person, currency string, c int64) string {
+	switch {
+	case c > 0:
+		return fmt.Sprintf("%s owes you %s", person, formatMoney(fromCents(c), currency))
+	case c < 0:
+		return fmt.Sprintf("You owe %s %s", person, formatMoney(fromCents(-c), currency))
+	}
+	return fmt.Sprintf("You and %s are square", person)
+}
+
+// ledgerService owns the shared-expense ledgers of all users.
+type ledgerService struct {
+	store *jsonStore
+
+	mu      sync.Mutex
+	ledgers map[string]*Ledger
+}
+
+func newLedgerService() *ledgerService {
+	s := &ledgerService{
+		store:   newJSONStore("ledger.json"),
+		ledgers: make(map[string]*Ledger),
+	}
+	if err := s.store.load(&s.ledgers); err != nil {
+		log.Printf("⚠️  Shared expenses not loaded: %v", err)
+	}
+	return s
+}
+
+func (s *ledgerService) ledger(userID string) *Ledger {
+	l, ok := s.ledgers[userID]
+	if !ok {
+		l = &Ledger{}
+		s.ledgers[userID] = l
+	}
+	return l
+}
+
+func (s *ledgerService) persist() {
+	if err := s.store.save(s.ledgers); err != nil {
+		log.Printf("⚠️  Failed to save shared expenses: %v", err)
+	}
+}
+
+// balances describes where the user stands, optionally with one person
+// and in one group.
+func (l *Ledger) balances(with, group string) map[string]interface{} {
+	pairs := []PairBalance{}
+	owed := l.pairwise(group)
+	people := make([]string, 0, len(owed))
+	for person := range owed {
+		people = append(people, person)
+	}
+	sort.Strings(people)
+	for _, person := range people {
+		if with != "" && person != with {
+			continue
+		}
+		currencies := make([]string, 0, len(owed[person]))
+		for c := range owed[person] {
+			currencies = append(currencies, c)
+		}
+		sort.Strings(currencies)
+		for _, currency := range currencies {
+			c := owed[person][currency]
+			pairs = append(pairs, PairBalance{With: person, Currency: currency, Net: fromCents(c), Summary: pairSummary(person, currency, c)})
+		}
+	}
+
+	positions := []Position{}
+	net := l.positions(group)
+	for currency, byPerson := range net {
+		for person, c := range byPerson {
+			if c != 0 {
+				positions = append(positions, Position{Person: person, Currency: currency, Net: fromCents(c)})
+			}
+		}
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		if positions[i].Currency != positions[j].Currency {
+			return positions[i].Currency < positions[j].Currency
+		}
+		return positions[i].Net > positions[j].Net
+	})
+
+	plan := []Transfer{}
+	for _, t := range simplify(net) {
+		if with == "" || t.From == with || t.To == with {
+			plan = append(plan, t)
+		}
+	}
+
+	recent := []SharedExpense{}
+	for i := len(l.Expenses) - 1; i >= 0 && len(recent) < 10; i-- {
+		e := l.Expenses[i]
+		if group != "" && e.Group != group {
+			continue
+		}
+		if _, involved := e.Shares[with]; with != "" && !involved && e.PaidBy != with {
+			continue
+		}
+		recent = append(recent, e)
+	}
+	pending := []Settlement{}
+	for _, s := range l.Settlements {
+		if s.Status == "proposed" && (with == "" || s.From == with || s.To == with) {
+			pending = append(pending, s)
+		}
+	}
+
+	return map[string]interface{}{
+		"balances":            pairs,
+		"positions":           positions,
+		"simplified":          plan,
+		"recent_expenses":     recent,
+		"pending_settlements": pending,
+	}
+}
+
+// propose records a settlement the user still has to confirm, replacing any
+// earlier proposal between the same people.
+func (l *Ledger) propose(from, to, currency, group string, amount int64, now time.Time) Settlement {
+	for i := range l.Settlements {
+		s := &l.Settlements[i]
+		if s.Status == "proposed" && s.From == from && s.To == to && s.Currency == currency && s.Group == group {
+			s.Status = "replaced"
+		}
+	}
+	l.NextID++
+	s := Settlement{
+		ID:        fmt.Sprintf("st-%d", l.NextID),
+		From:      from,
+		To:        to,
+		Amount:    fromCents(amount),
+		Currency:  currency,
+		Group:     group,
+		Status:    "proposed",
+		CreatedAt: now,
+	}
+	l.Settlements = append(l.Settlements, s)
+	return s
+}
+
+// settlementProposal is what settle_up hands the model for one settlement.
+func settlementProposal(s Settlement) map[string]interface{} {
+	p := map[string]interface{}{
+		"settlement_id": s.ID,
+		"amount":        s.Amount,
+		"currency":      s.Currency,
+	}
+	if s.Group != "" {
+		p["group"] = s.Group
+	}
+	if s.From == me {
+		p["direction"] = "pay"
+		p["to"] = s.To
+		p["summary"] = fmt.Sprintf("Send %s to %s", formatMoney(s.Amount, s.Currency), s.To)
+		if strings.HasPrefix(s.To, "@") {
+			p["send_money"] = map[string]interface{}{
+				"recipient": s.To,
+				"amount":    fmt.Sprintf("%.2f", s.Amount),
+				"currency":  s.Currency,
+				"note":      firstNonEmpty(s.Group, "shared expenses") + " - settle up",
+			}
+		} else {
+			p["note"] = s.To + " is not on Liminal - pay them another way, then confirm"
+		}
+		return p
+	}
+	p["direction"] = "collect"
+	p["from"] = s.From
+	p["summary"] = fmt.Sprintf("%s should send you %s", s.From, formatMoney(s.Amount, s.Currency))
+	return p
+}
+
+// ============================================================================
+// CUSTOM TOOLS: SHARED EXPENSES
+// ============================================================================
+
+func createRecordSharedExpenseTool(ledgers *ledgerService) core.Tool {
+	return tools.New("record_shared_expense").
+		Description("Record a bill the user shared with other people (\"I paid $60 for dinner, split with @bob\"), or that someone else paid for the user. Splits equally unless shares are given. Also removes a mistaken expense with remove_id.").
+		Schema(tools.ObjectSchema(map[string]interface{}{
+			"amount":      tools.NumberProperty("Total amount of the bill"),
+			"currency":    tools.StringProperty("Currency (default: USD)"),
+			"description": tools.StringProperty("What it was for, e.g. dinner"),
+			"paid_by":     tools.StringProperty("Who paid: me (default) or an @tag"),
+			"split_with":  tools.StringProperty("Comma-separated @tags (or names) sharing the bill, besides the user"),
+			"include_me":  tools.BooleanProperty("Whether the user has a share (default: true; false when they paid on someone else's behalf)"),
+			"shares":      tools.StringProperty("Uneven split, e.g. '@bob:40,me:20' or '@bob:60%,me:40%' (default: equal)"),
+			"group":       tools.StringProperty("Group the expense belongs to, e.g. flat or ski trip"),
+			"date":        tools.StringProperty("When it happened, YYYY-MM-DD (default: today)"),
+			"remove_id":   tools.StringProperty("ID of an expense to remove instead of recording one"),
+		})).
+		Handler(func(ctx context.Context, toolParams *core.ToolParams) (*core.ToolResult, error) {
+			var params struct {
+				Amount      float64 `json:"amount"`
+				Currency    string  `json:"currency"`
+				Description string  `json:"description"`
+				PaidBy      string  `json:"paid_by"`
+				SplitWith   string  `json:"split_with"`
+				IncludeMe   *bool   `json:"include_me"`
+				Shares      string  `json:"shares"`
+				Group       string  `json:"group"`
+				Date        string  `json:"date"`
+				RemoveID    string  `json:"remove_id"`
+			}
+			if err := json.Unmarshal(toolParams.Input, &params); err != nil {
+				return &core.ToolResult{
+					Success: false,
+					Error:   fmt.Sprintf("invalid input: %v", err),
+				}, nil
+			}
+
+			ledgers.mu.Lock()
+			defer ledgers.mu.Unlock()
+			l := ledgers.ledger(toolParams.UserID)
+
+			if params.RemoveID != "" {
+				for i, e := range l.Expenses {
+					if e.ID == params.RemoveID {
+						l.Expenses = append(l.Expenses[:i], l.Expenses[i+1:]...)
+						ledgers.persist()
+						return &core.ToolResult{Success: true, Data: map[string]interface{}{"removed": e}}, nil
+					}
+				}
+				return &core.ToolResult{Success: false, Error: fmt.Sprintf("no expense %q", params.RemoveID)}, nil
+			}
+
+			amount := cents(params.Amount)
+			if amount <= 0 {
+				return &core.ToolResult{Success: false, Error: "amount must be positive"}, nil
+			}
+			currency := strings.ToUpper(firstNonEmpty(params.Currency, "USD"))
+			paidBy := ledgerPerson(firstNonEmpty(params.PaidBy, me))
+			date := time.Now()
+			if params.Date != "" {
+				d, err := time.Parse("2006-01-02", params.Date)
+				if err != nil {
+					return &core.ToolResult{Success: false, Error: fmt.Sprintf("date %q should be YYYY-MM-DD", params.Date)}, nil
+				}
+				date = d
+			}
+
+			var people []string
+			seen := make(map[string]bool)
+			addPerson := func(p string) {
+				if p != "" && !seen[p] {
+					seen[p] = true
+					people = append(people, p)
+				}
+			}
+			if params.IncludeMe == nil || *params.IncludeMe {
+				addPerson(me)
+			}
+			for _, p := range strings.Split(params.SplitWith, ",") {
+				addPerson(ledgerPerson(p))
+			}
+			shares, err := splitShares(amount, people, params.Shares)
+			if err != nil {
+				return &core.ToolResult{Success: false, Error: err.Error()}, nil
+			}
+			owing := 0
+			for person, share := range shares {
+				if person != paidBy && share > 0 {
+					owing++
+				}
+			}
+			if owing == 0 {
+				return &core.ToolResult{Success: false, Error: "nobody owes anything for this expense - add split_with or check paid_by"}, nil
+			}
+			if _, ok := shares[me]; !ok && paidBy != me && params.Group == "" {
+				return &core.ToolResult{Success: false, Error: "the user is neither paying nor sharing this expense - give it a group to track other people's debts"}, nil
+			}
+
+			l.NextID++
+			expense := SharedExpense{
+				ID:          fmt.Sprintf("ex-%d", l.NextID),
+				Group:       strings.ToLower(strings.TrimSpace(params.Group)),
+				Description: firstNonEmpty(strings.TrimSpace(params.Description), "shared expense"),
+				PaidBy:      paidBy,
+				Amount:      fromCents(amount),
+				Currency:    currency,
+				Shares:      make(map[string]float64, len(shares)),
+				Date:        date,
+				CreatedAt:   time.Now(),
+			}
+			for person, share := range shares {
+				expense.Shares[person] = fromCents(share)
+			}
+			l.Expenses = append(l.Expenses, expense)
+			ledgers.persist()
+
+			// Where the user now stands with everyone on this bill.
+			involved := []string{paidBy}
+			for person := range shares {
+				involved = append(involved, person)
+			}
+			sort.Strings(involved)
+			var summaries []string
+			owed := l.pairwise("")
+			for i, person := range involved {
+				if person != me && (i == 0 || involved[i-1] != person) {
+					summaries = append(summaries, pairSummary(person, currency, owed[person][currency]))
+				}
+			}
+			return &core.ToolResult{
+				Success: true,
+				Data: map[string]interface{}{
+					"expense":  expense,
+					"balances": summaries,
+				},
+			}, nil
+		}).
+		Build()
+}
+
+func createBalancesWithTool(ledgers *ledgerService) core.Tool {
+	return tools.New("get_balances_with").
+		Description("Show who owes the user and whom the user owes from shared expenses: the balance with each person, everyone's position in a group, and the fewest transfers that would settle everything. Filter by person and/or group.").
+		Schema(tools.ObjectSchema(map[string]interface{}{
+			"with":  tools.StringProperty("Only this person, e.g. @bob (default: everyone)"),
+			"group": tools.StringProperty("Only this group (default: all expenses)"),
+		})).
+		Handler(func(ctx context.Context, toolParams *core.ToolParams) (*core.ToolResult, error) {
+			var params struct {
+				With  string `json:"with"`
+				Group string `json:"group"`
+			}
+			if err := json.Unmarshal(toolParams.Input, &params); err != nil {
+				return &core.ToolResult{
+					Success: false,
+					Error:   fmt.Sprintf("invalid input: %v", err),
+				}, nil
+			}
+
+			ledgers.mu.Lock()
+			defer ledgers.mu.Unlock()
+			l := ledgers.ledger(toolParams.UserID)
+			with := ledgerPerson(params.With)
+			if with == me {
+				with = ""
+			}
+			result := l.balances(with, strings.ToLower(strings.TrimSpace(params.Group)))
+			if len(l.Expenses) == 0 {
+				result["note"] = "No shared expenses recorded yet"
+			}
+			return &core.ToolResult{Success: true, Data: result}, nil
+		}).
+		Build()
+}
+
+func createSettleUpTool(ledgers *ledgerService) core.Tool {
+	return tools.New("settle_up").
+		Description("Work out the payments that settle the user's shared expenses - with one person, in one group, or with everyone - and turn what the user owes into send_money calls for them to confirm. Also used to mark a proposed settlement as confirmed (after send_money succeeded, or once the other person paid) or declined.").
+		Schema(tools.ObjectSchema(map[string]interface{}{
+			"with":          tools.StringProperty("Settle with this person, e.g. @bob (default: everyone)"),
+			"group":         tools.StringProperty("Only settle this group (default: all expenses)"),
+			"settlement_id": tools.StringProperty("A proposed settlement the user just responded to"),
+			"decision":      tools.StringProperty("confirmed (after send_money succeeded or the other person paid) or declined"),
+		})).
+		Handler(func(ctx context.Context, toolParams *core.ToolParams) (*core.ToolResult, error) {
+			var params struct {
+				With         string `json:"with"`
+				Group        string `json:"group"`
+				SettlementID string `json:"settlement_id"`
+				Decision     string `json:"decision"`
+			}
+			if err := json.Unmarshal(toolParams.Input, &params); err != nil {
+				return &core.ToolResult{
+					Success: false,
+					Error:   fmt.Sprintf("invalid input: %v", err),
+				}, nil
+			}
+
+			ledgers.mu.Lock()
+			defer ledgers.mu.Unlock()
+			l := ledgers.ledger(toolParams.UserID)
+			now := time.Now()
+
+			if params.SettlementID != "" {
+				if params.Decision != "confirmed" && params.Decision != "declined" {
+					return &core.ToolResult{Success: false, Error: "decision must be confirmed or declined"}, nil
+				}
+				for i := range l.Settlements {
+					s := &l.Settlements[i]
+					if s.ID != params.SettlementID || s.Status != "proposed" {
+						continue
+					}
+					s.Status = params.Decision
+					if s.Status == "confirmed" {
+						s.SettledAt = &now
+					}
+					ledgers.persist()
+					other := s.To
+					if s.To == me {
+						other = s.From
+					}
+					result := l.balances(other, s.Group)
+					result["settlement"] = *s
+					return &core.ToolResult{Success: true, Data: result}, nil
+				}
+				return &core.ToolResult{Success: false, Error: fmt.Sprintf("no proposed settlement %q", params.SettlementID)}, nil
+			}
+
+			with := ledgerPerson(params.With)
+			if with == me {
+				with = ""
+			}
+			group := strings.ToLower(strings.TrimSpace(params.Group))
+
+			// Settle what is owed between the user and each person (or just
+			// the one asked for).
+			var transfers []Transfer
+			for person, byCurrency := range l.pairwise(group) {
+				if with != "" && person != with {
+					continue
+				}
+				for currency, c := range byCurrency {
+					switch {
+					case c < 0:
+						transfers = append(transfers, Transfer{From: me, To: person, Amount: fromCents(-c), Currency: currency})
+					case c > 0:
+						transfers = append(transfers, Transfer{From: person, To: me, Amount: fromCents(c), Currency: currency})
+					}
+				}
+			}
+			other := func(t Transfer) string {
+				if t.From == me {
+					return t.To
+				}
+				return t.From
+			}
+			sort.Slice(transfers, func(i, j int) bool {
+				if other(transfers[i]) != other(transfers[j]) {
+					return other(transfers[i]) < other(transfers[j])
+				}
+				return transfers[i].Currency < transfers[j].Currency
+			})
+
+			proposals := []map[string]interface{}{}
+			for _, t := range transfers {
+				s := l.propose(t.From, t.To, t.Currency, group, cents(t.Amount), now)
+				proposals = append(proposals, settlementProposal(s))
+			}
+			if len(proposals) > 0 {
+				ledgers.persist()
+			}
+
+			result := map[string]interface{}{"settlements": proposals}
+			if with == "" {
+				// Routed transfers need the others to pay each other, so
+				// they are only ever a suggestion.
+				plan := simplify(l.positions(group))
+				for _, t := range plan {
+					if t.From != me && t.To != me {
+						result["group_suggestion"] = plan
+						break
+					}
+				}
+			}
+			switch {
+			case len(proposals) == 0 && with != "":
+				result["note"] = fmt.Sprintf("You and %s are square", with)
+			case len(proposals) == 0:
+				result["note"] = "Nothing to settle - you're square with everyone"
+			default:
+				result["note"] = "Offer each payment to the user; on yes call send_money with exactly the send_money input, then settle_up with its settlement_id and decision=confirmed. For collect, confirm once the other person has paid. A group_suggestion is the fewest transfers for the whole group - share it, but only the user's own settlements can be recorded."
+			}
+			return &core.ToolResult{Success: true, Data: result}, nil
+		}).
+		Build()
+}
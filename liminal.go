@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+)
+
+// ============================================================================
+// LIMINAL HELPERS
+// ============================================================================
+// Small wrappers around the Liminal executor and the loosely typed
+// transaction maps it returns. Custom tools and background automations use
+// these so they all read transactions the same way.
+
+// callLiminal executes a Liminal tool on behalf of a user and decodes the
+// response data into a generic map.
+func callLiminal(ctx context.Context, liminalExecutor core.ToolExecutor, userID, requestID, tool string, input interface{}) (map[string]interface{}, error) {
+	inputJSON, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s input: %w", tool, err)
+	}
+
+	response, err := liminalExecutor.Execute(ctx, &core.ExecuteRequest{
+		UserID:    userID,
+		Tool:      tool,
+		Input:     inputJSON,
+		RequestID: requestID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s: %w", tool, err)
+	}
+	if !response.Success {
+		return nil, fmt.Errorf("%s failed: %s", tool, response.Error)
+	}
+
+	data := make(map[string]interface{})
+	if len(response.Data) > 0 {
+		if err := json.Unmarshal(response.Data, &data); err != nil {
+			return nil, fmt.Errorf("failed to parse %s response: %w", tool, err)
+		}
+	}
+	return data, nil
+}
+
+// fetchTransactions pulls up to limit transactions from Liminal.
+func fetchTransactions(ctx context.Context, liminalExecutor core.ToolExecutor, userID, requestID string, limit int) ([]map[string]interface{}, error) {
+	txData, err := callLiminal(ctx, liminalExecutor, userID, requestID, "get_transactions", map[string]interface{}{
+		"limit": limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var transactions []map[string]interface{}
+	if txArray, ok := txData["transactions"].([]interface{}); ok {
+		for _, tx := range txArray {
+			if txMap, ok := tx.(map[string]interface{}); ok {
+				transactions = append(transactions, txMap)
+			}
+		}
+	}
+	return transactions, nil
+}
+
+// loadToolTransactions returns the transactions a custom tool should analyze:
+// the local CSV when useCSV is set, Liminal otherwise.
+func loadToolTransactions(ctx context.Context, liminalExecutor core.ToolExecutor, toolParams *core.ToolParams, useCSV bool) ([]map[string]interface{}, error) {
+	if useCSV {
+		transactions, err := loadTransactionsFromCSV(csvTransactionsPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load CSV: %w", err)
+		}
+		return transactions, nil
+	}
+	return fetchTransactions(ctx, liminalExecutor, toolParams.UserID, toolParams.RequestID, transactionFetchLimit)
+}
+
+// depositSavings moves money from the wallet into savings.
+func depositSavings(ctx context.Context, liminalExecutor core.ToolExecutor, userID, requestID string, amount float64, currency string) error {
+	_, err := callLiminal(ctx, liminalExecutor, userID, requestID, "deposit_savings", map[string]interface{}{
+		"amount":   fmt.Sprintf("%.2f", amount),
+		"currency": currency,
+	})
+	return err
+}
+
+// withdrawSavings moves money from savings back to the wallet.
+func withdrawSavings(ctx context.Context, liminalExecutor core.ToolExecutor, userID, requestID string, amount float64, currency string) error {
+	_, err := callLiminal(ctx, liminalExecutor, userID, requestID, "withdraw_savings", map[string]interface{}{
+		"amount":   fmt.Sprintf("%.2f", amount),
+		"currency": currency,
+	})
+	return err
+}
+
+// numberValue reads a number that may be encoded as a JSON number or string.
+func numberValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// balanceTotal reads the balance in one currency from a get_balance or
+// get_savings_balance response. Responses carry either a top-level amount or
+// a list of per-currency balances/positions; only entries in currency are
+// summed, and entries that don't name a currency count as that currency.
+func balanceTotal(data map[string]interface{}, currency string) float64 {
+	totals := balanceTotals(data)
+	return totals[strings.ToUpper(currency)] + totals[""]
+}
+
+// balanceTotals reads every currency's balance from a get_balance or
+// get_savings_balance response, keyed by currency code ("" when the
+// response doesn't say).
+func balanceTotals(data map[string]interface{}) map[string]float64 {
+	totals := make(map[string]float64)
+	currency := strings.ToUpper(txString(data, "currency"))
+	for _, key := range []string{"total", "balance", "available", "amount"} {
+		if v, ok := numberValue(data[key]); ok {
+			totals[currency] = v
+			return totals
+		}
+	}
+	for _, key := range []string{"balances", "positions", "wallets"} {
+		if list, ok := data[key].([]interface{}); ok {
+			for _, item := range list {
+				if m, ok := item.(map[string]interface{}); ok {
+					for c, v := range balanceTotals(m) {
+						totals[c] += v
+					}
+				}
+			}
+		}
+	}
+	return totals
+}
+
+// txString returns a string field from a transaction, or "" if missing.
+func txString(tx map[string]interface{}, key string) string {
+	switch v := tx[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+// txAmount returns the absolute transaction amount. Liminal returns amounts
+// as strings, the CSV loader as floats.
+func txAmount(tx map[string]interface{}) float64 {
+	v, _ := numberValue(tx["amount"])
+	return math.Abs(v)
+}
+
+var txTimeLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// txTime parses the transaction timestamp.
+func txTime(tx map[string]interface{}) (time.Time, bool) {
+	for _, key := range []string{"timestamp", "created_at", "date"} {
+		raw := txString(tx, key)
+		if raw == "" {
+			continue
+		}
+		for _, layout := range txTimeLayouts {
+			if t, err := time.Parse(layout, raw); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// txID returns a stable identifier for a transaction. CSV rows have no ID, so
+// fall back to a composite of the fields that make a row unique.
+func txID(tx map[string]interface{}) string {
+	for _, key := range []string{"id", "transaction_id"} {
+		if id := txString(tx, key); id != "" {
+			return id
+		}
+	}
+	return fmt.Sprintf("%s|%s|%.2f|%s", txString(tx, "timestamp"), txString(tx, "type"), txAmount(tx), txString(tx, "counterparty"))
+}
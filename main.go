@@ -4,13 +4,12 @@ package main
 
 import (
 	"context"
-	"encoding/csv"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
+	"net/http"
 	"os"
-	"strconv"
+	"strings"
 	"time"
 	"math"
 	"sort"
@@ -29,23 +28,26 @@ func main() {
 	// Load .env file if it exists (optional - will use system env vars if not found)
 	_ = godotenv.Load()
 
-	// Load configuration from environment variables
-	// Create a .env file or export these in your shell
-
-	anthropicKey := os.Getenv("ANTHROPIC_API_KEY")
-	if anthropicKey == "" {
-		log.Fatal("❌ ANTHROPIC_API_KEY environment variable is required")
+	// Offline subcommands (see cli.go) run without the server or its keys.
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:]))
 	}
 
-	liminalBaseURL := os.Getenv("LIMINAL_BASE_URL")
-	if liminalBaseURL == "" {
-		liminalBaseURL = "https://api.liminal.cash"
-	}
+	// Load configuration: neurapay.yaml (or NEURAPAY_CONFIG) with environment
+	// variables on top - see config.go. Create a .env file or export these in
+	// your shell, or run `neurapay config check` to see what's in effect.
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	cfg, err := loadConfig("", os.Getenv)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if err := cfg.validate(); err != nil {
+		log.Fatalf("❌ %v", err)
 	}
+	cfg.apply()
+
+	port := cfg.Server.Port
+	apiPort := cfg.Server.APIPort
 
 	// ============================================================================
 	// LIMINAL EXECUTOR SETUP
@@ -54,26 +56,53 @@ func main() {
 	// Authentication is handled automatically via JWT tokens passed from the
 	// frontend login flow (email/OTP). No API key needed!
 
-	liminalExecutor := executor.NewHTTPExecutor(executor.HTTPExecutorConfig{
-		BaseURL: liminalBaseURL,
-	})
-	log.Println("✅ Liminal API configured")
-
-	// ============================================================================
-	// SERVER SETUP
-	// ============================================================================
-	// Create the nim-go-sdk server with Claude AI
-	// The server handles WebSocket connections and manages conversations
-	// Authentication is automatic: JWT tokens from the login flow are extracted
-	// from WebSocket connections and forwarded to Liminal API calls
+	//
+	// Set liminal.mode (LIMINAL_MODE) to simulator to run against the offline
+	// simulator instead (see simulator.go) - handy for demos and integration
+	// tests. record captures real traffic to the cassette file (scrubbed), and
+	// replay serves it back offline (cassette.go).
+
+	var liminalExecutor core.ToolExecutor
+	switch cfg.Liminal.Mode {
+	case "http":
+		liminalExecutor = executor.NewHTTPExecutor(executor.HTTPExecutorConfig{
+			BaseURL:    cfg.Liminal.BaseURL,
+			HTTPClient: liminalHTTPClient(),
+		})
+		log.Println("✅ Liminal API configured")
+	case "simulator":
+		sim, err := newSimulatorFromDir(cfg.Liminal.SimulatorFixtures)
+		if err != nil {
+			log.Fatalf("❌ Failed to start Liminal simulator: %v", err)
+		}
+		liminalExecutor = sim
+		log.Printf("🧪 Liminal simulator loaded from %s (offline, no real money moves)", cfg.Liminal.SimulatorFixtures)
+	case "record":
+		liminalExecutor = newRecordingExecutor(executor.NewHTTPExecutor(executor.HTTPExecutorConfig{
+			BaseURL:    cfg.Liminal.BaseURL,
+			HTTPClient: liminalHTTPClient(),
+		}), cfg.Liminal.Cassette, cfg.Liminal.BaseURL)
+		log.Printf("⏺️  Recording Liminal traffic to %s (JWTs and PII scrubbed)", cfg.Liminal.Cassette)
+	case "replay":
+		cassette, err := loadCassette(cfg.Liminal.Cassette)
+		if err != nil {
+			log.Fatalf("❌ Failed to load cassette: %v", err)
+		}
+		liminalExecutor = newReplayExecutor(cassette)
+		log.Printf("⏯️  Replaying %d Liminal interactions from %s (offline)", len(cassette.Interactions), cfg.Liminal.Cassette)
+	}
+	liminalExecutor, err = wrapLiminalExecutor(liminalExecutor, cfg)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if cfg.Policy.MaxTransfer > 0 {
+		log.Printf("🛡️  Money movements capped at $%.2f", cfg.Policy.MaxTransfer)
+	}
+	if cfg.Data.Cache.Enabled {
+		log.Printf("🗄️  Transactions cached locally (synced when older than %s)", cfg.CacheMaxAge())
+	}
 
-	srv, err := server.New(server.Config{
-		AnthropicKey:    anthropicKey,
-		SystemPrompt:    hackathonSystemPrompt,
-		Model:           "claude-sonnet-4-20250514",
-		MaxTokens:       4096,
-		LiminalExecutor: liminalExecutor, // SDK automatically handles JWT extraction and forwarding
-	})
+	credentials, err := newCredentialStore(cfg.Liminal.CredentialsKey, cfg.Liminal.RefreshURL)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -96,17 +125,58 @@ func main() {
 	//   8. deposit_savings - Deposit funds into savings
 	//   9. withdraw_savings - Withdraw funds from savings
 
-	srv.AddTools(tools.LiminalTools(liminalExecutor)...)
-	log.Println("✅ Added 9 Liminal banking tools")
+	// Tools go through the registry (registry.go), which drops disabled and
+	// testing-only tools and gates feature-flagged ones per user;
+	// registerTools (below) adds the banking tools and then ours.
 
 	// ============================================================================
 	// ADD CUSTOM TOOLS
 	// ============================================================================
 	// This is where you'll add your hackathon project's custom tools!
-	// Below is an example spending analyzer tool to get you started.
+	// They're built in toolset.go, starting with an example spending analyzer.
 
-	srv.AddTool(createSpendingAnalyzerTool(liminalExecutor))
-	log.Println("✅ Added custom spending analyzer tool")
+	custom := newToolset(liminalExecutor, credentials, cfg)
+	registry, err := registerTools(cfg, liminalExecutor, custom)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	log.Println("✅ Added 9 Liminal banking tools")
+	if len(registry.skipped) > 0 {
+		log.Printf("🚫 Not registered: %s", strings.Join(registry.skipped, ", "))
+	}
+
+	// The system prompt is built from a template, the registered tools and
+	// the policy config (prompt.go); start_session adds the per-user part.
+	prompt, err := newPromptBuilder(cfg, registry, custom.contexts)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	systemPrompt, err := prompt.shared()
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	custom.greeter.prompt = prompt
+	log.Printf("✅ System prompt %s with %d tools", prompt.version, len(registry.tools))
+
+	// ============================================================================
+	// SERVER SETUP
+	// ============================================================================
+	// Create the nim-go-sdk server with Claude AI
+	// The server handles WebSocket connections and manages conversations
+	// Authentication is automatic: JWT tokens from the login flow are extracted
+	// from WebSocket connections and forwarded to Liminal API calls
+
+	srv, err := server.New(server.Config{
+		AnthropicKey:    cfg.Model.AnthropicKey,
+		SystemPrompt:    systemPrompt,
+		Model:           cfg.Model.Name,
+		MaxTokens:       cfg.Model.MaxTokens,
+		LiminalExecutor: liminalExecutor, // SDK automatically handles JWT extraction and forwarding
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	srv.AddTools(registry.tools...)
 
 	// TODO: Add more custom tools here!
 	// Examples:
@@ -116,11 +186,6 @@ func main() {
 	//   - Bill payment predictor
 	//   - Cash flow forecaster
 
-	srv.AddTool(createMoneyPersonality(liminalExecutor))
-    log.Println("✅ Added Money Personality analyzer")
-	
-	srv.AddTool(createCSVTransactionsTool())
-	log.Println("✅ Added CSV transactions reader (for testing)")
 	// ============================================================================
 	// START SERVER
 	// ============================================================================
@@ -131,14 +196,59 @@ func main() {
 	log.Printf("📡 WebSocket endpoint: ws://localhost:%s/ws", port)
 	log.Printf("💚 Health check: http://localhost:%s/health", port)
 	log.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	log.Printf("🔌 Companion API: http://localhost:%s", apiPort)
+	log.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 	log.Println("Ready for connections! Start your frontend with: cd frontend && npm run dev")
 	log.Println()
 
+	go custom.monitor.run(context.Background())
+	go custom.notifier.run(context.Background())
+
+	go func() {
+		if err := http.ListenAndServe(":"+apiPort, newAPIHandler(liminalExecutor, credentials, custom.statements, custom.exports, custom.notifier)); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
 	if err := srv.Run(":" + port); err != nil {
 		log.Fatal(err)
 	}
 }
 
+// wrapLiminalExecutor puts the money-movement cap and the transaction cache
+// (txcache.go) in front of the Liminal executor, in the order the server
+// and the scenario tests use them.
+func wrapLiminalExecutor(liminalExecutor core.ToolExecutor, cfg *Config) (core.ToolExecutor, error) {
+	if cfg.Policy.MaxTransfer > 0 {
+		liminalExecutor = newPolicyExecutor(liminalExecutor, cfg.Policy)
+	}
+
+	// Transaction reads are served from a local SQLite cache that syncs
+	// incrementally.
+	if cfg.Data.Cache.Enabled {
+		cache, err := newTransactionCache(cfg.CacheMaxAge())
+		if err != nil {
+			return nil, err
+		}
+		liminalExecutor = newCachingExecutor(liminalExecutor, cache)
+	}
+	return liminalExecutor, nil
+}
+
+// registerTools builds the registry the server serves: the Liminal banking
+// tools on liminalExecutor, then ours.
+func registerTools(cfg *Config, liminalExecutor core.ToolExecutor, custom *toolset) (*toolRegistry, error) {
+	registry := newToolRegistry(cfg.Tools)
+	registry.register("BANKING TOOLS", tools.LiminalTools(liminalExecutor))
+	registry.register("NEURAPAY TOOLS", custom.tools)
+
+	// Catch typos in the tools config now that every tool name is known.
+	if err := registry.checkNames(); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
 // ============================================================================
 // CSV TRANSACTION LOADER
 // ============================================================================
@@ -151,136 +261,12 @@ func main() {
 // Returns a slice of transaction maps compatible with the Liminal API format.
 
 func loadTransactionsFromCSV(filepath string) ([]map[string]interface{}, error) {
-	file, err := os.Open(filepath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open CSV file: %w", err)
-	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-	
-	// Read header row
-	header, err := reader.Read()
-	if err != nil {
-		return nil, fmt.Errorf("failed to read CSV header: %w", err)
-	}
-
-	var transactions []map[string]interface{}
-
-	// Read all rows
-	for {
-		record, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("error reading CSV row: %w", err)
-		}
-
-		// Create transaction map
-		tx := make(map[string]interface{})
-		for i, value := range record {
-			if i >= len(header) {
-				break
-			}
-			
-			columnName := header[i]
-			
-			// Parse specific fields appropriately
-			switch columnName {
-			case "amount", "balance_after":
-				if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
-					tx[columnName] = floatVal
-				} else {
-					tx[columnName] = value
-				}
-			case "timestamp":
-				tx[columnName] = value
-			default:
-				tx[columnName] = value
-			}
-		}
-
-		transactions = append(transactions, tx)
-	}
-
-	log.Printf("✅ Loaded %d transactions from CSV file", len(transactions))
-	return transactions, nil
+	// The importers (statements.go) read bank exports in this schema, other
+	// CSV layouts, OFX, QIF, camt.053 and MT940; rows they can't read are
+	// logged and skipped.
+	return loadStatementFile(filepath)
 }
 
-// ============================================================================
-// SYSTEM PROMPT
-// ============================================================================
-// This prompt defines your AI agent's personality and behavior
-// Customize this to match your hackathon project's focus!
-
-const hackathonSystemPrompt = `You are NeuraPay, a proactive AI wealth optimizer. You don't wait to be asked - 
-you actively monitor finances and suggest optimal moves. You're like having 
-a smart friend who's really good with money watching your back 24/7.
-
-PROACTIVE BEHAVIORS:
-- Greet users with their current spare cash amount
-- Suggest savings moves at optimal moments
-- Celebrate interest earnings and milestones
-- Warn about low balances before they happen
-
-WHAT YOU DO:
-You help users manage their money using Liminal's stablecoin banking platform. You can check balances, review transactions, send money, and manage savings - all through natural conversation.
-
-CONVERSATIONAL STYLE:
-- Be warm, friendly, and conversational - not robotic
-- Use casual language when appropriate, but stay professional about money
-- Ask clarifying questions when something is unclear
-- Remember context from earlier in the conversation
-- Explain things simply without being condescending
-
-WHEN TO USE TOOLS:
-- Use tools immediately for simple queries ("what's my balance?")
-- For actions, gather all required info first ("send $50 to @alice")
-- Always confirm before executing money movements
-- Don't use tools for general questions about how things work
-
-MONEY MOVEMENT RULES (IMPORTANT):
-- ALL money movements require explicit user confirmation
-- Show a clear summary before confirming:
-  * send_money: "Send $50 USD to @alice"
-  * deposit_savings: "Deposit $100 USD into savings"
-  * withdraw_savings: "Withdraw $50 USD from savings"
-- Never assume amounts or recipients
-- Always use the exact currency the user specified
-
-AVAILABLE BANKING TOOLS:
-- Check wallet balance (get_balance)
-- Check savings balance and APY (get_savings_balance)
-- View savings rates (get_vault_rates)
-- View transaction history (get_transactions)
-- Get profile info (get_profile)
-- Search for users (search_users)
-- Send money (send_money) - requires confirmation
-- Deposit to savings (deposit_savings) - requires confirmation
-- Withdraw from savings (withdraw_savings) - requires confirmation
-
-TESTING/DEMO TOOLS:
-- Read CSV transactions (get_csv_transactions) - for offline testing with transactions.csv
-
-CUSTOM ANALYTICAL TOOLS:
-- Analyze spending patterns (analyze_spending)
-- Discover your Money Personality (analyze_money_personality)
-
-TIPS FOR GREAT INTERACTIONS:
-- Proactively suggest relevant actions ("Want me to move some to savings?")
-- Explain the "why" behind suggestions
-- Celebrate financial wins ("Nice! Your savings earned $5 this month!")
-- Be encouraging about savings goals
-- Make finance feel less intimidating
-
-MONEY PERSONALITY INSIGHTS:
-When users want to understand their financial psychology, use analyze_money_personality.
-This isn't just data - it reveals behavioral patterns and provides personalized strategies.
-Make it feel like a revelation: "Let me analyze your financial DNA..."
-
-Remember: You're here to make banking delightful and help users build better financial habits!`
-
 // ============================================================================
 // CUSTOM TOOL: SPENDING ANALYZER
 // ============================================================================
@@ -292,18 +278,26 @@ Remember: You're here to make banking delightful and help users build better fin
 //
 // Use this as a template for your own hackathon tools!
 
-func createSpendingAnalyzerTool(liminalExecutor core.ToolExecutor) core.Tool {
+func createSpendingAnalyzerTool(liminalExecutor core.ToolExecutor, statements *statementStore, contexts *userContextStore) core.Tool {
 	return tools.New("analyze_spending").
-		Description("Analyze the user's spending patterns over a specified time period. Returns insights about spending velocity, categories, and trends.").
+		Description("Analyze the user's spending patterns over a specified time period. Returns insights about spending velocity, categories, and trends. Includes the bank statements the user imported.").
 		Schema(tools.ObjectSchema(map[string]interface{}{
 			"days": tools.IntegerProperty("Number of days to analyze (default: 30)"),
 			"use_csv": tools.BooleanProperty("Use local CSV file instead of API (for testing, default: false)"),
+			"include_statements": tools.BooleanProperty("Include bank statements the user imported (default: true)"),
+			"refresh": tools.BooleanProperty("Fetch the latest transactions from Liminal instead of the local cache (default: false)"),
+			"heatmap": tools.BooleanProperty("Also return spend by weekday, hour of day and day of month, with the patterns in them (default: false)"),
+			"timezone": tools.StringProperty("IANA timezone to read the heatmap in, e.g. America/New_York (default: the one saved with update_user_context, else UTC)"),
 		})).
 		Handler(func(ctx context.Context, toolParams *core.ToolParams) (*core.ToolResult, error) {
 			// Parse input parameters
 			var params struct {
-				Days   int  `json:"days"`
-				UseCSV bool `json:"use_csv"`
+				Days              int   `json:"days"`
+				UseCSV            bool  `json:"use_csv"`
+				IncludeStatements *bool `json:"include_statements"`
+				Refresh           bool  `json:"refresh"`
+				Heatmap           bool   `json:"heatmap"`
+				Timezone          string `json:"timezone"`
 			}
 			if err := json.Unmarshal(toolParams.Input, &params); err != nil {
 				return &core.ToolResult{
@@ -316,13 +310,20 @@ func createSpendingAnalyzerTool(liminalExecutor core.ToolExecutor) core.Tool {
 			if params.Days == 0 {
 				params.Days = 30
 			}
+			loc, err := userHeatmapLocation(contexts, toolParams.UserID, params.Timezone)
+			if err != nil {
+				return &core.ToolResult{Success: false, Error: err.Error()}, nil
+			}
+			if params.Refresh {
+				ctx = withFreshTransactions(ctx)
+			}
 
 			var transactions []map[string]interface{}
 
 			// STEP 1: Fetch transaction data (from CSV or API)
 			if params.UseCSV {
 				// Load from CSV file for testing
-				csvTransactions, err := loadTransactionsFromCSV("transactions.csv")
+				csvTransactions, err := loadTransactionsFromCSV(csvTransactionsPath)
 				if err != nil {
 					return &core.ToolResult{
 						Success: false,
@@ -333,7 +334,7 @@ func createSpendingAnalyzerTool(liminalExecutor core.ToolExecutor) core.Tool {
 			} else {
 				// Fetch from Liminal API
 				txRequest := map[string]interface{}{
-					"limit": 100, // Get up to 100 transactions
+					"limit": transactionFetchLimit, // Get up to 100 transactions by default
 				}
 				txRequestJSON, _ := json.Marshal(txRequest)
 
@@ -370,6 +371,13 @@ func createSpendingAnalyzerTool(liminalExecutor core.ToolExecutor) core.Tool {
 				}
 			}
 
+			// Statements from the user's other banks (statements.go) go back
+			// years, so only the period being analyzed is added.
+			statementCount := 0
+			if params.IncludeStatements == nil || *params.IncludeStatements {
+				transactions, statementCount = statements.withStatements(toolParams.UserID, transactions, time.Now().AddDate(0, 0, -params.Days))
+			}
+
 			// STEP 2: Analyze the data
 			analysis := analyzeTransactions(transactions, params.Days)
 
@@ -378,9 +386,14 @@ func createSpendingAnalyzerTool(liminalExecutor core.ToolExecutor) core.Tool {
 				"period_days":        params.Days,
 				"total_transactions": len(transactions),
 				"analysis":           analysis,
-				"data_source":        map[string]bool{"csv": params.UseCSV, "api": !params.UseCSV},
+				"data_source":        map[string]interface{}{"csv": params.UseCSV, "api": !params.UseCSV, "statements": statementCount},
 				"generated_at":       time.Now().Format(time.RFC3339),
 			}
+			if params.Heatmap {
+				if heatmap := spendingHeatmap(transactions, loc); heatmap != nil {
+					result["heatmap"] = heatmap
+				}
+			}
 
 			return &core.ToolResult{
 				Success: true,
@@ -497,15 +510,21 @@ type PersonalityArchetype struct {
 	FunFact    string
 }
 
-func createMoneyPersonality(liminalExecutor core.ToolExecutor) core.Tool {
+func createMoneyPersonality(liminalExecutor core.ToolExecutor, statements *statementStore, contexts *userContextStore) core.Tool {
 	return tools.New("analyze_money_personality").
-		Description("Discover your Money Personality - a psychological profile of your spending and saving behaviors. Reveals behavioral patterns, triggers, and personalized strategies.").
+		Description("Discover your Money Personality - a psychological profile of your spending and saving behaviors. Reveals behavioral patterns, triggers backed by when the user actually spends, and personalized strategies. Includes the bank statements the user imported.").
 		Schema(tools.ObjectSchema(map[string]interface{}{
 			"use_csv": tools.BooleanProperty("Use local CSV file instead of API (for testing, default: false)"),
+			"include_statements": tools.BooleanProperty("Include bank statements the user imported (default: true)"),
+			"refresh": tools.BooleanProperty("Fetch the latest transactions from Liminal instead of the local cache (default: false)"),
+			"timezone": tools.StringProperty("IANA timezone to read spending times in, e.g. America/New_York (default: the one saved with update_user_context, else UTC)"),
 		})).
 		Handler(func(ctx context.Context, toolParams *core.ToolParams) (*core.ToolResult, error) {
 			var params struct {
-				UseCSV bool `json:"use_csv"`
+				UseCSV            bool   `json:"use_csv"`
+				IncludeStatements *bool  `json:"include_statements"`
+				Refresh           bool   `json:"refresh"`
+				Timezone          string `json:"timezone"`
 			}
 			if err := json.Unmarshal(toolParams.Input, &params); err != nil {
 				return &core.ToolResult{
@@ -513,12 +532,19 @@ func createMoneyPersonality(liminalExecutor core.ToolExecutor) core.Tool {
 					Error:   fmt.Sprintf("invalid input: %v", err),
 				}, nil
 			}
+			loc, err := userHeatmapLocation(contexts, toolParams.UserID, params.Timezone)
+			if err != nil {
+				return &core.ToolResult{Success: false, Error: err.Error()}, nil
+			}
+			if params.Refresh {
+				ctx = withFreshTransactions(ctx)
+			}
 
 			var transactions []map[string]interface{}
 
 			// Fetch transaction data (from CSV or API)
 			if params.UseCSV {
-				csvTransactions, err := loadTransactionsFromCSV("transactions.csv")
+				csvTransactions, err := loadTransactionsFromCSV(csvTransactionsPath)
 				if err != nil {
 					return &core.ToolResult{
 						Success: false,
@@ -527,7 +553,7 @@ func createMoneyPersonality(liminalExecutor core.ToolExecutor) core.Tool {
 				}
 				transactions = csvTransactions
 			} else {
-				txRequest := map[string]interface{}{"limit": 100}
+				txRequest := map[string]interface{}{"limit": transactionFetchLimit}
 				txRequestJSON, _ := json.Marshal(txRequest)
 
 				txResponse, err := liminalExecutor.Execute(ctx, &core.ExecuteRequest{
@@ -562,6 +588,12 @@ func createMoneyPersonality(liminalExecutor core.ToolExecutor) core.Tool {
 				}
 			}
 
+			// Personality is about habits, so the whole statement history counts.
+			statementCount := 0
+			if params.IncludeStatements == nil || *params.IncludeStatements {
+				transactions, statementCount = statements.withStatements(toolParams.UserID, transactions, time.Time{})
+			}
+
 			if len(transactions) < 10 {
 				return &core.ToolResult{
 					Success: false,
@@ -582,7 +614,11 @@ func createMoneyPersonality(liminalExecutor core.ToolExecutor) core.Tool {
 				"personalized_strategies": archetype.Strategies,
 				"fun_fact":         archetype.FunFact,
 				"raw_scores":       scores,
-				"data_source":      map[string]bool{"csv": params.UseCSV, "api": !params.UseCSV},
+				"data_source":      map[string]interface{}{"csv": params.UseCSV, "api": !params.UseCSV, "statements": statementCount},
+			}
+			// When and how the user actually spends, to ground the triggers.
+			if heatmap := spendingHeatmap(transactions, loc); heatmap != nil && len(heatmap.Insights) > 0 {
+				result["trigger_evidence"] = heatmap.Insights
 			}
 
 			return &core.ToolResult{
@@ -630,7 +666,7 @@ func calculatePersonalityScores(transactions []map[string]interface{}) map[strin
 	}
 	
 	// 1. Transaction Velocity (0-100)
-	txPerWeek := float64(len(transactions)) / 4.0 // Assuming ~4 weeks of data
+	txPerWeek := float64(len(transactions)) / historyWeeks(transactions)
 	scores["transaction_velocity"] = math.Min(txPerWeek*10, 100)
 	
 	// 2. Amount Distribution (0-100) - measures consistency
@@ -673,6 +709,29 @@ func calculatePersonalityScores(transactions []map[string]interface{}) map[strin
 	return scores
 }
 
+// historyWeeks is the time span transactions cover, in weeks and at least
+// one. Without timestamps it assumes about four weeks of data.
+func historyWeeks(transactions []map[string]interface{}) float64 {
+	var first, last time.Time
+	for _, tx := range transactions {
+		ts, ok := txTime(tx)
+		if !ok {
+			continue
+		}
+		if first.IsZero() || ts.Before(first) {
+			first = ts
+		}
+		if ts.After(last) {
+			last = ts
+		}
+	}
+	if first.IsZero() {
+		return 4
+	}
+	days := math.Ceil(last.Sub(first).Hours() / 24)
+	return math.Max(days, 7) / 7
+}
+
 func calculateMean(values []float64) float64 {
 	if len(values) == 0 {
 		return 0
@@ -900,7 +959,7 @@ func createCSVTransactionsTool() core.Tool {
 			}
 
 			// Load transactions from CSV
-			transactions, err := loadTransactionsFromCSV("transactions.csv")
+			transactions, err := loadTransactionsFromCSV(csvTransactionsPath)
 			if err != nil {
 				return &core.ToolResult{
 					Success: false,
@@ -917,7 +976,7 @@ func createCSVTransactionsTool() core.Tool {
 				"transactions": transactions,
 				"count":        len(transactions),
 				"source":       "csv",
-				"file":         "transactions.csv",
+				"file":         csvTransactionsPath,
 			}
 
 			return &core.ToolResult{
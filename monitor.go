@@ -0,0 +1,443 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+	"github.com/becomeliminal/nim-go-sdk/tools"
+)
+
+// ============================================================================
+// BACKGROUND MONITOR
+// ============================================================================
+// Runs alongside the WebSocket server and "watches your money 24/7" for users
+// who opted in and stored credentials. Every MONITOR_INTERVAL it polls their
+// balance, savings and transactions, evaluates triggers (low balance forecast,
+// large transaction, unusual activity, interest earned, goal reached) and
+// queues insights. It also runs the savings automations so paychecks are
+// handled minutes after they land instead of at the next conversation.
+
+// MonitorSettings are the user's monitoring preferences.
+type MonitorSettings struct {
+	Enabled          bool    `json:"enabled"`
+	LowBalanceDays   int     `json:"low_balance_days"`
+	LargeTransaction float64 `json:"large_transaction"` // 0 = 3x the typical send
+	SavingsGoal      float64 `json:"savings_goal"`
+	UnusualActivity  string  `json:"unusual_activity,omitempty"` // lowest severity alerted, or off; "" = medium
+}
+
+// MonitorAccount is the per-user monitoring state.
+type MonitorAccount struct {
+	Settings    MonitorSettings `json:"settings"`
+	Initialized bool            `json:"initialized"`
+	LastBalance float64         `json:"last_balance"`
+	LastSavings float64         `json:"last_savings"`
+	SeenTxIDs   []string        `json:"seen_tx_ids"`
+	LastPolled  time.Time       `json:"last_polled"`
+	LastError   string          `json:"last_error,omitempty"`
+}
+
+const maxSeenMonitorIDs = 500
+
+// monitorJob is an automation run for every monitored user on each poll.
+type monitorJob struct {
+	name string
+	run  func(ctx context.Context, userID, requestID string) error
+}
+
+// monitor polls opted-in users in the background.
+type monitor struct {
+	liminalExecutor core.ToolExecutor
+	creds           *credentialStore
+	insights        *insightQueue
+	interval        time.Duration
+	store           *jsonStore
+
+	mu       sync.Mutex
+	accounts map[string]*MonitorAccount
+	jobs     []monitorJob
+}
+
+func newMonitor(liminalExecutor core.ToolExecutor, creds *credentialStore, insights *insightQueue, interval time.Duration) *monitor {
+	m := &monitor{
+		liminalExecutor: liminalExecutor,
+		creds:           creds,
+		insights:        insights,
+		interval:        interval,
+		store:           newJSONStore("monitor.json"),
+		accounts:        make(map[string]*MonitorAccount),
+	}
+	if err := m.store.load(&m.accounts); err != nil {
+		log.Printf("⚠️  Monitor state not loaded: %v", err)
+	}
+	return m
+}
+
+func (m *monitor) account(userID string) *MonitorAccount {
+	acct, ok := m.accounts[userID]
+	if !ok {
+		acct = &MonitorAccount{Settings: MonitorSettings{LowBalanceDays: 7}}
+		m.accounts[userID] = acct
+	}
+	return acct
+}
+
+func (m *monitor) persist() {
+	if err := m.store.save(m.accounts); err != nil {
+		log.Printf("⚠️  Failed to save monitor state: %v", err)
+	}
+}
+
+// addJob registers an automation to run for each monitored user.
+func (m *monitor) addJob(name string, run func(ctx context.Context, userID, requestID string) error) {
+	m.jobs = append(m.jobs, monitorJob{name: name, run: run})
+}
+
+// run polls until ctx is cancelled.
+func (m *monitor) run(ctx context.Context) {
+	if m.creds == nil {
+		log.Println("⚠️  Background monitor disabled: NEURAPAY_CREDENTIALS_KEY not set")
+		return
+	}
+	log.Printf("👀 Background monitor polling every %s", m.interval)
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		m.pollAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *monitor) pollAll(ctx context.Context) {
+	m.mu.Lock()
+	var users []string
+	for userID, acct := range m.accounts {
+		if acct.Settings.Enabled {
+			users = append(users, userID)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, userID := range users {
+		if ctx.Err() != nil {
+			return
+		}
+		err := m.pollUser(ctx, userID)
+
+		m.mu.Lock()
+		acct := m.account(userID)
+		acct.LastPolled = time.Now()
+		acct.LastError = ""
+		if err != nil {
+			acct.LastError = err.Error()
+			log.Printf("⚠️  Monitor poll failed for %s: %v", userID, err)
+		}
+		m.persist()
+		m.mu.Unlock()
+	}
+}
+
+// pollUser fetches the user's data, runs automations and evaluates triggers.
+func (m *monitor) pollUser(ctx context.Context, userID string) error {
+	creds, err := m.creds.fresh(ctx, userID)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(withBearerToken(ctx, creds.AccessToken), 2*time.Minute)
+	defer cancel()
+	requestID := fmt.Sprintf("monitor-%s-%d", userID, time.Now().Unix())
+
+	for _, job := range m.jobs {
+		if err := job.run(ctx, userID, requestID); err != nil {
+			log.Printf("⚠️  Monitor job %s failed for %s: %v", job.name, userID, err)
+		}
+	}
+
+	balanceData, err := callLiminal(ctx, m.liminalExecutor, userID, requestID, "get_balance", map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	savingsData, err := callLiminal(ctx, m.liminalExecutor, userID, requestID, "get_savings_balance", map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	transactions, err := fetchTransactions(ctx, m.liminalExecutor, userID, requestID, 100)
+	if err != nil {
+		return err
+	}
+
+	currency := mainCurrency(transactions)
+	balance := balanceTotal(balanceData, currency)
+	savings := balanceTotal(savingsData, currency)
+
+	m.mu.Lock()
+	acct := m.account(userID)
+	seen := make(map[string]bool, len(acct.SeenTxIDs))
+	for _, id := range acct.SeenTxIDs {
+		seen[id] = true
+	}
+	var newTx []map[string]interface{}
+	for _, tx := range transactions {
+		id := txID(tx)
+		if !seen[id] {
+			newTx = append(newTx, tx)
+			acct.SeenTxIDs = append(acct.SeenTxIDs, id)
+		}
+	}
+	if len(acct.SeenTxIDs) > maxSeenMonitorIDs {
+		acct.SeenTxIDs = acct.SeenTxIDs[len(acct.SeenTxIDs)-maxSeenMonitorIDs:]
+	}
+
+	// The first poll only records a baseline so history doesn't fire alerts.
+	firstPoll := !acct.Initialized
+	prevSavings := acct.LastSavings
+	settings := acct.Settings
+	acct.Initialized = true
+	acct.LastBalance = balance
+	acct.LastSavings = savings
+	m.mu.Unlock()
+
+	var found []Insight
+	if in := lowBalanceInsight(balance, transactions, settings.LowBalanceDays, time.Now()); in != nil {
+		found = append(found, *in)
+	}
+	if in := goalReachedInsight(savings, settings.SavingsGoal); in != nil {
+		found = append(found, *in)
+	}
+	if !firstPoll {
+		found = append(found, largeTransactionInsights(newTx, transactions, settings.LargeTransaction)...)
+		found = append(found, anomalyInsights(newTx, transactions, firstNonEmpty(settings.UnusualActivity, "medium"))...)
+		if in := interestEarnedInsight(prevSavings, savings, newTx, time.Now()); in != nil {
+			found = append(found, *in)
+		}
+	}
+	for _, in := range found {
+		m.insights.push(userID, in)
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+// Triggers
+// ----------------------------------------------------------------------------
+
+// isSavingsTransfer reports whether a transaction moved money to or from
+// savings rather than spending it.
+func isSavingsTransfer(tx map[string]interface{}) bool {
+	return txString(tx, "category") == "savings"
+}
+
+// avgDailySpend is the average daily outflow over the last 30 days.
+func avgDailySpend(transactions []map[string]interface{}, now time.Time) float64 {
+	since := now.AddDate(0, 0, -30)
+	total := 0.0
+	for _, tx := range transactions {
+		ts, ok := txTime(tx)
+		if !ok || ts.Before(since) || txString(tx, "type") != "send" || isSavingsTransfer(tx) {
+			continue
+		}
+		total += txAmount(tx)
+	}
+	return total / 30
+}
+
+func lowBalanceInsight(balance float64, transactions []map[string]interface{}, thresholdDays int, now time.Time) *Insight {
+	daily := avgDailySpend(transactions, now)
+	if daily <= 0 || thresholdDays <= 0 {
+		return nil
+	}
+	daysLeft := balance / daily
+	if daysLeft >= float64(thresholdDays) {
+		return nil
+	}
+	return &Insight{
+		Type:     "low_balance_forecast",
+		Severity: "warning",
+		Title:    "Balance running low",
+		Message:  fmt.Sprintf("At your usual pace of $%.2f/day, your $%.2f balance lasts about %.0f more days.", daily, balance, math.Floor(daysLeft)),
+		Data: map[string]interface{}{
+			"balance":         balance,
+			"avg_daily_spend": math.Round(daily*100) / 100,
+			"days_left":       math.Round(daysLeft*10) / 10,
+		},
+		DedupKey: "low_balance:" + now.Format("2006-01-02"),
+	}
+}
+
+func largeTransactionInsights(newTx, history []map[string]interface{}, threshold float64) []Insight {
+	if threshold <= 0 {
+		var sends []float64
+		for _, tx := range history {
+			if txString(tx, "type") == "send" && !isSavingsTransfer(tx) {
+				sends = append(sends, txAmount(tx))
+			}
+		}
+		threshold = math.Max(calculateMedian(sends)*3, 100)
+	}
+
+	var out []Insight
+	for _, tx := range newTx {
+		if txString(tx, "type") != "send" || isSavingsTransfer(tx) || txAmount(tx) < threshold {
+			continue
+		}
+		counterparty := txString(tx, "counterparty")
+		out = append(out, Insight{
+			Type:     "large_transaction",
+			Severity: "info",
+			Title:    "Large payment",
+			Message:  fmt.Sprintf("You sent $%.2f to %s - larger than usual.", txAmount(tx), counterparty),
+			Data: map[string]interface{}{
+				"amount":       txAmount(tx),
+				"counterparty": counterparty,
+				"threshold":    math.Round(threshold*100) / 100,
+			},
+			DedupKey: "large_tx:" + txID(tx),
+		})
+	}
+	return out
+}
+
+// interestEarnedInsight attributes any savings growth not explained by
+// deposits or withdrawals since the last poll to interest.
+func interestEarnedInsight(prevSavings, savings float64, newTx []map[string]interface{}, now time.Time) *Insight {
+	net := 0.0
+	for _, tx := range newTx {
+		if !isSavingsTransfer(tx) {
+			continue
+		}
+		switch txString(tx, "type") {
+		case "send":
+			net += txAmount(tx)
+		case "receive":
+			net -= txAmount(tx)
+		}
+	}
+	earned := savings - prevSavings - net
+	if earned < 0.01 {
+		return nil
+	}
+	return &Insight{
+		Type:     "interest_earned",
+		Severity: "celebrate",
+		Title:    "Your savings earned interest",
+		Message:  fmt.Sprintf("Your savings earned $%.2f since we last checked.", earned),
+		Data: map[string]interface{}{
+			"earned":  math.Round(earned*100) / 100,
+			"savings": savings,
+		},
+		DedupKey: fmt.Sprintf("interest:%s:%.2f", now.Format("2006-01-02T15"), savings),
+	}
+}
+
+func goalReachedInsight(savings, goal float64) *Insight {
+	if goal <= 0 || savings < goal {
+		return nil
+	}
+	return &Insight{
+		Type:     "goal_reached",
+		Severity: "celebrate",
+		Title:    "Savings goal reached!",
+		Message:  fmt.Sprintf("Your savings hit $%.2f - you reached your $%.2f goal!", savings, goal),
+		Data: map[string]interface{}{
+			"savings": savings,
+			"goal":    goal,
+		},
+		DedupKey: fmt.Sprintf("goal:%.2f", goal),
+	}
+}
+
+func createMonitorSettingsTool(mon *monitor) core.Tool {
+	return tools.New("configure_monitoring").
+		Description("Turn background monitoring on or off and set alert thresholds. While on, NeuraPay checks the user's money every few minutes and queues insights (low balance forecast, large transactions, unusual activity, interest earned, savings goal reached). Turning it on requires opt_in=true after the user explicitly agrees.").
+		Schema(tools.ObjectSchema(map[string]interface{}{
+			"enabled":                  tools.BooleanProperty("Whether background monitoring is active"),
+			"low_balance_days":         tools.IntegerProperty("Warn when the balance is forecast to last fewer than this many days (default: 7)"),
+			"large_transaction_amount": tools.NumberProperty("Flag sends at or above this amount (0 = three times the typical send)"),
+			"savings_goal":             tools.NumberProperty("Celebrate when savings reach this amount (0 = no goal)"),
+			"unusual_activity":         tools.StringProperty("Alert on unusual payments of at least this severity: low, medium (default), high, or off"),
+			"opt_in":                   tools.BooleanProperty("Must be true when turning monitoring on - confirms the user explicitly agreed"),
+		})).
+		Handler(func(ctx context.Context, toolParams *core.ToolParams) (*core.ToolResult, error) {
+			var params struct {
+				Enabled          *bool    `json:"enabled"`
+				LowBalanceDays   *int     `json:"low_balance_days"`
+				LargeTransaction *float64 `json:"large_transaction_amount"`
+				SavingsGoal      *float64 `json:"savings_goal"`
+				UnusualActivity  *string  `json:"unusual_activity"`
+				OptIn            bool     `json:"opt_in"`
+			}
+			if err := json.Unmarshal(toolParams.Input, &params); err != nil {
+				return &core.ToolResult{
+					Success: false,
+					Error:   fmt.Sprintf("invalid input: %v", err),
+				}, nil
+			}
+
+			mon.mu.Lock()
+			defer mon.mu.Unlock()
+			acct := mon.account(toolParams.UserID)
+			settings := acct.Settings
+
+			if params.Enabled != nil {
+				if *params.Enabled && !settings.Enabled && !params.OptIn {
+					return &core.ToolResult{
+						Success: false,
+						Error:   "the user must explicitly opt in (opt_in=true) before monitoring is enabled",
+					}, nil
+				}
+				settings.Enabled = *params.Enabled
+			}
+			if params.LowBalanceDays != nil {
+				settings.LowBalanceDays = *params.LowBalanceDays
+			}
+			if params.LargeTransaction != nil {
+				settings.LargeTransaction = *params.LargeTransaction
+			}
+			if params.SavingsGoal != nil {
+				settings.SavingsGoal = *params.SavingsGoal
+			}
+			if params.UnusualActivity != nil {
+				level := strings.ToLower(strings.TrimSpace(*params.UnusualActivity))
+				if level != "off" && anomalySeverities[level] == 0 {
+					return &core.ToolResult{Success: false, Error: "unusual_activity must be low, medium, high or off"}, nil
+				}
+				settings.UnusualActivity = level
+			}
+			if settings.LowBalanceDays < 0 || settings.LargeTransaction < 0 || settings.SavingsGoal < 0 {
+				return &core.ToolResult{Success: false, Error: "thresholds cannot be negative"}, nil
+			}
+
+			acct.Settings = settings
+			mon.persist()
+
+			result := map[string]interface{}{
+				"settings":    settings,
+				"last_polled": acct.LastPolled,
+				"last_error":  acct.LastError,
+			}
+			switch {
+			case mon.creds == nil:
+				result["note"] = "Background monitoring is not available on this server."
+			case !mon.creds.has(toolParams.UserID):
+				result["note"] = "The app still needs to authorize background access before monitoring can run."
+			}
+
+			return &core.ToolResult{
+				Success: true,
+				Data:    result,
+			}, nil
+		}).
+		Build()
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// SWIFT MT940 IMPORTER
+// ============================================================================
+// MT940 is the older text statement most European banks still offer
+// alongside camt.053. A statement is a run of tagged fields:
+//
+//   :25:  account            :60F: opening balance (C/D, date, currency)
+//   :61:  one booking        :86:  details of the booking above it
+//   :62F: closing balance
+//
+// :61: packs value date, optional booking date, D/C mark (RD/RC for
+// reversals), amount with a decimal comma and the transaction type into one
+// line. :86: is free text that banks structure in their own way; the two
+// common ones - German ?20-?29 subfields and the /NAME/.../REMI/ style - are
+// split into counterparty and remittance, anything else is kept as the
+// description.
+
+// mt940Booking matches the fixed part of a :61: line.
+var mt940Booking = regexp.MustCompile(`^(\d{6})(\d{4})?(RC|RD|C|D)([A-Z])?(\d+,\d*)([NSF][A-Z0-9]{3})?(.*)$`)
+
+// mt940Tag matches a field tag at the start of a line, e.g. :61: or :60F:.
+var mt940Tag = regexp.MustCompile(`^:(\d{2}[A-Z]?):`)
+
+// mt940Categories maps :61: transaction type codes to a category.
+var mt940Categories = map[string]string{
+	"NCHG": "fees",
+	"FCHG": "fees",
+	"NINT": "interest",
+	"FINT": "interest",
+}
+
+// mt940Field is one tagged field with the line it starts on.
+type mt940Field struct {
+	tag   string
+	value string
+	line  int
+}
+
+// importMT940 reads every statement in an MT940 file, with or without the
+// SWIFT {1:...}{4: envelope.
+func importMT940(data []byte) (*ImportResult, error) {
+	text, encoding, err := decodeCSV(data, "")
+	if err != nil {
+		return nil, err
+	}
+	result := &ImportResult{Format: "mt940", Encoding: encoding, DateFormat: "YYMMDD"}
+
+	var fields []mt940Field
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if i := strings.Index(line, "{4:"); i >= 0 {
+			line = line[i+3:]
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "-" || trimmed == "-}" || strings.HasPrefix(trimmed, "{") {
+			continue
+		}
+		if m := mt940Tag.FindStringSubmatch(line); m != nil {
+			fields = append(fields, mt940Field{tag: m[1], value: line[len(m[0]):], line: n})
+		} else if len(fields) > 0 {
+			// Continuation of a multi-line field.
+			fields[len(fields)-1].value += "\n" + line
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read MT940: %w", err)
+	}
+
+	var (
+		account  string
+		currency = "EUR"
+		balance  float64
+		haveBal  bool
+		accounts = make(map[string]bool)
+		sawStmt  bool
+	)
+	for i, f := range fields {
+		switch f.tag {
+		case "25":
+			sawStmt = true
+			// Some banks put the currency after the account: NL91RABO... EUR.
+			account = strings.TrimSpace(f.value)
+			if parts := strings.Fields(account); len(parts) == 2 && len(parts[1]) == 3 {
+				account = parts[0]
+			}
+			accounts[account] = true
+		case "60F", "60M":
+			mark, _, ccy, amount, err := parseMT940Balance(f.value)
+			if err != nil {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("line %d: opening balance: %v", f.line, err))
+				haveBal = false
+				continue
+			}
+			currency, balance, haveBal = ccy, amount, true
+			if mark == "D" {
+				balance = -amount
+			}
+		case "62F", "62M":
+			mark, _, _, amount, err := parseMT940Balance(f.value)
+			if err != nil || !haveBal {
+				continue
+			}
+			if mark == "D" {
+				amount = -amount
+			}
+			if math.Abs(amount-balance) > 0.005 {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("line %d: closing balance %.2f doesn't match the opening balance plus bookings (%.2f)", f.line, amount, balance))
+			}
+		case "61":
+			details := ""
+			if i+1 < len(fields) && fields[i+1].tag == "86" {
+				details = fields[i+1].value
+			}
+			tx, err := convertMT940Booking(f.value, details, currency)
+			if err != nil {
+				result.Errors = append(result.Errors, RowError{Line: f.line, Error: err.Error()})
+				// The running balance can't be trusted past a booking
+				// that wasn't read.
+				haveBal = false
+				continue
+			}
+			if haveBal {
+				if tx["type"] == "send" {
+					balance -= tx["amount"].(float64)
+				} else {
+					balance += tx["amount"].(float64)
+				}
+				tx["balance_after"] = round2(balance)
+			}
+			if account != "" {
+				tx["account"] = account
+				if ref, ok := tx["id"].(string); ok {
+					tx["id"] = "mt940:" + account + ":" + ref
+				}
+			} else if ref, ok := tx["id"].(string); ok {
+				tx["id"] = "mt940:" + ref
+			}
+			result.Transactions = append(result.Transactions, tx)
+		}
+	}
+	if !sawStmt {
+		return nil, errors.New("no :25: account field; is this an MT940 file?")
+	}
+	for acct := range accounts {
+		result.Accounts = append(result.Accounts, acct)
+	}
+	sort.Strings(result.Accounts)
+	return result, nil
+}
+
+// parseMT940Balance reads C/D mark, YYMMDD date, currency and amount, e.g.
+// C260101EUR1234,56.
+func parseMT940Balance(s string) (string, time.Time, string, float64, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 11 || (s[0] != 'C' && s[0] != 'D') {
+		return "", time.Time{}, "", 0, fmt.Errorf("%q is not a balance", s)
+	}
+	date, err := parseMT940Date(s[1:7])
+	if err != nil {
+		return "", time.Time{}, "", 0, err
+	}
+	amount, err := parseAmount(s[10:], true)
+	if err != nil {
+		return "", time.Time{}, "", 0, err
+	}
+	return s[:1], date, s[7:10], amount, nil
+}
+
+// parseMT940Date reads YYMMDD; two-digit years are in 1980-2079.
+func parseMT940Date(s string) (time.Time, error) {
+	t, err := time.Parse("060102", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a YYMMDD date", s)
+	}
+	if t.Year() < 1980 {
+		t = t.AddDate(100, 0, 0)
+	}
+	return t, nil
+}
+
+// convertMT940Booking turns a :61: line and its :86: details into a
+// transaction. The id is the bank's reference, or the customer's when the
+// bank gives none; importMT940 qualifies it with the account.
+func convertMT940Booking(booking, details, currency string) (map[string]interface{}, error) {
+	lines := strings.SplitN(booking, "\n", 2)
+	m := mt940Booking.FindStringSubmatch(strings.TrimSpace(lines[0]))
+	if m == nil {
+		return nil, fmt.Errorf("%q is not a :61: booking line", strings.TrimSpace(lines[0]))
+	}
+	valueDate, err := parseMT940Date(m[1])
+	if err != nil {
+		return nil, fmt.Errorf("value date: %v", err)
+	}
+	booked := valueDate
+	if m[2] != "" {
+		// MMDD in the value date's year, unless that puts it more than
+		// half a year away - a booking on 0102 for value 1231.
+		b, err := time.Parse("20060102", fmt.Sprintf("%04d%s", valueDate.Year(), m[2]))
+		if err != nil {
+			return nil, fmt.Errorf("booking date %q is not MMDD", m[2])
+		}
+		switch {
+		case b.Sub(valueDate) > 183*24*time.Hour:
+			b = b.AddDate(-1, 0, 0)
+		case valueDate.Sub(b) > 183*24*time.Hour:
+			b = b.AddDate(1, 0, 0)
+		}
+		booked = b
+	}
+	v, err := parseAmount(m[5], true)
+	if err != nil {
+		return nil, fmt.Errorf("amount: %v", err)
+	}
+	amount := round2(v)
+	if amount == 0 {
+		return nil, errors.New("amount is zero")
+	}
+
+	// RC reverses a credit, so money leaves the account just like a D;
+	// RD reverses a debit and brings it back.
+	txType := "receive"
+	if m[3] == "D" || m[3] == "RC" {
+		txType = "send"
+	}
+	category := mt940Categories[m[6]]
+	if strings.HasPrefix(m[3], "R") {
+		category = "reversal"
+	}
+
+	customerRef, bankRef := m[7], ""
+	if i := strings.Index(customerRef, "//"); i >= 0 {
+		customerRef, bankRef = customerRef[:i], customerRef[i+2:]
+	}
+	customerRef, bankRef = strings.TrimSpace(customerRef), strings.TrimSpace(bankRef)
+	supplementary := ""
+	if len(lines) > 1 {
+		supplementary = strings.TrimSpace(lines[1])
+	}
+
+	info := parseMT940Details(details)
+	description := firstNonEmpty(info.remittance, info.bookingText, supplementary)
+	tx := map[string]interface{}{
+		"timestamp":    booked.Format(time.RFC3339),
+		"value_date":   valueDate.Format("2006-01-02"),
+		"type":         txType,
+		"amount":       amount,
+		"currency":     currency,
+		"counterparty": firstNonEmpty(info.name, description),
+		"description":  description,
+		"category":     category,
+	}
+	if info.account != "" {
+		tx["counterparty_account"] = info.account
+	}
+	if ref := firstNonEmpty(bankRef, customerRef); ref != "" && !strings.EqualFold(ref, "NONREF") {
+		tx["id"] = ref
+	}
+	return tx, nil
+}
+
+// mt940Details is what could be read out of a :86: field.
+type mt940Details struct {
+	bookingText string
+	name        string
+	account     string
+	remittance  string
+}
+
+// mt940SlashKeys are the field names of the /KEY/value/ style of :86:.
+var mt940SlashKeys = map[string]bool{
+	"TRTP": true, "IBAN": true, "BIC": true, "NAME": true, "REMI": true, "EREF": true,
+	"MARF": true, "CSID": true, "ORDP": true, "BENM": true, "ADDR": true, "CNTP": true,
+	"ID": true, "PREF": true, "RTRN": true,
+}
+
+// parseMT940Details splits a :86: field into its parts where the bank
+// structured it.
+func parseMT940Details(s string) mt940Details {
+	// Lines are wrapped at a fixed width, not at word boundaries.
+	s = strings.ReplaceAll(s, "\n", "")
+	head := s
+	if len(head) > 6 {
+		head = head[:6]
+	}
+	var d mt940Details
+	switch {
+	case strings.Contains(head, "?"):
+		// German: three-digit business code, then ?NN subfields.
+		sub := make(map[string]string)
+		var order []string
+		for _, part := range strings.Split(s, "?")[1:] {
+			if len(part) < 2 {
+				continue
+			}
+			code := part[:2]
+			if _, seen := sub[code]; !seen {
+				order = append(order, code)
+			}
+			sub[code] += part[2:]
+		}
+		d.bookingText = strings.TrimSpace(sub["00"])
+		d.name = strings.TrimSpace(sub["32"] + sub["33"])
+		d.account = strings.TrimSpace(sub["31"])
+		var remittance strings.Builder
+		for _, code := range order {
+			if (code >= "20" && code <= "29") || (code >= "60" && code <= "63") {
+				remittance.WriteString(sub[code])
+			}
+		}
+		d.remittance = sepaRemittance(remittance.String())
+	case strings.HasPrefix(s, "/"):
+		parts := strings.Split(s, "/")
+		values := make(map[string]string)
+		key := ""
+		for _, p := range parts[1:] {
+			if mt940SlashKeys[p] {
+				key = p
+				continue
+			}
+			if key != "" {
+				if values[key] != "" {
+					values[key] += "/"
+				}
+				values[key] += p
+			}
+		}
+		d.bookingText = strings.TrimSpace(values["TRTP"])
+		d.name = strings.TrimSpace(values["NAME"])
+		d.account = strings.TrimSpace(values["IBAN"])
+		if cntp := strings.Split(values["CNTP"], "/"); values["CNTP"] != "" {
+			// CNTP is account/BIC/name/city.
+			d.account = firstNonEmpty(d.account, strings.TrimSpace(cntp[0]))
+			if len(cntp) > 2 {
+				d.name = firstNonEmpty(d.name, strings.TrimSpace(cntp[2]))
+			}
+		}
+		d.remittance = strings.TrimSpace(values["REMI"])
+		if strings.HasPrefix(d.remittance, "USTD//") {
+			d.remittance = strings.TrimSpace(strings.TrimPrefix(d.remittance, "USTD//"))
+		}
+	default:
+		d.remittance = strings.TrimSpace(s)
+	}
+	return d
+}
+
+// sepaRemittance picks the free text out of German SEPA remittance, which
+// prefixes its parts with EREF+, KREF+, MREF+, CRED+ and SVWZ+.
+func sepaRemittance(s string) string {
+	if i := strings.Index(s, "SVWZ+"); i >= 0 {
+		s = s[i+len("SVWZ+"):]
+		for _, next := range []string{"ABWA+", "ABWE+", "IBAN+", "BIC+"} {
+			if j := strings.Index(s, next); j >= 0 {
+				s = s[:j]
+			}
+		}
+		return strings.TrimSpace(s)
+	}
+	for _, prefix := range []string{"EREF+", "KREF+", "MREF+", "CRED+"} {
+		if strings.HasPrefix(s, prefix) {
+			// Only references, no free text.
+			return ""
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+// looksLikeMT940 reports whether s has an account field and at least one
+// booking or balance.
+func looksLikeMT940(s string) bool {
+	return strings.Contains(s, ":25:") && (strings.Contains(s, ":61:") || strings.Contains(s, ":60F:"))
+}
@@ -0,0 +1,7 @@
+package main
+
+import "testing"
+
+func TestMT940Fixtures(t *testing.T) {
+	checkImportFixtures(t, "mt940_*")
+}
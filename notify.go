@@ -0,0 +1,740 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"text/template"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+	"github.com/becomeliminal/nim-go-sdk/tools"
+)
+
+// ============================================================================
+// NOTIFICATIONS
+// ============================================================================
+// Delivers queued insights outside the chat. Each user picks channels
+// (signed webhook, email, push relay), quiet hours and a rate limit. Messages
+// are rendered from templates, retried with exponential backoff and moved to
+// a dead-letter store once retries are exhausted.
+//
+// Server-wide channel settings come from the environment:
+//   SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD, SMTP_FROM
+//   PUSH_RELAY_URL, PUSH_RELAY_TOKEN
+
+// notificationMessage is a rendered message ready for a channel.
+type notificationMessage struct {
+	Subject string  `json:"subject"`
+	Body    string  `json:"body"`
+	Insight Insight `json:"insight"`
+}
+
+// notificationChannel delivers a message to one user destination.
+type notificationChannel interface {
+	send(ctx context.Context, prefs NotificationPrefs, msg notificationMessage) error
+}
+
+// ----------------------------------------------------------------------------
+// Webhook channel
+// ----------------------------------------------------------------------------
+
+// webhookChannel POSTs the insight as JSON, signed with the user's secret:
+//
+//	X-NeuraPay-Timestamp: unix seconds
+//	X-NeuraPay-Signature: sha256=hex(HMAC-SHA256(secret, timestamp + "." + body))
+type webhookChannel struct {
+	client *http.Client
+}
+
+func signWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (c *webhookChannel) send(ctx context.Context, prefs NotificationPrefs, msg notificationMessage) error {
+	if prefs.WebhookURL == "" {
+		return errors.New("no webhook URL configured")
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"type":    "insight",
+		"subject": msg.Subject,
+		"body":    msg.Body,
+		"insight": msg.Insight,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, prefs.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-NeuraPay-Timestamp", strconv.FormatInt(ts, 10))
+	req.Header.Set("X-NeuraPay-Signature", signWebhook(prefs.WebhookSecret, ts, body))
+	return doDelivery(c.client, req)
+}
+
+// ----------------------------------------------------------------------------
+// Email channel
+// ----------------------------------------------------------------------------
+
+// smtpChannel sends plain-text email. Without SMTP_USERNAME it sends
+// unauthenticated, which works with local stand-ins such as MailHog.
+type smtpChannel struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+func (c *smtpChannel) send(ctx context.Context, prefs NotificationPrefs, msg notificationMessage) error {
+	if c.host == "" {
+		return errors.New("email is not configured on this server")
+	}
+	if prefs.Email == "" {
+		return errors.New("no email address configured")
+	}
+	var auth smtp.Auth
+	if c.username != "" {
+		auth = smtp.PlainAuth("", c.username, c.password, c.host)
+	}
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: NeuraPay <%s>\r\n", c.from)
+	fmt.Fprintf(&body, "To: %s\r\n", prefs.Email)
+	fmt.Fprintf(&body, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&body, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	body.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	body.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return smtp.SendMail(net.JoinHostPort(c.host, c.port), auth, c.from, []string{prefs.Email}, []byte(body.String()))
+}
+
+// ----------------------------------------------------------------------------
+// Push relay channel
+// ----------------------------------------------------------------------------
+
+// pushChannel hands messages to a generic push relay (e.g. a service that
+// fans out to APNs/FCM) as {device_token, title, body, data}.
+type pushChannel struct {
+	relayURL string
+	token    string
+	client   *http.Client
+}
+
+func (c *pushChannel) send(ctx context.Context, prefs NotificationPrefs, msg notificationMessage) error {
+	if c.relayURL == "" {
+		return errors.New("push relay is not configured on this server")
+	}
+	if prefs.PushDeviceToken == "" {
+		return errors.New("no push device registered")
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"device_token": prefs.PushDeviceToken,
+		"title":        msg.Subject,
+		"body":         msg.Body,
+		"data":         map[string]string{"insight_id": msg.Insight.ID, "type": msg.Insight.Type},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.relayURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return doDelivery(c.client, req)
+}
+
+// doDelivery performs an HTTP delivery and treats any non-2xx as failure.
+func doDelivery(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+// Templates
+// ----------------------------------------------------------------------------
+
+// notificationTemplates renders subject and body per insight type, falling
+// back to "default".
+var notificationTemplates = map[string]struct{ subject, body string }{
+	"default": {
+		subject: "NeuraPay: {{.Title}}",
+		body:    "{{.Message}}\n\nOpen NeuraPay to chat about it.",
+	},
+	"low_balance_forecast": {
+		subject: "⚠️ Heads up: {{.Title}}",
+		body:    "{{.Message}}\n\nWant help stretching it to payday? Open NeuraPay and ask.",
+	},
+	"large_transaction": {
+		subject: "NeuraPay: {{.Title}}",
+		body:    "{{.Message}}\n\nIf this wasn't you, contact Liminal support right away.",
+	},
+	"goal_reached": {
+		subject: "🎉 {{.Title}}",
+		body:    "{{.Message}}\n\nTime to set the next one?",
+	},
+	"interest_earned": {
+		subject: "💰 {{.Title}}",
+		body:    "{{.Message}}\n\nMoney making money - nice.",
+	},
+}
+
+var compiledNotificationTemplates = func() map[string][2]*template.Template {
+	out := make(map[string][2]*template.Template)
+	for name, t := range notificationTemplates {
+		out[name] = [2]*template.Template{
+			template.Must(template.New(name + ".subject").Parse(t.subject)),
+			template.Must(template.New(name + ".body").Parse(t.body)),
+		}
+	}
+	return out
+}()
+
+func renderNotification(insight Insight) (notificationMessage, error) {
+	tmpl, ok := compiledNotificationTemplates[insight.Type]
+	if !ok {
+		tmpl = compiledNotificationTemplates["default"]
+	}
+	var subject, body bytes.Buffer
+	if err := tmpl[0].Execute(&subject, insight); err != nil {
+		return notificationMessage{}, err
+	}
+	if err := tmpl[1].Execute(&body, insight); err != nil {
+		return notificationMessage{}, err
+	}
+	return notificationMessage{Subject: subject.String(), Body: body.String(), Insight: insight}, nil
+}
+
+// ----------------------------------------------------------------------------
+// Preferences, quiet hours and rate limiting
+// ----------------------------------------------------------------------------
+
+// NotificationPrefs are a user's delivery preferences.
+type NotificationPrefs struct {
+	Channels        []string `json:"channels"` // webhook, email, push
+	WebhookURL      string   `json:"webhook_url,omitempty"`
+	WebhookSecret   string   `json:"webhook_secret,omitempty"`
+	Email           string   `json:"email,omitempty"`
+	PushDeviceToken string   `json:"push_device_token,omitempty"`
+	QuietStart      string   `json:"quiet_start,omitempty"` // "22:00"
+	QuietEnd        string   `json:"quiet_end,omitempty"`   // "07:00"
+	Timezone        string   `json:"timezone,omitempty"`
+	MaxPerHour      int      `json:"max_per_hour"`
+	MinSeverity     string   `json:"min_severity"` // info, warning, celebrate
+}
+
+var severityRank = map[string]int{"info": 0, "celebrate": 1, "warning": 2}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, use HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// quietUntil returns when quiet hours end if now falls inside them, or the
+// zero time if delivery may happen now.
+func (p NotificationPrefs) quietUntil(now time.Time) time.Time {
+	if p.QuietStart == "" || p.QuietEnd == "" {
+		return time.Time{}
+	}
+	loc := time.UTC
+	if p.Timezone != "" {
+		if l, err := time.LoadLocation(p.Timezone); err == nil {
+			loc = l
+		}
+	}
+	start, err1 := parseClock(p.QuietStart)
+	end, err2 := parseClock(p.QuietEnd)
+	if err1 != nil || err2 != nil || start == end {
+		return time.Time{}
+	}
+
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	inQuiet := (start < end && minute >= start && minute < end) ||
+		(start > end && (minute >= start || minute < end))
+	if !inQuiet {
+		return time.Time{}
+	}
+	until := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, loc)
+	if !until.After(local) {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until
+}
+
+// ----------------------------------------------------------------------------
+// Dispatcher
+// ----------------------------------------------------------------------------
+
+// Delivery is one message for one channel, retried until it succeeds or is
+// dead-lettered.
+type Delivery struct {
+	ID          string              `json:"id"`
+	UserID      string              `json:"user_id"`
+	Channel     string              `json:"channel"`
+	Message     notificationMessage `json:"message"`
+	Attempts    int                 `json:"attempts"`
+	NextAttempt time.Time           `json:"next_attempt"`
+	LastError   string              `json:"last_error,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+}
+
+// maxDeliveryAttempts before a delivery moves to the dead-letter store.
+const maxDeliveryAttempts = 5
+
+type notifierState struct {
+	Prefs      map[string]NotificationPrefs `json:"prefs"`
+	Outbox     []Delivery                   `json:"outbox"`
+	DeadLetter []Delivery                   `json:"dead_letter"`
+	Sent       map[string][]time.Time       `json:"sent"` // per user, for rate limiting
+}
+
+// notifier routes insights to user channels.
+type notifier struct {
+	channels map[string]notificationChannel
+	store    *jsonStore
+
+	mu    sync.Mutex
+	state notifierState
+}
+
+func newNotifier(channels map[string]notificationChannel) *notifier {
+	n := &notifier{
+		channels: channels,
+		store:    newJSONStore("notifications.json"),
+		state: notifierState{
+			Prefs: make(map[string]NotificationPrefs),
+			Sent:  make(map[string][]time.Time),
+		},
+	}
+	if err := n.store.load(&n.state); err != nil {
+		log.Printf("⚠️  Notification state not loaded: %v", err)
+	}
+	return n
+}
+
+// defaultNotificationChannels builds the channels from the config.
+func defaultNotificationChannels(cfg NotificationConfig) map[string]notificationChannel {
+	client := &http.Client{Timeout: 10 * time.Second}
+	port := cfg.SMTP.Port
+	if port == "" {
+		port = "587"
+	}
+	return map[string]notificationChannel{
+		"webhook": &webhookChannel{client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{DialContext: publicOnlyDialer().DialContext},
+		}},
+		"email": &smtpChannel{
+			host:     cfg.SMTP.Host,
+			port:     port,
+			username: cfg.SMTP.Username,
+			password: cfg.SMTP.Password,
+			from:     cfg.SMTP.From,
+		},
+		"push": &pushChannel{
+			relayURL: cfg.Push.RelayURL,
+			token:    cfg.Push.Token,
+			client:   client,
+		},
+	}
+}
+
+func (n *notifier) persist() {
+	if err := n.store.save(n.state); err != nil {
+		log.Printf("⚠️  Failed to save notification state: %v", err)
+	}
+}
+
+func (n *notifier) prefs(userID string) NotificationPrefs {
+	p, ok := n.state.Prefs[userID]
+	if !ok {
+		p = NotificationPrefs{MaxPerHour: 5, MinSeverity: "info"}
+	}
+	return p
+}
+
+// webhookSecret returns the secret that signs a user's webhooks, replacing
+// it first when rotate is set. It is "" while no webhook is configured.
+func (n *notifier) webhookSecret(userID string, rotate bool) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	prefs, ok := n.state.Prefs[userID]
+	if !ok || prefs.WebhookURL == "" {
+		return ""
+	}
+	if rotate || prefs.WebhookSecret == "" {
+		prefs.WebhookSecret = newWebhookSecret()
+		n.state.Prefs[userID] = prefs
+		n.persist()
+	}
+	return prefs.WebhookSecret
+}
+
+// enqueue renders an insight and queues one delivery per enabled channel.
+// It is subscribed to the insight queue.
+func (n *notifier) enqueue(userID string, insight Insight) {
+	msg, err := renderNotification(insight)
+	if err != nil {
+		log.Printf("⚠️  Failed to render notification for %s: %v", insight.ID, err)
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	prefs := n.prefs(userID)
+	if severityRank[insight.Severity] < severityRank[prefs.MinSeverity] {
+		return
+	}
+	for _, channel := range prefs.Channels {
+		n.state.Outbox = append(n.state.Outbox, Delivery{
+			ID:          fmt.Sprintf("%s-%s", insight.ID, channel),
+			UserID:      userID,
+			Channel:     channel,
+			Message:     msg,
+			NextAttempt: time.Now(),
+			CreatedAt:   time.Now(),
+		})
+	}
+	if len(prefs.Channels) > 0 {
+		n.persist()
+	}
+}
+
+// run delivers due messages until ctx is cancelled.
+func (n *notifier) run(ctx context.Context) {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+	for {
+		n.flush(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// flush attempts every delivery that is due, honoring quiet hours and the
+// per-user rate limit.
+func (n *notifier) flush(ctx context.Context) {
+	now := time.Now()
+
+	n.mu.Lock()
+	var due []Delivery
+	var waiting []Delivery
+	for _, d := range n.state.Outbox {
+		prefs := n.prefs(d.UserID)
+		if until := prefs.quietUntil(now); !until.IsZero() && until.After(d.NextAttempt) {
+			d.NextAttempt = until
+		}
+		if d.NextAttempt.After(now) || !n.allowLocked(d.UserID, prefs, now) {
+			waiting = append(waiting, d)
+			continue
+		}
+		due = append(due, d)
+	}
+	n.state.Outbox = waiting
+	n.mu.Unlock()
+
+	for _, d := range due {
+		n.mu.Lock()
+		prefs := n.prefs(d.UserID)
+		n.mu.Unlock()
+
+		err := errors.New("unknown channel " + d.Channel)
+		if channel, ok := n.channels[d.Channel]; ok {
+			sendCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			err = channel.send(sendCtx, prefs, d.Message)
+			cancel()
+		}
+		d.Attempts++
+
+		n.mu.Lock()
+		switch {
+		case err == nil:
+			log.Printf("📣 Delivered %s via %s", d.Message.Insight.ID, d.Channel)
+		case d.Attempts >= maxDeliveryAttempts:
+			d.LastError = err.Error()
+			n.state.DeadLetter = append(n.state.DeadLetter, d)
+			log.Printf("⚠️  Dead-lettered %s via %s: %v", d.Message.Insight.ID, d.Channel, err)
+		default:
+			d.LastError = err.Error()
+			d.NextAttempt = time.Now().Add(time.Minute << (d.Attempts - 1))
+			n.state.Outbox = append(n.state.Outbox, d)
+		}
+		n.mu.Unlock()
+	}
+
+	if len(due) > 0 {
+		n.mu.Lock()
+		n.persist()
+		n.mu.Unlock()
+	}
+}
+
+// allowLocked applies the per-user hourly limit and, if allowed, records the
+// send. Callers hold n.mu.
+func (n *notifier) allowLocked(userID string, prefs NotificationPrefs, now time.Time) bool {
+	var recent []time.Time
+	for _, t := range n.state.Sent[userID] {
+		if now.Sub(t) < time.Hour {
+			recent = append(recent, t)
+		}
+	}
+	if prefs.MaxPerHour > 0 && len(recent) >= prefs.MaxPerHour {
+		n.state.Sent[userID] = recent
+		return false
+	}
+	n.state.Sent[userID] = append(recent, now)
+	return true
+}
+
+// validWebhookURL accepts https URLs whose host resolves only to public
+// addresses, so a webhook cannot be pointed at the server's own network.
+func validWebhookURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL %q", raw)
+	}
+	if u.Scheme != "https" {
+		return errors.New("webhook URL must use https")
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("webhook host %q does not resolve", u.Hostname())
+	}
+	for _, a := range addrs {
+		if !publicIP(a.IP) {
+			return fmt.Errorf("webhook host %q resolves to a private or local address", u.Hostname())
+		}
+	}
+	return nil
+}
+
+// sharedAddressSpace is 100.64.0.0/10, the carrier-grade NAT range, which
+// net.IP.IsPrivate doesn't cover.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// publicIP reports whether ip is routable on the internet: not loopback,
+// private, shared (carrier-grade NAT), link-local, multicast or unspecified.
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || sharedAddressSpace.Contains(ip) ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// publicOnlyDialer refuses connections to non-public addresses. The check
+// runs on the address actually dialled, so a host that resolved to a public
+// address when the URL was saved cannot later be re-pointed inside.
+func publicOnlyDialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return fmt.Errorf("refusing to deliver to non-public address %s", host)
+			}
+			return nil
+		},
+	}
+}
+
+func newWebhookSecret() string {
+	b := make([]byte, 24)
+	_, _ = rand.Read(b)
+	return "whsec_" + hex.EncodeToString(b)
+}
+
+func createNotificationSettingsTool(notify *notifier) core.Tool {
+	return tools.New("configure_notifications").
+		Description("View or change where proactive alerts are delivered outside the chat: signed webhook, email, or push. Also sets quiet hours, an hourly rate limit and the minimum severity. Returns recent failed deliveries.").
+		Schema(tools.ObjectSchema(map[string]interface{}{
+			"channels":          tools.StringProperty("Comma-separated channels to use: webhook, email, push (empty string turns notifications off)"),
+			"webhook_url":       tools.StringProperty("HTTPS URL to POST signed alerts to"),
+			"email":             tools.StringProperty("Email address for alerts"),
+			"push_device_token": tools.StringProperty("Device token registered by the mobile app"),
+			"quiet_start":       tools.StringProperty("Start of quiet hours, HH:MM"),
+			"quiet_end":         tools.StringProperty("End of quiet hours, HH:MM"),
+			"timezone":          tools.StringProperty("IANA timezone for quiet hours, e.g. Europe/London"),
+			"max_per_hour":      tools.IntegerProperty("Maximum notifications per hour (default: 5)"),
+			"min_severity":      tools.StringProperty("Only notify at or above: info, celebrate, warning"),
+		})).
+		Handler(func(ctx context.Context, toolParams *core.ToolParams) (*core.ToolResult, error) {
+			var params struct {
+				Channels        *string `json:"channels"`
+				WebhookURL      *string `json:"webhook_url"`
+				Email           *string `json:"email"`
+				PushDeviceToken *string `json:"push_device_token"`
+				QuietStart      *string `json:"quiet_start"`
+				QuietEnd        *string `json:"quiet_end"`
+				Timezone        *string `json:"timezone"`
+				MaxPerHour      *int    `json:"max_per_hour"`
+				MinSeverity     *string `json:"min_severity"`
+			}
+			if err := json.Unmarshal(toolParams.Input, &params); err != nil {
+				return &core.ToolResult{
+					Success: false,
+					Error:   fmt.Sprintf("invalid input: %v", err),
+				}, nil
+			}
+			fail := func(msg string) (*core.ToolResult, error) {
+				return &core.ToolResult{Success: false, Error: msg}, nil
+			}
+
+			// Resolving the webhook host can be slow; do it before locking.
+			if params.WebhookURL != nil {
+				if err := validWebhookURL(ctx, *params.WebhookURL); err != nil {
+					return fail(err.Error())
+				}
+			}
+
+			notify.mu.Lock()
+			defer notify.mu.Unlock()
+			prefs := notify.prefs(toolParams.UserID)
+			newSecret := false
+
+			if params.Channels != nil {
+				prefs.Channels = nil
+				for _, c := range strings.Split(*params.Channels, ",") {
+					c = strings.TrimSpace(strings.ToLower(c))
+					if c == "" {
+						continue
+					}
+					if _, ok := notify.channels[c]; !ok {
+						return fail(fmt.Sprintf("unknown channel %q", c))
+					}
+					prefs.Channels = append(prefs.Channels, c)
+				}
+			}
+			if params.WebhookURL != nil {
+				prefs.WebhookURL = *params.WebhookURL
+				if prefs.WebhookSecret == "" {
+					prefs.WebhookSecret = newWebhookSecret()
+					newSecret = true
+				}
+			}
+			if params.Email != nil {
+				addr, err := mail.ParseAddress(strings.TrimSpace(*params.Email))
+				if err != nil {
+					return fail(fmt.Sprintf("invalid email address %q", *params.Email))
+				}
+				prefs.Email = addr.Address
+			}
+			if params.PushDeviceToken != nil {
+				prefs.PushDeviceToken = *params.PushDeviceToken
+			}
+			if params.QuietStart != nil {
+				prefs.QuietStart = *params.QuietStart
+			}
+			if params.QuietEnd != nil {
+				prefs.QuietEnd = *params.QuietEnd
+			}
+			if params.Timezone != nil {
+				if _, err := time.LoadLocation(*params.Timezone); err != nil {
+					return fail(fmt.Sprintf("unknown timezone %q", *params.Timezone))
+				}
+				prefs.Timezone = *params.Timezone
+			}
+			if params.MaxPerHour != nil {
+				prefs.MaxPerHour = *params.MaxPerHour
+			}
+			if params.MinSeverity != nil {
+				if _, ok := severityRank[*params.MinSeverity]; !ok {
+					return fail("min_severity must be info, celebrate or warning")
+				}
+				prefs.MinSeverity = *params.MinSeverity
+			}
+			for _, clock := range []string{prefs.QuietStart, prefs.QuietEnd} {
+				if clock != "" {
+					if _, err := parseClock(clock); err != nil {
+						return fail(err.Error())
+					}
+				}
+			}
+			for _, c := range prefs.Channels {
+				switch {
+				case c == "webhook" && prefs.WebhookURL == "":
+					return fail("set webhook_url to use the webhook channel")
+				case c == "email" && prefs.Email == "":
+					return fail("set email to use the email channel")
+				case c == "push" && prefs.PushDeviceToken == "":
+					return fail("set push_device_token to use the push channel")
+				}
+			}
+
+			notify.state.Prefs[toolParams.UserID] = prefs
+			notify.persist()
+
+			var failed []Delivery
+			for _, d := range notify.state.DeadLetter {
+				if d.UserID == toolParams.UserID {
+					failed = append(failed, d)
+				}
+			}
+			if len(failed) > 5 {
+				failed = failed[len(failed)-5:]
+			}
+
+			result := map[string]interface{}{
+				"channels":          prefs.Channels,
+				"webhook_url":       prefs.WebhookURL,
+				"email":             prefs.Email,
+				"push_registered":   prefs.PushDeviceToken != "",
+				"quiet_hours":       map[string]string{"start": prefs.QuietStart, "end": prefs.QuietEnd, "timezone": prefs.Timezone},
+				"max_per_hour":      prefs.MaxPerHour,
+				"min_severity":      prefs.MinSeverity,
+				"failed_deliveries": failed,
+			}
+			if newSecret {
+				// The secret never goes back through the model; the user's app
+				// reads it from the companion API.
+				result["webhook_secret"] = "generated; the app shows it from GET /v1/notifications/webhook-secret"
+			}
+
+			return &core.ToolResult{
+				Success: true,
+				Data:    result,
+			}, nil
+		}).
+		Build()
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+func TestPublicIP(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{"8.8.8.8", true},
+		{"2606:4700:4700::1111", true},
+		{"100.63.255.255", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"100.64.0.1", false},
+		{"100.127.255.254", false},
+		{"169.254.169.254", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+		{"::1", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"::ffff:10.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := publicIP(net.ParseIP(tt.ip)); got != tt.public {
+			t.Errorf("publicIP(%s) = %v, want %v", tt.ip, got, tt.public)
+		}
+	}
+}
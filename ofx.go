@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// OFX / QFX IMPORTER
+// ============================================================================
+// OFX is what "Download to Quicken/Money" buttons produce; QFX is the same
+// thing with a few Intuit tags added. Version 1 files are SGML, where leaf
+// elements are never closed (<TRNAMT>-12.50), and version 2 files are XML.
+// Both are read by the same tag scanner: a leaf is a tag followed by text,
+// and only aggregates like <STMTTRN> need their closing tag.
+//
+// Amounts are signed from the account holder's side on bank and card
+// statements alike, so a negative TRNAMT is money out.
+
+// ofxCategories maps transaction types that say something about the
+// spending to a category; the rest leave it empty.
+var ofxCategories = map[string]string{
+	"INT":    "interest",
+	"DIV":    "interest",
+	"FEE":    "fees",
+	"SRVCHG": "fees",
+	"ATM":    "cash",
+}
+
+// ofxTag matches an opening or closing tag.
+var ofxTag = regexp.MustCompile(`<(/?)([A-Za-z0-9._]+)[^>]*>`)
+
+// importOFX reads the transactions of every statement in an OFX or QFX file.
+func importOFX(data []byte, format string) (*ImportResult, error) {
+	text, encoding, err := decodeCSV(data, "")
+	if err != nil {
+		return nil, err
+	}
+	start := strings.Index(strings.ToUpper(text), "<OFX>")
+	if start < 0 {
+		return nil, fmt.Errorf("no <OFX> element; is this an %s file?", strings.ToUpper(format))
+	}
+	result := &ImportResult{Format: format, Encoding: encoding, DateFormat: "OFX (YYYYMMDDHHMMSS[offset])"}
+
+	var (
+		currency = "USD"
+		account  string
+		tx       map[string]string // leaf values of the open <STMTTRN>
+		txLine   int
+		inPayee  bool
+		accounts = make(map[string]bool)
+	)
+	body := text[start:]
+	baseLine := strings.Count(text[:start], "\n") + 1
+	for _, m := range ofxTag.FindAllStringSubmatchIndex(body, -1) {
+		closing := m[3] > m[2]
+		name := strings.ToUpper(body[m[4]:m[5]])
+		if closing {
+			switch name {
+			case "STMTTRN":
+				if tx != nil {
+					convertOFXTransaction(result, tx, txLine, account, currency)
+					tx = nil
+				}
+			case "PAYEE":
+				inPayee = false
+			}
+			continue
+		}
+
+		// The value runs to the next tag; aggregates have none.
+		value := body[m[1]:]
+		if next := strings.IndexByte(value, '<'); next >= 0 {
+			value = value[:next]
+		}
+		value = strings.TrimSpace(html.UnescapeString(value))
+
+		switch name {
+		case "STMTTRN":
+			if tx != nil {
+				// Aggregates are closed even in SGML, but not by every bank.
+				convertOFXTransaction(result, tx, txLine, account, currency)
+			}
+			tx = make(map[string]string)
+			txLine = baseLine + strings.Count(body[:m[0]], "\n")
+			continue
+		case "PAYEE":
+			inPayee = true
+			continue
+		}
+		if value == "" {
+			continue
+		}
+		switch {
+		case tx != nil && inPayee && name == "NAME":
+			tx["PAYEE"] = value
+		case tx != nil:
+			tx[name] = value
+		case name == "CURDEF":
+			currency = strings.ToUpper(value)
+		case name == "ACCTID":
+			account = value
+			accounts[value] = true
+		}
+	}
+	if tx != nil {
+		convertOFXTransaction(result, tx, txLine, account, currency)
+	}
+	for acct := range accounts {
+		result.Accounts = append(result.Accounts, acct)
+	}
+	sort.Strings(result.Accounts)
+	if len(result.Transactions) == 0 && len(result.Errors) == 0 {
+		result.Warnings = append(result.Warnings, "the file has no <STMTTRN> transactions")
+	}
+	return result, nil
+}
+
+// convertOFXTransaction turns one <STMTTRN> into a transaction, or a
+// RowError at the line it starts on.
+func convertOFXTransaction(result *ImportResult, tx map[string]string, line int, account, currency string) {
+	fail := func(format string, args ...interface{}) {
+		result.Errors = append(result.Errors, RowError{Line: line, Error: fmt.Sprintf(format, args...)})
+	}
+	raw := firstNonEmpty(tx["DTPOSTED"], tx["DTUSER"])
+	if raw == "" {
+		fail("missing DTPOSTED")
+		return
+	}
+	ts, err := parseOFXDate(raw)
+	if err != nil {
+		fail("DTPOSTED: %v", err)
+		return
+	}
+	if tx["TRNAMT"] == "" {
+		fail("missing TRNAMT")
+		return
+	}
+	v, err := parseAmount(tx["TRNAMT"], false)
+	if err != nil {
+		fail("TRNAMT: %v", err)
+		return
+	}
+	amount := round2(math.Abs(v))
+	if amount == 0 {
+		fail("amount is zero")
+		return
+	}
+
+	txType := "receive"
+	if v < 0 {
+		txType = "send"
+	}
+	counterparty := firstNonEmpty(tx["NAME"], tx["PAYEE"], tx["MEMO"])
+	t := map[string]interface{}{
+		"timestamp":    ts.Format(time.RFC3339),
+		"type":         txType,
+		"amount":       amount,
+		"currency":     firstNonEmpty(strings.ToUpper(tx["CURSYM"]), currency),
+		"counterparty": counterparty,
+		"description":  firstNonEmpty(tx["MEMO"], counterparty),
+		"category":     ofxCategories[strings.ToUpper(tx["TRNTYPE"])],
+	}
+	if account != "" {
+		t["account"] = account
+	}
+	// FITIDs are unique per account, so re-importing an overlapping
+	// download doesn't double count.
+	if id := tx["FITID"]; id != "" {
+		t["id"] = "ofx:" + account + ":" + id
+	}
+	result.Transactions = append(result.Transactions, t)
+}
+
+// parseOFXDate reads YYYYMMDD[HHMMSS[.XXX]][[offset[:TZ]]], e.g.
+// 20260115120000.000[-5:EST]. Without an offset the time is UTC.
+func parseOFXDate(s string) (time.Time, error) {
+	orig := s
+	offset := 0
+	if i := strings.IndexByte(s, '['); i >= 0 {
+		tz := strings.TrimSuffix(s[i+1:], "]")
+		s = s[:i]
+		if j := strings.IndexByte(tz, ':'); j >= 0 {
+			tz = tz[:j]
+		}
+		hours, err := strconv.ParseFloat(tz, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("bad time zone in %q", orig)
+		}
+		offset = int(hours * 3600)
+	}
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		s = s[:i]
+	}
+	var layout string
+	switch len(s) {
+	case 8:
+		layout = "20060102"
+	case 12:
+		layout = "200601021504"
+	case 14:
+		layout = "20060102150405"
+	default:
+		return time.Time{}, fmt.Errorf("%q is not an OFX date", orig)
+	}
+	t, err := time.ParseInLocation(layout, s, time.FixedZone("", offset))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not an OFX date", orig)
+	}
+	return t, nil
+}
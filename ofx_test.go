@@ -0,0 +1,7 @@
+package main
+
+import "testing"
+
+func TestOFXFixtures(t *testing.T) {
+	checkImportFixtures(t, "*.[oq]fx")
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ============================================================================
+// PDF REPORT
+// ============================================================================
+// A text-and-table report needs no PDF library: pages are drawn with the
+// standard Helvetica fonts every viewer has, so nothing is embedded. Text is
+// WinAnsi encoded; characters outside it print as "?" and emoji are left
+// out. Column widths are estimated from Helvetica's metrics, which are exact
+// for digits, so amounts line up on the right.
+
+const (
+	pdfPageWidth  = 595.0 // A4, in points
+	pdfPageHeight = 842.0
+	pdfMargin     = 50.0
+	pdfRight      = pdfPageWidth - pdfMargin
+)
+
+// pdfDoc collects the content streams of the pages being drawn.
+type pdfDoc struct {
+	pages  []*bytes.Buffer
+	page   *bytes.Buffer
+	y      float64 // baseline of the next line, from the bottom
+	header func()  // redraws a table header on a new page
+}
+
+func newPDFDoc() *pdfDoc {
+	d := &pdfDoc{}
+	d.newPage()
+	return d
+}
+
+func (d *pdfDoc) newPage() {
+	d.page = &bytes.Buffer{}
+	d.pages = append(d.pages, d.page)
+	d.y = pdfPageHeight - pdfMargin
+	if d.header != nil {
+		d.header()
+	}
+}
+
+// advance moves down by h, starting a new page when it wouldn't fit.
+func (d *pdfDoc) advance(h float64) {
+	if d.y-h < pdfMargin {
+		d.newPage()
+	}
+	d.y -= h
+}
+
+// text draws s with its left edge at x on the current line.
+func (d *pdfDoc) text(x float64, bold bool, size float64, gray bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	color := "0 g"
+	if gray {
+		color = "0.42 0.45 0.52 rg"
+	}
+	fmt.Fprintf(d.page, "BT %s /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", color, font, size, x, d.y, pdfString(s))
+}
+
+// textRight draws s with its right edge at x.
+func (d *pdfDoc) textRight(x float64, bold bool, size float64, gray bool, s string) {
+	d.text(x-pdfTextWidth(s, size), bold, size, gray, s)
+}
+
+// rule draws a thin line under the current line.
+func (d *pdfDoc) rule() {
+	fmt.Fprintf(d.page, "0.87 0.89 0.93 RG 0.5 w %.2f %.2f m %.2f %.2f l S\n", pdfMargin, d.y-4, pdfRight, d.y-4)
+}
+
+// bar draws a filled bar of width w starting at x on the current line.
+func (d *pdfDoc) bar(x, w float64, r, g, b float64) {
+	fmt.Fprintf(d.page, "%.2f %.2f %.2f rg %.2f %.2f %.2f 6 re f\n", r, g, b, x, d.y, w)
+}
+
+// heading starts a section.
+func (d *pdfDoc) heading(title string) {
+	d.header = nil
+	d.advance(30)
+	d.text(pdfMargin, true, 13, false, title)
+	d.rule()
+	d.advance(6)
+}
+
+// paragraph draws wrapped text.
+func (d *pdfDoc) paragraph(x float64, size float64, gray bool, s string) {
+	for _, line := range pdfWrap(s, pdfRight-x, size) {
+		d.advance(size + 4)
+		d.text(x, false, size, gray, line)
+	}
+}
+
+// bytes assembles the PDF: catalog, page tree, the two fonts, then a page
+// object and content stream per page, each page numbered in its footer.
+func (d *pdfDoc) bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	var kids []string
+	for i := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 5+2*i))
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, page := range d.pages {
+		footer := fmt.Sprintf("Page %d of %d", i+1, len(d.pages))
+		fmt.Fprintf(page, "BT 0.42 0.45 0.52 rg /F1 8.0 Tf %.2f %.2f Td (%s) Tj ET\n", pdfRight-pdfTextWidth(footer, 8), pdfMargin/2, footer)
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", pdfPageWidth, pdfPageHeight, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// winAnsi maps the characters above Latin-1 that WinAnsiEncoding has.
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92,
+	'“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// pdfString encodes s as the inside of a PDF string literal.
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		case winAnsi[r] != 0:
+			fmt.Fprintf(&b, "\\%03o", winAnsi[r])
+		case pdfDropped(r):
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// pdfDropped reports whether r is an emoji or a joiner that goes with one.
+func pdfDropped(r rune) bool {
+	return r > 0xffff || (r >= 0x2600 && r <= 0x27bf) || r == 0xfe0f || r == 0x200d
+}
+
+// pdfTextWidth estimates the width of s in Helvetica at size.
+func pdfTextWidth(s string, size float64) float64 {
+	units := 0
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9', r == '$', r == '€', r == '£', r == '?':
+			units += 556
+		case r == '.', r == ',', r == ' ', r == '/', r == ':', r == 'i', r == 'l', r == 'j', r == 'I', r == '\'':
+			units += 278
+		case r == '-', r == '(', r == ')', r == 'r', r == 't', r == 'f':
+			units += 333
+		case r == 'm', r == 'M', r == 'W', r == '%':
+			units += 833
+		case r == 'w':
+			units += 722
+		case r >= 'A' && r <= 'Z':
+			units += 667
+		case pdfDropped(r):
+		default:
+			units += 556
+		}
+	}
+	return float64(units) * size / 1000
+}
+
+// pdfFit shortens s with an ellipsis to fit width.
+func pdfFit(s string, width, size float64) string {
+	if pdfTextWidth(s, size) <= width {
+		return s
+	}
+	r := []rune(s)
+	for len(r) > 0 && pdfTextWidth(string(r)+"…", size) > width {
+		r = r[:len(r)-1]
+	}
+	return strings.TrimSpace(string(r)) + "…"
+}
+
+// pdfWrap breaks s into lines that fit width.
+func pdfWrap(s string, width, size float64) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		candidate := strings.TrimSpace(line + " " + word)
+		if line != "" && pdfTextWidth(candidate, size) > width {
+			lines = append(lines, line)
+			candidate = word
+		}
+		line = candidate
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// writeReportPDF draws the monthly report.
+func writeReportPDF(w io.Writer, v reportView) error {
+	d := newPDFDoc()
+	d.advance(18)
+	d.text(pdfMargin, true, 18, false, v.Title)
+	d.advance(14)
+	meta := "Generated " + v.GeneratedAt
+	if len(v.Filters) > 0 {
+		meta += " - Filtered to " + strings.Join(v.Filters, ", ")
+	}
+	d.text(pdfMargin, false, 9, true, pdfFit(meta, pdfRight-pdfMargin, 9))
+
+	// Figures side by side.
+	d.advance(28)
+	column := (pdfRight - pdfMargin) / float64(len(v.Figures))
+	for i, f := range v.Figures {
+		d.text(pdfMargin+float64(i)*column, false, 8, true, strings.ToUpper(f.Label))
+	}
+	d.advance(16)
+	for i, f := range v.Figures {
+		d.text(pdfMargin+float64(i)*column, true, 14, false, f.Value)
+	}
+
+	if b := v.Budget; b != nil {
+		d.heading("Budget status")
+		d.advance(14)
+		d.text(pdfMargin, true, 10, false, strings.ReplaceAll(b.Status, "_", " "))
+		d.text(pdfMargin+80, false, 10, false, b.Summary)
+		for _, line := range []struct {
+			label  string
+			amount float64
+		}{{"Income", b.Income}, {"Bills", b.Bills}, {"Everyday spending", b.Spending}, {"Saved", b.Saved}, {"Left over", b.LeftOver}} {
+			d.advance(15)
+			bold := line.label == "Left over"
+			d.text(pdfMargin, bold, 10, false, line.label)
+			d.textRight(pdfRight, bold, 10, false, formatMoney(line.amount, b.Currency))
+		}
+	}
+
+	if len(v.Categories) > 0 {
+		d.heading("Spending by category")
+		for _, c := range v.Categories {
+			d.advance(15)
+			d.text(pdfMargin, false, 10, false, pdfFit(c.Category, 150, 10))
+			d.bar(pdfMargin+160, 160, 0.87, 0.89, 0.93)
+			d.bar(pdfMargin+160, 160*c.Share/100, 0.29, 0.42, 0.97)
+			d.textRight(pdfRight-100, false, 10, true, fmt.Sprintf("%.1f%%", c.Share))
+			d.textRight(pdfRight, false, 10, false, formatMoney(c.Amount, v.Currency))
+		}
+	}
+
+	if p := v.Personality; p != nil {
+		d.heading("Money personality")
+		if p.Archetype == "" {
+			d.paragraph(pdfMargin, 10, true, p.Note)
+		} else {
+			d.advance(15)
+			d.text(pdfMargin, true, 11, false, p.Archetype)
+			for _, t := range p.Traits {
+				d.paragraph(pdfMargin+10, 10, false, "• "+t)
+			}
+			if len(p.Strategies) > 0 {
+				d.advance(6)
+				d.paragraph(pdfMargin, 10, true, "What helps:")
+				for _, s := range p.Strategies {
+					d.paragraph(pdfMargin+10, 10, false, "• "+s)
+				}
+			}
+		}
+	}
+
+	d.heading("Transactions")
+	columns := []struct {
+		x     float64
+		width float64
+		title string
+	}{{pdfMargin, 60, "Date"}, {pdfMargin + 62, 120, "Counterparty"}, {pdfMargin + 186, 150, "Description"}, {pdfMargin + 340, 70, "Category"}}
+	header := func() {
+		d.y -= 14
+		for _, c := range columns {
+			d.text(c.x, true, 9, true, c.title)
+		}
+		d.textRight(pdfRight, true, 9, true, "Amount")
+		d.rule()
+		d.y -= 4
+	}
+	header()
+	d.header = header
+	for _, row := range v.Transactions {
+		d.advance(13)
+		for i, cell := range []string{row.Date, row.Counterparty, row.Description, row.Category} {
+			d.text(columns[i].x, false, 9, false, pdfFit(cell, columns[i].width, 9))
+		}
+		d.textRight(pdfRight, false, 9, false, row.Amount)
+	}
+
+	_, err := w.Write(d.bytes())
+	return err
+}
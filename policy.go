@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+)
+
+// ============================================================================
+// MONEY MOVEMENT POLICY
+// ============================================================================
+// The prompt tells the model about policy.max_transfer; this makes it a hard
+// limit. The model's writes go through ExecuteWrite, so an over-limit
+// transfer is refused before the user is even asked to confirm it.
+// Automations (round-ups, auto-save, smoothing, ...) move money through
+// Execute without a confirmation step, so check guards both paths; removing
+// it from Execute would let automations bypass the limit.
+
+// policyExecutor refuses money movements above the configured limit.
+type policyExecutor struct {
+	inner  core.ToolExecutor
+	policy PolicyConfig
+}
+
+func newPolicyExecutor(inner core.ToolExecutor, policy PolicyConfig) *policyExecutor {
+	return &policyExecutor{inner: inner, policy: policy}
+}
+
+func (p *policyExecutor) Execute(ctx context.Context, req *core.ExecuteRequest) (*core.ExecuteResponse, error) {
+	if resp := p.check(req); resp != nil {
+		return resp, nil
+	}
+	return p.inner.Execute(ctx, req)
+}
+
+func (p *policyExecutor) ExecuteWrite(ctx context.Context, req *core.ExecuteRequest) (*core.ExecuteResponse, error) {
+	if resp := p.check(req); resp != nil {
+		return resp, nil
+	}
+	return p.inner.ExecuteWrite(ctx, req)
+}
+
+func (p *policyExecutor) Confirm(ctx context.Context, userID, confirmationID string) (*core.ExecuteResponse, error) {
+	return p.inner.Confirm(ctx, userID, confirmationID)
+}
+
+func (p *policyExecutor) Cancel(ctx context.Context, userID, confirmationID string) error {
+	return p.inner.Cancel(ctx, userID, confirmationID)
+}
+
+// check returns a refusal for an over-limit write, or nil.
+func (p *policyExecutor) check(req *core.ExecuteRequest) *core.ExecuteResponse {
+	if p.policy.MaxTransfer <= 0 || !simWriteTools[req.Tool] {
+		return nil
+	}
+	// An amount the cap can't read is refused too, so the cap never fails
+	// open on input Liminal might still parse.
+	var in simInput
+	if err := json.Unmarshal(req.Input, &in); err != nil {
+		return &core.ExecuteResponse{Success: false, Error: fmt.Sprintf("%s refused: invalid input", req.Tool)}
+	}
+	amount, err := in.amount()
+	if err != nil {
+		return &core.ExecuteResponse{Success: false, Error: fmt.Sprintf("%s refused: %v", req.Tool, err)}
+	}
+	if amount <= p.policy.MaxTransfer {
+		return nil
+	}
+	return &core.ExecuteResponse{
+		Success: false,
+		Error:   fmt.Sprintf("%s of %.2f is over the %.2f limit for a single money movement", req.Tool, amount, p.policy.MaxTransfer),
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+)
+
+func TestPolicyExecutorFailsClosed(t *testing.T) {
+	policy := newPolicyExecutor(echoExecutor{}, PolicyConfig{MaxTransfer: 100})
+	tests := []struct {
+		tool    string
+		input   string
+		allowed bool
+	}{
+		{"send_money", `{"recipient":"@bob","amount":"50.00"}`, true},
+		{"send_money", `{"recipient":"@bob","amount":100}`, true},
+		{"send_money", `{"recipient":"@bob","amount":"150"}`, false},
+		{"send_money", `{"recipient":"@bob","amount":"1e3"}`, false},
+		{"send_money", `{"recipient":"@bob","amount":"lots"}`, false},
+		{"send_money", `{"recipient":"@bob"}`, false},
+		{"deposit_savings", `{"amount":[500]}`, false},
+		{"withdraw_savings", `not json`, false},
+		{"get_balance", `not json`, true},
+	}
+	for _, tt := range tests {
+		req := &core.ExecuteRequest{UserID: "u", Tool: tt.tool, Input: json.RawMessage(tt.input)}
+		for name, execute := range map[string]func(context.Context, *core.ExecuteRequest) (*core.ExecuteResponse, error){
+			"Execute":      policy.Execute,
+			"ExecuteWrite": policy.ExecuteWrite,
+		} {
+			resp, err := execute(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Success != tt.allowed {
+				t.Errorf("%s %s %s: allowed = %v, want %v (%s)", name, tt.tool, tt.input, resp.Success, tt.allowed, resp.Error)
+			}
+		}
+	}
+}
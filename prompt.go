@@ -0,0 +1,200 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// ============================================================================
+// SYSTEM PROMPT
+// ============================================================================
+// The prompt is assembled from a versioned template in prompts/ (or
+// model.system_prompt_file), filled in with:
+//
+//   - the tool catalog, generated from the registered tools' descriptions so
+//     it can't drift from what the model is actually offered
+//   - policy limits from the config
+//   - the user's context (name, currency, locale, goals) - server.Config
+//     takes one prompt for every conversation, so the template's "user"
+//     section is rendered per user and served by start_session instead
+//
+// model.prompt_version picks the template; `neurapay prompt preview` prints
+// the result for any version and user.
+
+//go:embed prompts/*.tmpl
+var promptFiles embed.FS
+
+// defaultPromptVersion is used when model.prompt_version is unset.
+const defaultPromptVersion = "v2"
+
+// promptVersions lists the built-in templates.
+func promptVersions() []string {
+	entries, _ := promptFiles.ReadDir("prompts")
+	var versions []string
+	for _, e := range entries {
+		versions = append(versions, strings.TrimSuffix(e.Name(), path.Ext(e.Name())))
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// PromptData is what the templates see.
+type PromptData struct {
+	Version string
+	Groups  []PromptToolGroup
+	Policy  PolicyConfig
+	User    UserContext // only set for the "user" section
+}
+
+// PromptToolGroup is one section of the tool catalog.
+type PromptToolGroup struct {
+	Title string
+	Tools []PromptTool
+}
+
+// PromptTool is one line of the tool catalog.
+type PromptTool struct {
+	Name         string
+	Summary      string
+	Confirmation bool // a money movement the user has to confirm
+	Flagged      bool // only some users have it
+}
+
+var promptFuncs = template.FuncMap{
+	"money": func(v float64) string { return fmt.Sprintf("$%.2f", v) },
+}
+
+// promptBuilder renders the configured template.
+type promptBuilder struct {
+	version  string
+	tmpl     *template.Template
+	system   string // name of the template holding the shared prompt
+	registry *toolRegistry
+	policy   PolicyConfig
+	contexts *userContextStore
+}
+
+// newPromptBuilder parses the template. A prompt file without a
+// {{define "system"}} block is used whole as the shared prompt.
+func newPromptBuilder(cfg *Config, registry *toolRegistry, contexts *userContextStore) (*promptBuilder, error) {
+	version := cfg.Model.PromptVersion
+	if version == "" {
+		version = defaultPromptVersion
+	}
+
+	var (
+		src []byte
+		err error
+	)
+	if f := cfg.Model.SystemPromptFile; f != "" {
+		version = f
+		src, err = os.ReadFile(f)
+	} else {
+		src, err = promptFiles.ReadFile("prompts/" + version + ".tmpl")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt %s: %w", version, err)
+	}
+
+	tmpl, err := template.New(version).Funcs(promptFuncs).Parse(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse prompt %s: %w", version, err)
+	}
+	b := &promptBuilder{
+		version:  version,
+		tmpl:     tmpl,
+		system:   version,
+		registry: registry,
+		policy:   cfg.Policy,
+		contexts: contexts,
+	}
+	if tmpl.Lookup("system") != nil {
+		b.system = "system"
+	}
+	return b, nil
+}
+
+// catalog groups the registered tools for the prompt.
+func (b *promptBuilder) catalog() []PromptToolGroup {
+	var groups []PromptToolGroup
+	index := make(map[string]int)
+	for _, tool := range b.registry.tools {
+		title := b.registry.group[tool.Name()]
+		i, ok := index[title]
+		if !ok {
+			i = len(groups)
+			index[title] = i
+			groups = append(groups, PromptToolGroup{Title: title})
+		}
+		_, flagged := b.registry.cfg.Flags[tool.Name()]
+		groups[i].Tools = append(groups[i].Tools, PromptTool{
+			Name:         tool.Name(),
+			Summary:      firstSentence(tool.Description()),
+			Confirmation: simWriteTools[tool.Name()],
+			Flagged:      flagged,
+		})
+	}
+	return groups
+}
+
+// firstSentence keeps the catalog to one line per tool; the model still gets
+// the full description with the tool definition.
+func firstSentence(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.Index(s, ". "); i >= 0 {
+		return s[:i+1]
+	}
+	return s
+}
+
+func (b *promptBuilder) render(name string, data PromptData) (string, error) {
+	var out strings.Builder
+	if err := b.tmpl.ExecuteTemplate(&out, name, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt %s: %w", b.version, err)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// shared renders the prompt every conversation gets, with any line about a
+// tool that wasn't registered scrubbed (see toolRegistry.systemPrompt).
+func (b *promptBuilder) shared() (string, error) {
+	out, err := b.render(b.system, PromptData{Version: b.version, Groups: b.catalog(), Policy: b.policy})
+	if err != nil {
+		return "", err
+	}
+	return b.registry.systemPrompt(out), nil
+}
+
+// user renders the per-user section; empty when the template has none or
+// there's nothing known about the user yet.
+func (b *promptBuilder) user(userID string) (string, error) {
+	if b.tmpl.Lookup("user") == nil || b.contexts == nil {
+		return "", nil
+	}
+	return b.render("user", PromptData{Version: b.version, Policy: b.policy, User: b.contexts.get(userID)})
+}
+
+// preview is the shared prompt followed by what start_session would add for
+// userID.
+func (b *promptBuilder) preview(userID string) (string, error) {
+	shared, err := b.shared()
+	if err != nil {
+		return "", err
+	}
+	if userID == "" {
+		return shared, nil
+	}
+	user, err := b.user(userID)
+	if err != nil {
+		return "", err
+	}
+	if user == "" {
+		user = "(nothing saved for this user)"
+	}
+	return fmt.Sprintf("%s\n\n----- start_session context for %s -----\n%s", shared, userID, user), nil
+}
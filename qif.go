@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// QIF IMPORTER
+// ============================================================================
+// QIF is the line-based format older Quicken and Microsoft Money exports
+// still use: a "!Type:" header, then records of one-letter fields (D date,
+// T amount, P payee, M memo, L category) each ended by "^". Amounts are
+// signed, negative for money out. Dates have no fixed format - 1/25'26,
+// 01/25/2026 and 25/01/2026 all occur - so they're detected the way CSV
+// dates are.
+
+// qifRecord is one "^"-terminated record with the line it starts on.
+type qifRecord struct {
+	line    int
+	fields  map[byte]string
+	account string
+}
+
+// importQIF reads the cash, bank and card transactions in a QIF file.
+// Investment and list sections (categories, memorized payees) are skipped.
+func importQIF(data []byte) (*ImportResult, error) {
+	text, encoding, err := decodeCSV(data, "")
+	if err != nil {
+		return nil, err
+	}
+	result := &ImportResult{Format: "qif", Encoding: encoding}
+
+	var (
+		records  []qifRecord
+		current  *qifRecord
+		section  string // the current !Type, lowercased
+		header   string // and as written, for warnings
+		account  string
+		pending  map[byte]string // fields of an !Account block
+		skipped  = make(map[string]bool)
+		accounts = make(map[string]bool)
+		sawType  bool
+	)
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "!") {
+			header = strings.TrimSpace(line)
+			switch lower := strings.ToLower(header); {
+			case lower == "!account":
+				pending, section = make(map[byte]string), "account"
+			case strings.HasPrefix(lower, "!type:"):
+				sawType = true
+				section = strings.TrimSpace(strings.TrimPrefix(lower, "!type:"))
+			case strings.HasPrefix(lower, "!option:"), strings.HasPrefix(lower, "!clear:"):
+				// Export flags; nothing to import.
+			default:
+				section = lower
+			}
+			current = nil
+			continue
+		}
+
+		code, value := line[0], strings.TrimSpace(line[1:])
+		if section == "account" {
+			if code == '^' {
+				account = pending['N']
+				section = ""
+			} else {
+				pending[code] = value
+			}
+			continue
+		}
+		if !qifTransactionSection(section) {
+			if section != "" {
+				skipped[header] = true
+			}
+			continue
+		}
+		if code == '^' {
+			if current != nil {
+				records = append(records, *current)
+				current = nil
+			}
+			continue
+		}
+		if current == nil {
+			current = &qifRecord{line: n, fields: make(map[byte]string), account: account}
+		}
+		// Split lines (S, E, $) repeat; the record total in T is what counts.
+		if _, seen := current.fields[code]; !seen {
+			current.fields[code] = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read QIF: %w", err)
+	}
+	if current != nil {
+		// A last record without its "^".
+		records = append(records, *current)
+	}
+	if !sawType {
+		return nil, fmt.Errorf("no !Type: header; is this a QIF file?")
+	}
+
+	var skippedNames []string
+	for name := range skipped {
+		skippedNames = append(skippedNames, name)
+	}
+	sort.Strings(skippedNames)
+	for _, name := range skippedNames {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("skipped the %s section; only bank, cash and card transactions are imported", name))
+	}
+
+	var dates []string
+	for _, r := range records {
+		if d := normalizeQIFDate(r.fields['D']); d != "" {
+			dates = append(dates, d)
+		}
+	}
+	layout, warning := detectDateLayout(dates)
+	if warning != "" {
+		result.Warnings = append(result.Warnings, warning)
+	}
+	result.DateFormat = layout
+
+	for _, r := range records {
+		if convertQIFRecord(result, r, layout) && r.account != "" {
+			accounts[r.account] = true
+		}
+	}
+	for acct := range accounts {
+		result.Accounts = append(result.Accounts, acct)
+	}
+	sort.Strings(result.Accounts)
+	return result, nil
+}
+
+// qifTransactionSection reports whether a !Type section holds transactions
+// NeuraPay can use.
+func qifTransactionSection(section string) bool {
+	switch section {
+	case "bank", "cash", "ccard", "oth a", "oth l":
+		return true
+	}
+	return false
+}
+
+// normalizeQIFDate rewrites Quicken's 1/25'26 and " 1/ 5/26" into something
+// the CSV date layouts read.
+func normalizeQIFDate(s string) string {
+	s = strings.ReplaceAll(strings.TrimSpace(s), " ", "")
+	if i := strings.IndexByte(s, '\''); i >= 0 {
+		// The apostrophe marks a 2000s year.
+		year := strings.TrimSpace(s[i+1:])
+		if len(year) == 1 {
+			year = "0" + year
+		}
+		s = s[:i] + "/20" + year
+	}
+	return s
+}
+
+// convertQIFRecord adds one record, or a RowError, and reports whether it
+// was imported.
+func convertQIFRecord(result *ImportResult, r qifRecord, layout string) bool {
+	fail := func(format string, args ...interface{}) bool {
+		result.Errors = append(result.Errors, RowError{Line: r.line, Error: fmt.Sprintf(format, args...)})
+		return false
+	}
+	raw := r.fields['D']
+	if raw == "" {
+		return fail("missing date (D)")
+	}
+	if layout == "" {
+		return fail("unrecognised date %q", raw)
+	}
+	ts, err := time.Parse(layout, normalizeQIFDate(raw))
+	if err != nil {
+		return fail("date %q doesn't match %s", raw, layout)
+	}
+	rawAmount := firstNonEmpty(r.fields['T'], r.fields['U'])
+	if rawAmount == "" {
+		return fail("missing amount (T)")
+	}
+	v, err := parseAmount(rawAmount, false)
+	if err != nil {
+		return fail("amount: %v", err)
+	}
+	amount := round2(math.Abs(v))
+	if amount == 0 {
+		return fail("amount is zero")
+	}
+
+	txType := "receive"
+	if v < 0 {
+		txType = "send"
+	}
+	// "Food:Groceries" is a category and subcategory; "[Savings]" is a
+	// transfer to another account.
+	category := r.fields['L']
+	if strings.HasPrefix(category, "[") {
+		category = "transfer"
+	} else if i := strings.IndexByte(category, ':'); i >= 0 {
+		category = category[:i]
+	}
+	counterparty := firstNonEmpty(r.fields['P'], r.fields['M'])
+	tx := map[string]interface{}{
+		"timestamp":    ts.Format(time.RFC3339),
+		"type":         txType,
+		"amount":       amount,
+		"currency":     "USD", // QIF doesn't record one
+		"counterparty": counterparty,
+		"description":  firstNonEmpty(r.fields['M'], counterparty),
+		"category":     strings.ToLower(strings.TrimSpace(category)),
+	}
+	if r.account != "" {
+		tx["account"] = r.account
+	}
+	result.Transactions = append(result.Transactions, tx)
+	return true
+}
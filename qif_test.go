@@ -0,0 +1,7 @@
+package main
+
+import "testing"
+
+func TestQIFFixtures(t *testing.T) {
+	checkImportFixtures(t, "*.qif")
+}
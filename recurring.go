@@ -0,0 +1,175 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// RECURRING PAYMENT DETECTION
+// ============================================================================
+// Finds payments that repeat with the same counterparty, a similar amount and
+// a regular cadence: paychecks on the receive side, bills and subscriptions on
+// the send side.
+
+// RecurringSeries is a run of similar payments with one counterparty.
+type RecurringSeries struct {
+	Counterparty string    `json:"counterparty"`
+	Category     string    `json:"category,omitempty"`
+	AvgAmount    float64   `json:"avg_amount"`
+	Cadence      string    `json:"cadence"`
+	IntervalDays float64   `json:"interval_days"`
+	Count        int       `json:"count"`
+	Last         time.Time `json:"last"`
+}
+
+// recurringAmountTolerance is how far a payment may deviate from the series'
+// typical amount and still count as the same payment.
+const recurringAmountTolerance = 0.2
+
+// detectRecurring groups transactions of txType by counterparty and keeps the
+// groups that repeat with similar amounts on a regular cadence.
+func detectRecurring(transactions []map[string]interface{}, txType string) []RecurringSeries {
+	type payment struct {
+		at       time.Time
+		amount   float64
+		category string
+	}
+	byCounterparty := make(map[string][]payment)
+	for _, tx := range transactions {
+		if txString(tx, "type") != txType || isSavingsTransfer(tx) {
+			continue
+		}
+		counterparty := normalizeCounterparty(txString(tx, "counterparty"))
+		ts, ok := txTime(tx)
+		if counterparty == "" || !ok {
+			continue
+		}
+		byCounterparty[counterparty] = append(byCounterparty[counterparty], payment{
+			at:       ts,
+			amount:   txAmount(tx),
+			category: txString(tx, "category"),
+		})
+	}
+
+	var out []RecurringSeries
+	for counterparty, payments := range byCounterparty {
+		if len(payments) < 2 {
+			continue
+		}
+		sort.Slice(payments, func(i, j int) bool { return payments[i].at.Before(payments[j].at) })
+
+		var amounts, gaps []float64
+		categories := make(map[string]int)
+		for i, p := range payments {
+			amounts = append(amounts, p.amount)
+			categories[p.category]++
+			if i > 0 {
+				gaps = append(gaps, p.at.Sub(payments[i-1].at).Hours()/24)
+			}
+		}
+		typical := calculateMedian(amounts)
+		similar := 0
+		for _, a := range amounts {
+			if typical > 0 && math.Abs(a-typical)/typical <= recurringAmountTolerance {
+				similar++
+			}
+		}
+		if float64(similar)/float64(len(amounts)) < 0.75 {
+			continue
+		}
+
+		interval := calculateMedian(gaps)
+		cadence := cadenceName(interval)
+		if cadence == "" {
+			continue
+		}
+
+		category, best := "", 0
+		for c, n := range categories {
+			if n > best || (n == best && c < category) {
+				category, best = c, n
+			}
+		}
+		out = append(out, RecurringSeries{
+			Counterparty: counterparty,
+			Category:     category,
+			AvgAmount:    math.Round(calculateMean(amounts)*100) / 100,
+			Cadence:      cadence,
+			IntervalDays: math.Round(interval*10) / 10,
+			Count:        len(payments),
+			Last:         payments[len(payments)-1].at,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].AvgAmount != out[j].AvgAmount {
+			return out[i].AvgAmount > out[j].AvgAmount
+		}
+		return out[i].Counterparty < out[j].Counterparty
+	})
+	return out
+}
+
+// normalizeCounterparty makes counterparty names comparable.
+func normalizeCounterparty(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// cadenceName maps a typical gap in days to a cadence, or "" if the gap does
+// not look like a schedule.
+func cadenceName(days float64) string {
+	switch {
+	case days >= 6 && days <= 8:
+		return "weekly"
+	case days >= 13 && days <= 16:
+		return "biweekly"
+	case days >= 26 && days <= 35:
+		return "monthly"
+	default:
+		return ""
+	}
+}
+
+// nextDue returns the first expected payment strictly after t.
+func (s RecurringSeries) nextDue(t time.Time) time.Time {
+	step := time.Duration(s.IntervalDays * 24 * float64(time.Hour))
+	if s.Cadence == "monthly" {
+		next := s.Last
+		for !next.After(t) {
+			next = next.AddDate(0, 1, 0)
+		}
+		return next
+	}
+	if step <= 0 {
+		return time.Time{}
+	}
+	next := s.Last
+	for !next.After(t) {
+		next = next.Add(step)
+	}
+	return next
+}
+
+// dueBetween lists the expected payments in (from, to].
+func (s RecurringSeries) dueBetween(from, to time.Time) []time.Time {
+	var out []time.Time
+	for next := s.nextDue(from); !next.IsZero() && !next.After(to); next = s.nextDue(next) {
+		out = append(out, next)
+	}
+	return out
+}
+
+func calculateMedian(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+)
+
+// ============================================================================
+// TOOL REGISTRY
+// ============================================================================
+// Decides which tools the server registers and what the system prompt says
+// about them:
+//
+//   - tools.disabled: never registered and scrubbed from the prompt
+//   - testing-only tools (get_csv_transactions): same, unless tools.testing
+//   - tools.flags: rolled out to some users, cohorts or a percentage. The
+//     prompt is shared by every conversation, so flagged tools stay
+//     registered and described, but each call is checked against the caller
+//     and start_session tells the model which ones this user can't use.
+
+// testingTools only make sense against local data.
+var testingTools = map[string]bool{
+	"get_csv_transactions": true,
+}
+
+// toolRegistry filters and gates tools according to the tools config.
+type toolRegistry struct {
+	cfg     ToolsConfig
+	tools   []core.Tool       // what the server registers, in order
+	group   map[string]string // tool name -> catalog section
+	skipped []string          // known tools that were left out
+	known   map[string]bool
+}
+
+func newToolRegistry(cfg ToolsConfig) *toolRegistry {
+	return &toolRegistry{cfg: cfg, group: make(map[string]string), known: make(map[string]bool)}
+}
+
+// register adds a group of tools under a catalog title, dropping disabled
+// and testing-only ones and wrapping flagged ones in a per-user gate.
+func (r *toolRegistry) register(title string, group []core.Tool) {
+	disabled := make(map[string]bool, len(r.cfg.Disabled))
+	for _, name := range r.cfg.Disabled {
+		disabled[name] = true
+	}
+	for _, tool := range group {
+		name := tool.Name()
+		r.known[name] = true
+		if disabled[name] || (testingTools[name] && !r.cfg.Testing) {
+			r.skipped = append(r.skipped, name)
+			continue
+		}
+		if _, flagged := r.cfg.Flags[name]; flagged {
+			tool = &gatedTool{Tool: tool, registry: r}
+		}
+		r.tools = append(r.tools, tool)
+		r.group[name] = title
+	}
+}
+
+// checkNames reports config entries that match no registered group's tool;
+// call it once every group is in.
+func (r *toolRegistry) checkNames() error {
+	var unknown []string
+	for _, name := range r.cfg.Disabled {
+		if !r.known[name] {
+			unknown = append(unknown, "tools.disabled: "+name)
+		}
+	}
+	for name := range r.cfg.Flags {
+		if !r.known[name] {
+			unknown = append(unknown, "tools.flags: "+name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown tools in config:\n  - %s", strings.Join(unknown, "\n  - "))
+	}
+	return nil
+}
+
+// availableFor reports whether userID may call the tool. Unflagged tools are
+// available to everyone.
+func (r *toolRegistry) availableFor(userID, name string) bool {
+	flag, ok := r.cfg.Flags[name]
+	if !ok {
+		return true
+	}
+	for _, u := range flag.Users {
+		if u == userID {
+			return true
+		}
+	}
+	for _, cohort := range flag.Cohorts {
+		for _, u := range r.cfg.Cohorts[cohort] {
+			if u == userID {
+				return true
+			}
+		}
+	}
+	return flag.Percent > 0 && rolloutBucket(name, userID) < flag.Percent
+}
+
+// unavailableFor lists the flagged tools userID can't call.
+func (r *toolRegistry) unavailableFor(userID string) []string {
+	var names []string
+	for name := range r.cfg.Flags {
+		if r.known[name] && !r.availableFor(userID, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// rolloutBucket places a user in 0-99 for a tool. Hashing the tool name in
+// too means a 10% rollout of one tool isn't the same 10% of users as the next.
+func rolloutBucket(tool, userID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(tool + ":" + userID))
+	return int(h.Sum32() % 100)
+}
+
+// gatedTool refuses calls from users outside the tool's flag.
+type gatedTool struct {
+	core.Tool
+	registry *toolRegistry
+}
+
+func (g *gatedTool) Execute(ctx context.Context, toolParams *core.ToolParams) (*core.ToolResult, error) {
+	if !g.registry.availableFor(toolParams.UserID, g.Name()) {
+		return &core.ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("%s is not available for this user yet", g.Name()),
+		}, nil
+	}
+	return g.Tool.Execute(ctx, toolParams)
+}
+
+// ----------------------------------------------------------------------------
+// System prompt
+// ----------------------------------------------------------------------------
+
+// promptHeading matches section headings like "SAVINGS AUTOMATIONS:" or
+// "MONEY PERSONALITY INSIGHTS (analyze_money_personality):".
+var promptHeading = regexp.MustCompile(`^[A-Z][A-Z0-9 /&'-]*( \([a-z_, ]+\))?:$`)
+
+// systemPrompt rewrites the prompt's tool list to match what was registered:
+// any line naming a left-out tool is dropped, a heading that names one drops
+// its whole section, and a heading left with nothing under it goes too.
+// Works on custom prompt files as well as the built-in one.
+func (r *toolRegistry) systemPrompt(prompt string) string {
+	if len(r.skipped) > 0 {
+		names := make([]string, len(r.skipped))
+		for i, name := range r.skipped {
+			names[i] = regexp.QuoteMeta(name)
+		}
+		mentions := regexp.MustCompile(`\b(` + strings.Join(names, "|") + `)\b`)
+
+		var kept []string
+		for _, section := range strings.Split(prompt, "\n\n") {
+			lines := strings.Split(section, "\n")
+			heading := promptHeading.MatchString(strings.TrimSpace(lines[0]))
+			if heading && mentions.MatchString(lines[0]) {
+				continue
+			}
+			var out []string
+			for _, line := range lines {
+				if !mentions.MatchString(line) {
+					out = append(out, line)
+				}
+			}
+			if len(out) == 0 || (heading && len(out) == 1 && len(lines) > 1) {
+				continue
+			}
+			kept = append(kept, strings.Join(out, "\n"))
+		}
+		prompt = strings.Join(kept, "\n\n")
+	}
+
+	var flagged []string
+	for name := range r.cfg.Flags {
+		if r.known[name] && !r.isSkipped(name) {
+			flagged = append(flagged, name)
+		}
+	}
+	if len(flagged) > 0 {
+		sort.Strings(flagged)
+		prompt += fmt.Sprintf("\n\nGRADUAL ROLLOUTS:\n- Only some users have %s. start_session lists the ones this user can't use (unavailable_tools) - never offer or call those.",
+			strings.Join(flagged, ", "))
+	}
+	return prompt
+}
+
+func (r *toolRegistry) isSkipped(name string) bool {
+	for _, s := range r.skipped {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
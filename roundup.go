@@ -0,0 +1,399 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+	"github.com/becomeliminal/nim-go-sdk/tools"
+)
+
+// ============================================================================
+// CUSTOM TOOL: ROUND-UP SAVINGS
+// ============================================================================
+// Implements the Impulse Optimizer strategy "auto-save the 'change' from each
+// transaction". Every new send is rounded up to the configured increment, the
+// change (times an optional multiplier) accumulates, and once it crosses the
+// threshold it is batched into a single deposit_savings.
+//
+// Deposits only happen automatically when the user opted in with
+// auto_deposit; otherwise the batch is returned as a suggestion for the model
+// to confirm with the user.
+
+// RoundupSettings is the user's round-up configuration.
+type RoundupSettings struct {
+	Enabled     bool    `json:"enabled"`
+	Increment   float64 `json:"increment"`
+	Multiplier  float64 `json:"multiplier"`
+	Threshold   float64 `json:"threshold"`
+	Currency    string  `json:"currency"`
+	AutoDeposit bool    `json:"auto_deposit"`
+}
+
+// RoundupDeposit is one batched transfer into savings.
+type RoundupDeposit struct {
+	Amount    float64   `json:"amount"`
+	Currency  string    `json:"currency"`
+	Count     int       `json:"transactions"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RoundupAccount is the per-user round-up state.
+type RoundupAccount struct {
+	Settings     RoundupSettings  `json:"settings"`
+	EnabledAt    time.Time        `json:"enabled_at"`
+	Pending      float64          `json:"pending"`
+	PendingCount int              `json:"pending_count"`
+	SavedToDate  float64          `json:"saved_to_date"`
+	Deposits     []RoundupDeposit `json:"deposits"`
+	SeenIDs      []string         `json:"seen_ids"`
+}
+
+// maxSeenRoundupIDs bounds how many processed transaction IDs we remember.
+const maxSeenRoundupIDs = 500
+
+func defaultRoundupSettings() RoundupSettings {
+	return RoundupSettings{
+		Increment:  1,
+		Multiplier: 1,
+		Threshold:  10,
+		Currency:   "USD",
+	}
+}
+
+// roundUpChange returns how much is needed to round amount up to the next
+// multiple of increment. Works in cents to avoid float drift.
+func roundUpChange(amount, increment float64) float64 {
+	cents := int64(math.Round(amount * 100))
+	incCents := int64(math.Round(increment * 100))
+	if cents <= 0 || incCents <= 0 {
+		return 0
+	}
+	rem := cents % incCents
+	if rem == 0 {
+		return 0
+	}
+	return float64(incCents-rem) / 100
+}
+
+// roundupService owns round-up state for all users.
+type roundupService struct {
+	liminalExecutor core.ToolExecutor
+	store           *jsonStore
+
+	mu         sync.Mutex
+	accounts   map[string]*RoundupAccount
+	depositing map[string]bool // users with an automatic deposit in flight
+}
+
+func newRoundupService(liminalExecutor core.ToolExecutor) *roundupService {
+	s := &roundupService{
+		liminalExecutor: liminalExecutor,
+		store:           newJSONStore("roundups.json"),
+		accounts:        make(map[string]*RoundupAccount),
+		depositing:      make(map[string]bool),
+	}
+	if err := s.store.load(&s.accounts); err != nil {
+		log.Printf("⚠️  Round-up state not loaded: %v", err)
+	}
+	return s
+}
+
+func (s *roundupService) account(userID string) *RoundupAccount {
+	acct, ok := s.accounts[userID]
+	if !ok {
+		acct = &RoundupAccount{Settings: defaultRoundupSettings()}
+		s.accounts[userID] = acct
+	}
+	return acct
+}
+
+func (s *roundupService) persist() {
+	if err := s.store.save(s.accounts); err != nil {
+		log.Printf("⚠️  Failed to save round-up state: %v", err)
+	}
+}
+
+// process applies round-ups for any sends the user made since the last run and
+// batches a deposit once the threshold is reached. It returns the batch that
+// is ready but awaiting confirmation, if any.
+func (s *roundupService) process(ctx context.Context, userID, requestID string) (*RoundupDeposit, error) {
+	s.mu.Lock()
+	acct := s.account(userID)
+	enabled := acct.Settings.Enabled
+	s.mu.Unlock()
+	if !enabled {
+		return nil, nil
+	}
+
+	transactions, err := fetchTransactions(ctx, s.liminalExecutor, userID, requestID, 100)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := s.accumulate(userID, transactions)
+	if batch == nil {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	acct = s.account(userID)
+	if !acct.Settings.AutoDeposit {
+		s.mu.Unlock()
+		return batch, nil
+	}
+	if s.depositing[userID] {
+		s.mu.Unlock()
+		return nil, nil // the deposit already in flight covers this change
+	}
+	s.depositing[userID] = true
+	s.mu.Unlock()
+
+	// The deposit is a network call; other users' round-ups don't wait on it.
+	err = depositSavings(ctx, s.liminalExecutor, userID, requestID, batch.Amount, batch.Currency)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.depositing, userID)
+	if err != nil {
+		return nil, fmt.Errorf("round-up deposit failed: %w", err)
+	}
+	acct = s.account(userID)
+	acct.Pending = math.Max(acct.Pending-batch.Amount, 0)
+	acct.PendingCount -= batch.Count
+	if acct.PendingCount < 0 || acct.Pending == 0 {
+		acct.PendingCount = 0
+	}
+	acct.SavedToDate += batch.Amount
+	acct.Deposits = append(acct.Deposits, *batch)
+	s.persist()
+	log.Printf("💰 Round-up deposit of %.2f %s for user %s", batch.Amount, batch.Currency, userID)
+	return nil, nil
+}
+
+// accumulate adds the change from sends it hasn't seen yet and returns the
+// batch to deposit once the threshold is reached.
+func (s *roundupService) accumulate(userID string, transactions []map[string]interface{}) *RoundupDeposit {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.persist()
+
+	acct := s.account(userID)
+	seen := make(map[string]bool, len(acct.SeenIDs))
+	for _, id := range acct.SeenIDs {
+		seen[id] = true
+	}
+
+	// Oldest first so SeenIDs stays in chronological order.
+	sort.SliceStable(transactions, func(i, j int) bool {
+		ti, _ := txTime(transactions[i])
+		tj, _ := txTime(transactions[j])
+		return ti.Before(tj)
+	})
+
+	for _, tx := range transactions {
+		if txString(tx, "type") != "send" || txString(tx, "category") == "savings" {
+			continue
+		}
+		if ts, ok := txTime(tx); !ok || ts.Before(acct.EnabledAt) {
+			continue
+		}
+		id := txID(tx)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		acct.SeenIDs = append(acct.SeenIDs, id)
+
+		change := roundUpChange(txAmount(tx), acct.Settings.Increment) * acct.Settings.Multiplier
+		if change > 0 {
+			acct.Pending += change
+			acct.PendingCount++
+		}
+	}
+	if len(acct.SeenIDs) > maxSeenRoundupIDs {
+		acct.SeenIDs = acct.SeenIDs[len(acct.SeenIDs)-maxSeenRoundupIDs:]
+	}
+
+	if acct.Pending < acct.Settings.Threshold {
+		return nil
+	}
+	return &RoundupDeposit{
+		Amount:    math.Round(acct.Pending*100) / 100,
+		Currency:  acct.Settings.Currency,
+		Count:     acct.PendingCount,
+		CreatedAt: time.Now(),
+	}
+}
+
+// recordManualDeposit marks (part of) a suggested batch as saved. The amount
+// is capped at what is pending, and the transaction count only resets once
+// nothing is left. It returns the amount recorded.
+func (s *roundupService) recordManualDeposit(userID string, amount float64) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acct := s.account(userID)
+	amount = math.Round(math.Min(amount, acct.Pending)*100) / 100
+	if amount <= 0 {
+		return 0
+	}
+	acct.Pending = math.Max(acct.Pending-amount, 0)
+	count := 0
+	if acct.Pending < 0.005 {
+		acct.Pending = 0
+		count = acct.PendingCount
+		acct.PendingCount = 0
+	}
+	acct.SavedToDate += amount
+	acct.Deposits = append(acct.Deposits, RoundupDeposit{
+		Amount:    amount,
+		Currency:  acct.Settings.Currency,
+		Count:     count,
+		CreatedAt: time.Now(),
+	})
+	s.persist()
+	return amount
+}
+
+func createRoundupSettingsTool(roundups *roundupService) core.Tool {
+	return tools.New("configure_roundups").
+		Description("Turn round-up savings on or off and adjust how it works. Each new send is rounded up to the increment, the change (times the multiplier) accumulates, and is moved to savings once it reaches the threshold.").
+		Schema(tools.ObjectSchema(map[string]interface{}{
+			"enabled":      tools.BooleanProperty("Whether round-ups are active"),
+			"increment":    tools.NumberProperty("Round each send up to a multiple of this amount (default: 1.00)"),
+			"multiplier":   tools.NumberProperty("Multiply the change before saving it, e.g. 2 for double round-ups (default: 1)"),
+			"threshold":    tools.NumberProperty("Deposit to savings once accumulated change reaches this amount (default: 10.00)"),
+			"currency":     tools.StringProperty("Currency for the savings deposit (default: USD)"),
+			"auto_deposit": tools.BooleanProperty("Deposit automatically without asking each time (only set when the user explicitly agrees)"),
+		})).
+		Handler(func(ctx context.Context, toolParams *core.ToolParams) (*core.ToolResult, error) {
+			var params struct {
+				Enabled     *bool    `json:"enabled"`
+				Increment   *float64 `json:"increment"`
+				Multiplier  *float64 `json:"multiplier"`
+				Threshold   *float64 `json:"threshold"`
+				Currency    *string  `json:"currency"`
+				AutoDeposit *bool    `json:"auto_deposit"`
+			}
+			if err := json.Unmarshal(toolParams.Input, &params); err != nil {
+				return &core.ToolResult{
+					Success: false,
+					Error:   fmt.Sprintf("invalid input: %v", err),
+				}, nil
+			}
+
+			roundups.mu.Lock()
+			defer roundups.mu.Unlock()
+
+			acct := roundups.account(toolParams.UserID)
+			settings := acct.Settings
+			if params.Enabled != nil {
+				if *params.Enabled && !settings.Enabled {
+					// Only round up sends made from now on.
+					acct.EnabledAt = time.Now()
+				}
+				settings.Enabled = *params.Enabled
+			}
+			if params.Increment != nil {
+				settings.Increment = *params.Increment
+			}
+			if params.Multiplier != nil {
+				settings.Multiplier = *params.Multiplier
+			}
+			if params.Threshold != nil {
+				settings.Threshold = *params.Threshold
+			}
+			if params.Currency != nil && *params.Currency != "" {
+				settings.Currency = *params.Currency
+			}
+			if params.AutoDeposit != nil {
+				settings.AutoDeposit = *params.AutoDeposit
+			}
+
+			if settings.Increment <= 0 || settings.Multiplier <= 0 || settings.Threshold <= 0 {
+				return &core.ToolResult{
+					Success: false,
+					Error:   "increment, multiplier and threshold must be greater than zero",
+				}, nil
+			}
+
+			acct.Settings = settings
+			roundups.persist()
+
+			return &core.ToolResult{
+				Success: true,
+				Data: map[string]interface{}{
+					"settings":   settings,
+					"enabled_at": acct.EnabledAt.Format(time.RFC3339),
+				},
+			}, nil
+		}).
+		Build()
+}
+
+func createRoundupSummaryTool(roundups *roundupService) core.Tool {
+	return tools.New("get_roundup_summary").
+		Description("Report how much round-up savings has put away to date, the change waiting to be deposited, and past round-up deposits. Also processes any new transactions.").
+		Schema(tools.ObjectSchema(map[string]interface{}{
+			"confirmed_deposit": tools.NumberProperty("Amount of a suggested round-up batch the user just deposited via deposit_savings"),
+		})).
+		Handler(func(ctx context.Context, toolParams *core.ToolParams) (*core.ToolResult, error) {
+			var params struct {
+				ConfirmedDeposit float64 `json:"confirmed_deposit"`
+			}
+			if err := json.Unmarshal(toolParams.Input, &params); err != nil {
+				return &core.ToolResult{
+					Success: false,
+					Error:   fmt.Sprintf("invalid input: %v", err),
+				}, nil
+			}
+
+			recorded := 0.0
+			if params.ConfirmedDeposit > 0 {
+				recorded = roundups.recordManualDeposit(toolParams.UserID, params.ConfirmedDeposit)
+			}
+
+			ready, err := roundups.process(ctx, toolParams.UserID, toolParams.RequestID)
+			if err != nil {
+				return &core.ToolResult{
+					Success: false,
+					Error:   err.Error(),
+				}, nil
+			}
+
+			roundups.mu.Lock()
+			acct := *roundups.account(toolParams.UserID)
+			roundups.mu.Unlock()
+
+			result := map[string]interface{}{
+				"enabled":       acct.Settings.Enabled,
+				"settings":      acct.Settings,
+				"saved_to_date": fmt.Sprintf("%.2f", acct.SavedToDate),
+				"pending":       fmt.Sprintf("%.2f", acct.Pending),
+				"pending_count": acct.PendingCount,
+				"deposits":      acct.Deposits,
+			}
+			if params.ConfirmedDeposit > 0 && recorded < round2(params.ConfirmedDeposit) {
+				result["note"] = fmt.Sprintf("Recorded %.2f as saved, all the round-up change that was pending (%.2f was reported)", recorded, params.ConfirmedDeposit)
+			}
+			if ready != nil {
+				result["deposit_ready"] = map[string]interface{}{
+					"amount":   fmt.Sprintf("%.2f", ready.Amount),
+					"currency": ready.Currency,
+					"note":     "Threshold reached. Offer to deposit this with deposit_savings, then call get_roundup_summary with confirmed_deposit.",
+				}
+			}
+
+			return &core.ToolResult{
+				Success: true,
+				Data:    result,
+			}, nil
+		}).
+		Build()
+}
@@ -0,0 +1,538 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+	"github.com/becomeliminal/nim-go-sdk/tools"
+)
+
+// ============================================================================
+// TRANSACTION SEARCH
+// ============================================================================
+// "How much did I send @alice in March?" used to mean the model pulling raw
+// history and adding it up in its head. search_transactions filters and
+// aggregates on the server and returns exact figures. Filters come as
+// structured fields or a short query, which is easier for the model to
+// write for compound questions:
+//
+//   type:send counterparty:@alice month:2026-03
+//   category:dining amount>=20 from:2026-01-01 coffee
+//   @bob "rent share"
+//
+// key:value terms set the filter of that name, amount takes >, >=, <, <= and
+// =, a bare @tag is a counterparty, and any other words must all appear in
+// the description. Structured fields win over the query. The same filter
+// selects what export_transactions writes.
+
+// TransactionFilter selects transactions. Empty fields match everything;
+// dates are inclusive days.
+type TransactionFilter struct {
+	From         string  `json:"from,omitempty"` // YYYY-MM-DD
+	To           string  `json:"to,omitempty"`   // YYYY-MM-DD
+	Type         string  `json:"type,omitempty"` // send or receive
+	Category     string  `json:"category,omitempty"`
+	Counterparty string  `json:"counterparty,omitempty"` // @tag matches exactly, a name matches any part
+	MinAmount    float64 `json:"min_amount,omitempty"`
+	MaxAmount    float64 `json:"max_amount,omitempty"`
+	Text         string  `json:"text,omitempty"` // words that must all appear in the description
+}
+
+// bounds parses From and To into [from, until).
+func (f TransactionFilter) bounds() (from, until time.Time, err error) {
+	if f.From != "" {
+		if from, err = time.Parse("2006-01-02", f.From); err != nil {
+			return from, until, fmt.Errorf("from %q should be YYYY-MM-DD", f.From)
+		}
+	}
+	if f.To != "" {
+		if until, err = time.Parse("2006-01-02", f.To); err != nil {
+			return from, until, fmt.Errorf("to %q should be YYYY-MM-DD", f.To)
+		}
+		until = until.AddDate(0, 0, 1)
+	}
+	if !from.IsZero() && !until.IsZero() && !from.Before(until) {
+		return from, until, fmt.Errorf("from %s is after to %s", f.From, f.To)
+	}
+	return from, until, nil
+}
+
+// setMonth sets From and To to cover a YYYY-MM month; "" changes nothing.
+func (f *TransactionFilter) setMonth(month string) error {
+	if month == "" {
+		return nil
+	}
+	start, err := time.Parse("2006-01", month)
+	if err != nil {
+		return fmt.Errorf("month %q should be YYYY-MM", month)
+	}
+	f.From = start.Format("2006-01-02")
+	f.To = start.AddDate(0, 1, -1).Format("2006-01-02")
+	return nil
+}
+
+// apply returns the matching transactions, oldest first.
+func (f TransactionFilter) apply(transactions []map[string]interface{}) ([]map[string]interface{}, error) {
+	from, until, err := f.bounds()
+	if err != nil {
+		return nil, err
+	}
+	switch f.Type {
+	case "", "send", "receive":
+	default:
+		return nil, fmt.Errorf("type should be send or receive, got %q", f.Type)
+	}
+	if f.MaxAmount > 0 && f.MinAmount > f.MaxAmount {
+		return nil, fmt.Errorf("min_amount %.2f is above max_amount %.2f", f.MinAmount, f.MaxAmount)
+	}
+	counterparty := strings.ToLower(f.Counterparty)
+	exact := strings.HasPrefix(counterparty, "@")
+	words := strings.Fields(strings.ToLower(f.Text))
+
+	var out []map[string]interface{}
+	for _, tx := range transactions {
+		if !from.IsZero() || !until.IsZero() {
+			ts, ok := txTime(tx)
+			if !ok || (!from.IsZero() && ts.Before(from)) || (!until.IsZero() && !ts.Before(until)) {
+				continue
+			}
+		}
+		if f.Type != "" && txString(tx, "type") != f.Type {
+			continue
+		}
+		if f.Category != "" && !strings.EqualFold(txString(tx, "category"), f.Category) {
+			continue
+		}
+		if counterparty != "" {
+			// @bob must not also match @bobby; merchant names match any part.
+			name := strings.ToLower(txString(tx, "counterparty"))
+			if (exact && name != counterparty) || (!exact && !strings.Contains(name, counterparty)) {
+				continue
+			}
+		}
+		amount := txAmount(tx)
+		if amount < f.MinAmount || (f.MaxAmount > 0 && amount > f.MaxAmount) {
+			continue
+		}
+		if len(words) > 0 {
+			description := strings.ToLower(txString(tx, "description"))
+			missing := false
+			for _, w := range words {
+				if !strings.Contains(description, w) {
+					missing = true
+					break
+				}
+			}
+			if missing {
+				continue
+			}
+		}
+		out = append(out, tx)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, _ := txTime(out[i])
+		b, _ := txTime(out[j])
+		return a.Before(b)
+	})
+	return out, nil
+}
+
+// merge fills the fields of f that are empty from other.
+func (f *TransactionFilter) merge(other TransactionFilter) {
+	f.From = firstNonEmpty(f.From, other.From)
+	f.To = firstNonEmpty(f.To, other.To)
+	f.Type = firstNonEmpty(f.Type, other.Type)
+	f.Category = firstNonEmpty(f.Category, other.Category)
+	f.Counterparty = firstNonEmpty(f.Counterparty, other.Counterparty)
+	f.Text = firstNonEmpty(f.Text, other.Text)
+	if f.MinAmount == 0 {
+		f.MinAmount = other.MinAmount
+	}
+	if f.MaxAmount == 0 {
+		f.MaxAmount = other.MaxAmount
+	}
+}
+
+// parseFilterQuery reads the query language described above.
+func parseFilterQuery(query string) (TransactionFilter, error) {
+	var f TransactionFilter
+	terms, err := splitQuery(query)
+	if err != nil {
+		return f, err
+	}
+	var text []string
+	for _, term := range terms {
+		if rest, ok := strings.CutPrefix(term, "amount"); ok && rest != "" && strings.ContainsRune("<>=", rune(rest[0])) {
+			if err := parseAmountTerm(&f, rest); err != nil {
+				return f, err
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(term, ":")
+		if !ok || value == "" {
+			if strings.HasPrefix(term, "@") && len(term) > 1 {
+				f.Counterparty = term
+			} else {
+				text = append(text, term)
+			}
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "from":
+			f.From = value
+		case "to":
+			f.To = value
+		case "month":
+			if err := f.setMonth(value); err != nil {
+				return f, err
+			}
+		case "type":
+			f.Type = strings.ToLower(value)
+		case "category":
+			f.Category = value
+		case "counterparty":
+			f.Counterparty = value
+		default:
+			// "re:" or a time like 10:30 is text, not a filter.
+			text = append(text, term)
+		}
+	}
+	f.Text = strings.Join(text, " ")
+	return f, nil
+}
+
+// splitQuery splits on spaces, keeping "quoted phrases" (also as values:
+// counterparty:"Acme Corp") together.
+func splitQuery(query string) ([]string, error) {
+	var (
+		terms   []string
+		current strings.Builder
+		quoted  bool
+	)
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			if current.Len() > 0 {
+				terms = append(terms, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unclosed quote in query %q", query)
+	}
+	if current.Len() > 0 {
+		terms = append(terms, current.String())
+	}
+	return terms, nil
+}
+
+// parseAmountTerm applies ">=20", "<100" or "=12.50". Amounts are in
+// cents, so > 20 is >= 20.01.
+func parseAmountTerm(f *TransactionFilter, term string) error {
+	value := strings.TrimLeft(term, "<>=")
+	op := term[:len(term)-len(value)]
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || v < 0 {
+		return fmt.Errorf("amount%s: %q is not an amount", op, value)
+	}
+	switch op {
+	case ">":
+		f.MinAmount = v + 0.01
+	case ">=":
+		f.MinAmount = v
+	case "<":
+		f.MaxAmount = v - 0.01
+	case "<=":
+		f.MaxAmount = v
+	case "=":
+		f.MinAmount, f.MaxAmount = v, v
+	default:
+		return fmt.Errorf("amount takes >, >=, <, <= or =, got %q", op)
+	}
+	return nil
+}
+
+// Aggregate is the figures for one group of matching transactions, in one
+// currency.
+type Aggregate struct {
+	Group    string   `json:"group,omitempty"`
+	Currency string   `json:"currency"`
+	Count    int      `json:"count"`
+	Sum      *float64 `json:"sum,omitempty"` // amounts in both directions
+	Avg      *float64 `json:"avg,omitempty"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+	Net      *float64 `json:"net,omitempty"` // received minus sent
+}
+
+// searchAggregates are the figures search_transactions can compute; count
+// is always included.
+var searchAggregates = []string{"count", "sum", "avg", "min", "max", "net"}
+
+// searchGroupings are what search_transactions can group by.
+var searchGroupings = []string{"counterparty", "category", "type", "currency", "day", "week", "month"}
+
+// maxSearchGroups bounds the groups returned; the rest are summed up in
+// groups_omitted.
+const maxSearchGroups = 50
+
+// groupKey is the group a transaction falls in.
+func groupKey(tx map[string]interface{}, by string) string {
+	switch by {
+	case "counterparty":
+		return firstNonEmpty(txString(tx, "counterparty"), "(none)")
+	case "category":
+		return firstNonEmpty(txString(tx, "category"), "uncategorized")
+	case "type":
+		return txString(tx, "type")
+	case "currency":
+		return strings.ToUpper(firstNonEmpty(txString(tx, "currency"), "USD"))
+	}
+	ts, ok := txTime(tx)
+	if !ok {
+		return "(no date)"
+	}
+	switch by {
+	case "day":
+		return ts.Format("2006-01-02")
+	case "week":
+		year, week := ts.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case "month":
+		return ts.Format("2006-01")
+	}
+	return ""
+}
+
+// aggregateTransactions computes the requested figures per group and
+// currency; groupBy "" gives one group per currency.
+func aggregateTransactions(transactions []map[string]interface{}, groupBy string, funcs map[string]bool) []Aggregate {
+	type acc struct {
+		group, currency    string
+		count              int
+		sum, min, max, net float64
+	}
+	byKey := make(map[string]*acc)
+	var order []*acc
+	for _, tx := range transactions {
+		group := ""
+		if groupBy != "" {
+			group = groupKey(tx, groupBy)
+		}
+		currency := strings.ToUpper(firstNonEmpty(txString(tx, "currency"), "USD"))
+		key := group + "\x00" + currency
+		a := byKey[key]
+		if a == nil {
+			a = &acc{group: group, currency: currency, min: math.Inf(1), max: math.Inf(-1)}
+			byKey[key] = a
+			order = append(order, a)
+		}
+		amount := txAmount(tx)
+		a.count++
+		a.sum += amount
+		a.min = math.Min(a.min, amount)
+		a.max = math.Max(a.max, amount)
+		if txString(tx, "type") == "send" {
+			a.net -= amount
+		} else {
+			a.net += amount
+		}
+	}
+
+	out := make([]Aggregate, 0, len(order))
+	for _, a := range order {
+		g := Aggregate{Group: a.group, Currency: a.currency, Count: a.count}
+		figure := func(name string, v float64) *float64 {
+			if !funcs[name] {
+				return nil
+			}
+			v = round2(v)
+			return &v
+		}
+		g.Sum = figure("sum", a.sum)
+		g.Avg = figure("avg", a.sum/float64(a.count))
+		g.Min = figure("min", a.min)
+		g.Max = figure("max", a.max)
+		g.Net = figure("net", a.net)
+		out = append(out, g)
+	}
+
+	// Dates read best in order; everything else biggest first.
+	chronological := groupBy == "day" || groupBy == "week" || groupBy == "month"
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if chronological && a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if sa, sb := a.Count, b.Count; a.Sum != nil && b.Sum != nil && *a.Sum != *b.Sum {
+			return *a.Sum > *b.Sum
+		} else if sa != sb {
+			return sa > sb
+		}
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		return a.Currency < b.Currency
+	})
+	return out
+}
+
+// ============================================================================
+// CUSTOM TOOL: SEARCH TRANSACTIONS
+// ============================================================================
+
+func createSearchTransactionsTool(liminalExecutor core.ToolExecutor, statements *statementStore) core.Tool {
+	return tools.New("search_transactions").
+		Description("Find transactions and compute exact totals on the server - use this instead of adding up get_transactions yourself. Filter by date range, month, counterparty, category, amount range, type and words in the description, either as fields or as a query like 'type:send counterparty:@alice month:2026-03' or 'category:dining amount>=20 coffee'. Aggregates (count, sum, avg, min, max, net) can be grouped by counterparty, category, type, currency, day, week or month. Amounts in different currencies are never added together.").
+		Schema(tools.ObjectSchema(map[string]interface{}{
+			"query":              tools.StringProperty("Filter query: key:value terms (from, to, month, type, category, counterparty), amount>N / amount<=N, @tag for a counterparty, other words must appear in the description"),
+			"from":               tools.StringProperty("First day, YYYY-MM-DD"),
+			"to":                 tools.StringProperty("Last day, YYYY-MM-DD"),
+			"month":              tools.StringProperty("Calendar month, YYYY-MM (instead of from/to)"),
+			"type":               tools.StringProperty("send or receive"),
+			"category":           tools.StringProperty("Category, e.g. dining"),
+			"counterparty":       tools.StringProperty("Counterparty name (matches part of the name) or exact @tag"),
+			"min_amount":         tools.NumberProperty("Smallest amount to include"),
+			"max_amount":         tools.NumberProperty("Largest amount to include"),
+			"text":               tools.StringProperty("Words that must all appear in the description"),
+			"aggregate":          tools.StringProperty("Comma-separated figures: count, sum, avg, min, max, net (default: count,sum, plus net when both types match)"),
+			"group_by":           tools.StringProperty("counterparty, category, type, currency, day, week or month (default: no grouping)"),
+			"limit":              tools.IntegerProperty("Matching transactions to return, newest first (default: 20, max: 100; 0 for figures only)"),
+			"include_statements": tools.BooleanProperty("Include bank statements the user imported (default: true)"),
+			"refresh":            tools.BooleanProperty("Fetch the latest transactions from Liminal instead of the local cache (default: false)"),
+			"use_csv":            tools.BooleanProperty("Use local CSV file instead of API (for testing, default: false)"),
+		})).
+		Handler(func(ctx context.Context, toolParams *core.ToolParams) (*core.ToolResult, error) {
+			var params struct {
+				TransactionFilter
+				Query             string `json:"query"`
+				Month             string `json:"month"`
+				Aggregate         string `json:"aggregate"`
+				GroupBy           string `json:"group_by"`
+				Limit             *int   `json:"limit"`
+				IncludeStatements *bool  `json:"include_statements"`
+				Refresh           bool   `json:"refresh"`
+				UseCSV            bool   `json:"use_csv"`
+			}
+			if err := json.Unmarshal(toolParams.Input, &params); err != nil {
+				return &core.ToolResult{
+					Success: false,
+					Error:   fmt.Sprintf("invalid input: %v", err),
+				}, nil
+			}
+
+			filter := params.TransactionFilter
+			if err := filter.setMonth(params.Month); err != nil {
+				return &core.ToolResult{Success: false, Error: err.Error()}, nil
+			}
+			if params.Query != "" {
+				parsed, err := parseFilterQuery(params.Query)
+				if err != nil {
+					return &core.ToolResult{Success: false, Error: fmt.Sprintf("invalid query: %v", err)}, nil
+				}
+				filter.merge(parsed)
+			}
+			filter.Type = strings.ToLower(filter.Type)
+
+			groupBy := strings.ToLower(strings.TrimSpace(params.GroupBy))
+			if groupBy == "none" {
+				groupBy = ""
+			}
+			if groupBy != "" && !slices.Contains(searchGroupings, groupBy) {
+				return &core.ToolResult{Success: false, Error: fmt.Sprintf("group_by should be one of %s, got %q", strings.Join(searchGroupings, ", "), params.GroupBy)}, nil
+			}
+			funcs := map[string]bool{"count": true}
+			for _, name := range strings.Split(params.Aggregate, ",") {
+				name = strings.ToLower(strings.TrimSpace(name))
+				if name == "" {
+					continue
+				}
+				if !slices.Contains(searchAggregates, name) {
+					return &core.ToolResult{Success: false, Error: fmt.Sprintf("aggregate should list %s, got %q", strings.Join(searchAggregates, ", "), name)}, nil
+				}
+				funcs[name] = true
+			}
+			if len(funcs) == 1 {
+				funcs["sum"] = true
+				funcs["net"] = filter.Type == ""
+			}
+			limit := 20
+			if params.Limit != nil {
+				limit = *params.Limit
+			}
+			if limit < 0 || limit > 100 {
+				return &core.ToolResult{Success: false, Error: "limit must be between 0 and 100"}, nil
+			}
+
+			if params.Refresh {
+				ctx = withFreshTransactions(ctx)
+			}
+			transactions, err := loadToolTransactions(ctx, liminalExecutor, toolParams, params.UseCSV)
+			if err != nil {
+				return &core.ToolResult{Success: false, Error: err.Error()}, nil
+			}
+			statementCount := 0
+			if params.IncludeStatements == nil || *params.IncludeStatements {
+				transactions, statementCount = statements.withStatements(toolParams.UserID, transactions, time.Time{})
+			}
+			matched, err := filter.apply(transactions)
+			if err != nil {
+				return &core.ToolResult{Success: false, Error: err.Error()}, nil
+			}
+
+			result := map[string]interface{}{
+				"filter":      filter,
+				"matched":     len(matched),
+				"totals":      aggregateTransactions(matched, "", funcs),
+				"searched":    len(transactions),
+				"data_source": map[string]interface{}{"csv": params.UseCSV, "api": !params.UseCSV, "statements": statementCount},
+			}
+			// The model should know how far back "all" goes.
+			var earliest time.Time
+			for _, tx := range transactions {
+				if ts, ok := txTime(tx); ok && (earliest.IsZero() || ts.Before(earliest)) {
+					earliest = ts
+				}
+			}
+			if !earliest.IsZero() {
+				result["history_from"] = earliest.Format("2006-01-02")
+				// Only the newest rows are fetched, so a range starting
+				// earlier may be missing transactions.
+				if from, _, _ := filter.bounds(); !from.IsZero() && from.Before(earliest) {
+					result["partial"] = true
+					result["warning"] = fmt.Sprintf("Only transactions from %s onwards were searched; figures before then are missing, not zero.", earliest.Format("2006-01-02"))
+				}
+			}
+			if groupBy != "" {
+				groups := aggregateTransactions(matched, groupBy, funcs)
+				if len(groups) > maxSearchGroups {
+					result["groups_omitted"] = len(groups) - maxSearchGroups
+					groups = groups[:maxSearchGroups]
+				}
+				result["group_by"] = groupBy
+				result["groups"] = groups
+			}
+			if limit > 0 {
+				newest := make([]map[string]interface{}, 0, limit)
+				for i := len(matched) - 1; i >= 0 && len(newest) < limit; i-- {
+					newest = append(newest, matched[i])
+				}
+				result["transactions"] = newest
+			}
+			return &core.ToolResult{Success: true, Data: result}, nil
+		}).
+		Build()
+}
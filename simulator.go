@@ -0,0 +1,484 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+)
+
+// ============================================================================
+// OFFLINE LIMINAL SIMULATOR
+// ============================================================================
+// An in-process stand-in for Liminal that implements all nine banking tools,
+// so the whole agent can be demoed and integration-tested with no network.
+// Select it with LIMINAL_MODE=simulator; fixtures are read from
+// LIMINAL_SIMULATOR_FIXTURES (default: fixtures/simulator):
+//
+//   users.json         users with wallet and savings balances per currency
+//   rates.json         savings vault rates per currency
+//   transactions.json  optional transaction history per user ID
+//
+// Balances stay consistent as money moves: sends debit the sender and credit
+// the recipient, deposits and withdrawals move money between wallet and
+// savings, and savings accrue interest at the vault APY. Users that are not
+// in the fixtures (e.g. whoever logs in during a demo) are created from the
+// first fixture user.
+
+// SimUser is a simulated Liminal account.
+type SimUser struct {
+	ID         string             `json:"id"`
+	DisplayTag string             `json:"display_tag"`
+	Name       string             `json:"name"`
+	Email      string             `json:"email"`
+	Wallet     map[string]float64 `json:"wallet"`
+	Savings    map[string]float64 `json:"savings"`
+}
+
+// SimRate is a simulated savings vault.
+type SimRate struct {
+	Currency string  `json:"currency"`
+	Vault    string  `json:"vault"`
+	APY      float64 `json:"apy"` // percent, e.g. 4.5
+}
+
+// simPending is a write waiting for user confirmation.
+type simPending struct {
+	userID string
+	tool   string
+	input  json.RawMessage
+}
+
+// liminalSimulator implements core.ToolExecutor against in-memory state.
+type liminalSimulator struct {
+	now func() time.Time
+
+	mu           sync.Mutex
+	users        map[string]*SimUser
+	template     SimUser
+	rates        map[string]SimRate
+	transactions map[string][]map[string]interface{}
+	earned       map[string]map[string]float64 // user -> currency -> interest
+	lastAccrual  time.Time
+	pending      map[string]simPending
+	nextID       int
+}
+
+// newSimulatorFromDir loads fixtures from dir.
+func newSimulatorFromDir(dir string) (*liminalSimulator, error) {
+	var users []SimUser
+	if err := readFixture(filepath.Join(dir, "users.json"), &users); err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, fmt.Errorf("%s: at least one user is required", filepath.Join(dir, "users.json"))
+	}
+	var rates []SimRate
+	if err := readFixture(filepath.Join(dir, "rates.json"), &rates); err != nil {
+		return nil, err
+	}
+	transactions := make(map[string][]map[string]interface{})
+	if err := readFixture(filepath.Join(dir, "transactions.json"), &transactions); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return newSimulator(users, rates, transactions, time.Now), nil
+}
+
+func readFixture(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read fixture: %w", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse fixture %s: %w", path, err)
+	}
+	return nil
+}
+
+func newSimulator(users []SimUser, rates []SimRate, transactions map[string][]map[string]interface{}, now func() time.Time) *liminalSimulator {
+	s := &liminalSimulator{
+		now:          now,
+		users:        make(map[string]*SimUser),
+		template:     users[0],
+		rates:        make(map[string]SimRate),
+		transactions: make(map[string][]map[string]interface{}),
+		earned:       make(map[string]map[string]float64),
+		lastAccrual:  now(),
+		pending:      make(map[string]simPending),
+	}
+	for i := range users {
+		u := users[i]
+		u.Wallet = copyBalances(u.Wallet)
+		u.Savings = copyBalances(u.Savings)
+		s.users[u.ID] = &u
+	}
+	for _, r := range rates {
+		s.rates[strings.ToUpper(r.Currency)] = r
+	}
+	for userID, list := range transactions {
+		for _, tx := range list {
+			s.nextID++
+			if txString(tx, "id") == "" {
+				tx["id"] = fmt.Sprintf("sim-tx-%d", s.nextID)
+			}
+			s.transactions[userID] = append(s.transactions[userID], tx)
+		}
+	}
+	return s
+}
+
+func copyBalances(in map[string]float64) map[string]float64 {
+	out := make(map[string]float64, len(in))
+	for k, v := range in {
+		out[strings.ToUpper(k)] = v
+	}
+	return out
+}
+
+// user returns the account for userID, provisioning it from the template
+// when it doesn't exist yet. Callers hold s.mu.
+func (s *liminalSimulator) user(userID string) *SimUser {
+	if u, ok := s.users[userID]; ok {
+		return u
+	}
+	u := s.template
+	u.ID = userID
+	u.DisplayTag = "@" + strings.TrimPrefix(userID, "@")
+	u.Wallet = copyBalances(s.template.Wallet)
+	u.Savings = copyBalances(s.template.Savings)
+	s.users[userID] = &u
+	if seed, ok := s.transactions[s.template.ID]; ok {
+		s.transactions[userID] = append([]map[string]interface{}(nil), seed...)
+	}
+	return &u
+}
+
+// balances returns copies of a user's wallet and savings balances.
+func (s *liminalSimulator) balances(userID string) (wallet, savings map[string]float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accrue()
+	u := s.user(userID)
+	return copyBalances(u.Wallet), copyBalances(u.Savings)
+}
+
+// accrue credits savings interest for the time elapsed since the last call.
+// Callers hold s.mu.
+func (s *liminalSimulator) accrue() {
+	now := s.now()
+	years := now.Sub(s.lastAccrual).Hours() / 24 / 365
+	s.lastAccrual = now
+	if years <= 0 {
+		return
+	}
+	for _, u := range s.users {
+		for currency, balance := range u.Savings {
+			rate, ok := s.rates[currency]
+			if !ok || balance <= 0 {
+				continue
+			}
+			interest := balance * rate.APY / 100 * years
+			u.Savings[currency] += interest
+			if s.earned[u.ID] == nil {
+				s.earned[u.ID] = make(map[string]float64)
+			}
+			s.earned[u.ID][currency] += interest
+		}
+	}
+}
+
+// record appends a transaction to a user's history. Callers hold s.mu.
+func (s *liminalSimulator) record(u *SimUser, txType string, amount float64, currency, counterparty, description, category string) map[string]interface{} {
+	s.nextID++
+	tx := map[string]interface{}{
+		"id":            fmt.Sprintf("sim-tx-%d", s.nextID),
+		"type":          txType,
+		"amount":        amount,
+		"currency":      currency,
+		"counterparty":  counterparty,
+		"description":   description,
+		"category":      category,
+		"balance_after": round2(u.Wallet[currency]),
+		"timestamp":     s.now().UTC().Format(time.RFC3339),
+	}
+	s.transactions[u.ID] = append(s.transactions[u.ID], tx)
+	return tx
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// simInput is the union of inputs accepted by the banking tools.
+type simInput struct {
+	Limit      int             `json:"limit"`
+	Query      string          `json:"query"`
+	Recipient  string          `json:"recipient"`
+	To         string          `json:"to"`
+	DisplayTag string          `json:"display_tag"`
+	Amount     json.RawMessage `json:"amount"`
+	Currency   string          `json:"currency"`
+	Note       string          `json:"note"`
+}
+
+func (in simInput) amount() (float64, error) {
+	var raw interface{}
+	if len(in.Amount) == 0 || json.Unmarshal(in.Amount, &raw) != nil {
+		return 0, errors.New("amount is required")
+	}
+	v, ok := numberValue(raw)
+	if !ok || v <= 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0, errors.New("amount must be a positive number")
+	}
+	return round2(v), nil
+}
+
+func (in simInput) currency() string {
+	if in.Currency == "" {
+		return "USD"
+	}
+	return strings.ToUpper(in.Currency)
+}
+
+// simWriteTools move money and need confirmation through ExecuteWrite.
+var simWriteTools = map[string]bool{
+	"send_money":       true,
+	"deposit_savings":  true,
+	"withdraw_savings": true,
+}
+
+// Execute runs a tool immediately, including writes.
+func (s *liminalSimulator) Execute(ctx context.Context, req *core.ExecuteRequest) (*core.ExecuteResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.run(req.UserID, req.Tool, req.Input)
+	if err != nil {
+		return &core.ExecuteResponse{Success: false, Error: err.Error()}, nil
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &core.ExecuteResponse{Success: true, Data: encoded}, nil
+}
+
+// ExecuteWrite validates a write and holds it until Confirm or Cancel.
+func (s *liminalSimulator) ExecuteWrite(ctx context.Context, req *core.ExecuteRequest) (*core.ExecuteResponse, error) {
+	if !simWriteTools[req.Tool] {
+		return s.Execute(ctx, req)
+	}
+	var in simInput
+	if err := json.Unmarshal(req.Input, &in); err != nil {
+		return &core.ExecuteResponse{Success: false, Error: fmt.Sprintf("invalid input: %v", err)}, nil
+	}
+	amount, err := in.amount()
+	if err != nil {
+		return &core.ExecuteResponse{Success: false, Error: err.Error()}, nil
+	}
+
+	s.mu.Lock()
+	s.nextID++
+	id := fmt.Sprintf("sim-confirm-%d", s.nextID)
+	s.pending[id] = simPending{userID: req.UserID, tool: req.Tool, input: req.Input}
+	s.mu.Unlock()
+
+	summary := fmt.Sprintf("%s %.2f %s", strings.ReplaceAll(req.Tool, "_", " "), amount, in.currency())
+	data, _ := json.Marshal(map[string]interface{}{
+		"status":          "pending_confirmation",
+		"confirmation_id": id,
+		"summary":         summary,
+	})
+	return &core.ExecuteResponse{Success: true, Data: data}, nil
+}
+
+// Confirm executes a held write.
+func (s *liminalSimulator) Confirm(ctx context.Context, userID, confirmationID string) (*core.ExecuteResponse, error) {
+	s.mu.Lock()
+	p, ok := s.pending[confirmationID]
+	if ok && p.userID == userID {
+		delete(s.pending, confirmationID)
+	}
+	s.mu.Unlock()
+	if !ok || p.userID != userID {
+		return &core.ExecuteResponse{Success: false, Error: "confirmation not found"}, nil
+	}
+	return s.Execute(ctx, &core.ExecuteRequest{UserID: userID, Tool: p.tool, Input: p.input})
+}
+
+// Cancel drops a held write.
+func (s *liminalSimulator) Cancel(ctx context.Context, userID, confirmationID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pending[confirmationID]
+	if !ok || p.userID != userID {
+		return errors.New("confirmation not found")
+	}
+	delete(s.pending, confirmationID)
+	return nil
+}
+
+// run dispatches a tool call. Callers hold s.mu.
+func (s *liminalSimulator) run(userID, tool string, input json.RawMessage) (interface{}, error) {
+	var in simInput
+	if len(input) > 0 {
+		if err := json.Unmarshal(input, &in); err != nil {
+			return nil, fmt.Errorf("invalid input: %v", err)
+		}
+	}
+	s.accrue()
+	u := s.user(userID)
+
+	switch tool {
+	case "get_balance":
+		return map[string]interface{}{"balances": balanceList(u.Wallet)}, nil
+
+	case "get_savings_balance":
+		var positions []map[string]interface{}
+		for _, b := range balanceList(u.Savings) {
+			currency := b["currency"].(string)
+			rate := s.rates[currency]
+			positions = append(positions, map[string]interface{}{
+				"currency":        currency,
+				"vault":           rate.Vault,
+				"balance":         b["amount"],
+				"apy":             rate.APY,
+				"interest_earned": fmt.Sprintf("%.2f", s.earned[u.ID][currency]),
+			})
+		}
+		return map[string]interface{}{"positions": positions}, nil
+
+	case "get_vault_rates":
+		var rates []SimRate
+		for _, r := range s.rates {
+			rates = append(rates, r)
+		}
+		sort.Slice(rates, func(i, j int) bool { return rates[i].Currency < rates[j].Currency })
+		return map[string]interface{}{"rates": rates}, nil
+
+	case "get_transactions":
+		limit := in.Limit
+		if limit <= 0 {
+			limit = 50
+		}
+		history := s.transactions[u.ID]
+		// Newest first, like the API.
+		out := make([]map[string]interface{}, 0, limit)
+		for i := len(history) - 1; i >= 0 && len(out) < limit; i-- {
+			out = append(out, history[i])
+		}
+		return map[string]interface{}{"transactions": out, "count": len(out)}, nil
+
+	case "get_profile":
+		return map[string]interface{}{
+			"id":          u.ID,
+			"display_tag": u.DisplayTag,
+			"name":        u.Name,
+			"email":       u.Email,
+		}, nil
+
+	case "search_users":
+		query := strings.ToLower(strings.TrimPrefix(firstNonEmpty(in.Query, in.DisplayTag), "@"))
+		var matches []map[string]interface{}
+		for _, other := range s.users {
+			tag := strings.ToLower(strings.TrimPrefix(other.DisplayTag, "@"))
+			if other.ID != u.ID && (query == "" || strings.Contains(tag, query) || strings.Contains(strings.ToLower(other.Name), query)) {
+				matches = append(matches, map[string]interface{}{"display_tag": other.DisplayTag, "name": other.Name})
+			}
+		}
+		sort.Slice(matches, func(i, j int) bool { return matches[i]["display_tag"].(string) < matches[j]["display_tag"].(string) })
+		return map[string]interface{}{"users": matches}, nil
+
+	case "send_money":
+		amount, err := in.amount()
+		if err != nil {
+			return nil, err
+		}
+		currency := in.currency()
+		tag := firstNonEmpty(in.Recipient, in.To, in.DisplayTag)
+		if tag == "" {
+			return nil, errors.New("recipient is required")
+		}
+		recipient := s.findByTag(tag)
+		if recipient == nil {
+			return nil, fmt.Errorf("user %s not found", tag)
+		}
+		if recipient.ID == u.ID {
+			return nil, errors.New("cannot send money to yourself")
+		}
+		if u.Wallet[currency] < amount {
+			return nil, fmt.Errorf("insufficient funds: wallet has %.2f %s", u.Wallet[currency], currency)
+		}
+		u.Wallet[currency] -= amount
+		recipient.Wallet[currency] += amount
+		tx := s.record(u, "send", amount, currency, recipient.DisplayTag, in.Note, "transfer")
+		s.record(recipient, "receive", amount, currency, u.DisplayTag, in.Note, "transfer")
+		return map[string]interface{}{"status": "completed", "transaction": tx}, nil
+
+	case "deposit_savings", "withdraw_savings":
+		amount, err := in.amount()
+		if err != nil {
+			return nil, err
+		}
+		currency := in.currency()
+		if _, ok := s.rates[currency]; !ok {
+			return nil, fmt.Errorf("no savings vault for %s", currency)
+		}
+		from, to, txType := u.Wallet, u.Savings, "send"
+		if tool == "withdraw_savings" {
+			from, to, txType = u.Savings, u.Wallet, "receive"
+		}
+		if from[currency] < amount {
+			return nil, fmt.Errorf("insufficient funds: %.2f %s available", from[currency], currency)
+		}
+		from[currency] -= amount
+		to[currency] += amount
+		tx := s.record(u, txType, amount, currency, "savings", strings.ReplaceAll(tool, "_", " "), "savings")
+		return map[string]interface{}{"status": "completed", "transaction": tx}, nil
+	}
+
+	return nil, fmt.Errorf("unknown tool %q", tool)
+}
+
+// findByTag looks a user up by display tag. Callers hold s.mu.
+func (s *liminalSimulator) findByTag(tag string) *SimUser {
+	tag = strings.ToLower(strings.TrimPrefix(tag, "@"))
+	for _, u := range s.users {
+		if strings.ToLower(strings.TrimPrefix(u.DisplayTag, "@")) == tag {
+			return u
+		}
+	}
+	return nil
+}
+
+// balanceList renders balances as a sorted list of {currency, amount}.
+func balanceList(balances map[string]float64) []map[string]interface{} {
+	var currencies []string
+	for c := range balances {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+	out := make([]map[string]interface{}, 0, len(currencies))
+	for _, c := range currencies {
+		out = append(out, map[string]interface{}{"currency": c, "amount": fmt.Sprintf("%.2f", balances[c])})
+	}
+	return out
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
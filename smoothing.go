@@ -0,0 +1,537 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+	"github.com/becomeliminal/nim-go-sdk/tools"
+)
+
+// ============================================================================
+// CUSTOM TOOL: INCOME SMOOTHING
+// ============================================================================
+// Implements the Cyclical Spender strategy "divide monthly income into weekly
+// 'paychecks'". While smoothing is on, every incoming payment is parked in
+// savings and a fixed weekly allowance is released back to the wallet on the
+// chosen weekday. The ledger records every park and release so the user can
+// see exactly where their money is.
+//
+// Volatility is reported as the standard deviation (sqrt of calculateVariance)
+// of weekly wallet inflows, before and after smoothing.
+
+// SmoothingEntry is one movement recorded in the smoothing ledger.
+type SmoothingEntry struct {
+	Type      string    `json:"type"` // park or release
+	Amount    float64   `json:"amount"`
+	Currency  string    `json:"currency"`
+	TxID      string    `json:"tx_id,omitempty"`
+	Status    string    `json:"status"` // done or failed
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SmoothingPlan is the per-user income smoothing state.
+type SmoothingPlan struct {
+	Enabled      bool             `json:"enabled"`
+	WeeklyAmount float64          `json:"weekly_amount"`
+	Manual       bool             `json:"manual"` // weekly amount set by the user
+	ReleaseDay   time.Weekday     `json:"release_day"`
+	MinIncome    float64          `json:"min_income"`
+	Currency     string           `json:"currency"`
+	StartedAt    time.Time        `json:"started_at"`
+	LastRelease  time.Time        `json:"last_release"`
+	Ledger       []SmoothingEntry `json:"ledger"`
+}
+
+// parked is the amount currently held back in savings.
+func (p *SmoothingPlan) parked() float64 {
+	total := 0.0
+	for _, e := range p.Ledger {
+		if e.Status != "done" {
+			continue
+		}
+		switch e.Type {
+		case "park":
+			total += e.Amount
+		case "release":
+			total -= e.Amount
+		}
+	}
+	return math.Max(total, 0)
+}
+
+// hasParked reports whether a payment was parked successfully. Failed parks
+// don't count, so they are retried on the next run.
+func (p *SmoothingPlan) hasParked(txID string) bool {
+	for _, e := range p.Ledger {
+		if e.Type == "park" && e.TxID == txID && e.Status == "done" {
+			return true
+		}
+	}
+	return false
+}
+
+// recordPark adds a park to the ledger, replacing an earlier failed attempt
+// for the same payment.
+func (p *SmoothingPlan) recordPark(entry SmoothingEntry) {
+	for i, e := range p.Ledger {
+		if e.Type == "park" && e.TxID == entry.TxID && e.Status == "failed" {
+			p.Ledger[i] = entry
+			return
+		}
+	}
+	p.Ledger = append(p.Ledger, entry)
+}
+
+// recordRelease adds a release to the ledger, replacing a failed attempt
+// since the last successful release so retries don't pile up.
+func (p *SmoothingPlan) recordRelease(entry SmoothingEntry) {
+	for i := len(p.Ledger) - 1; i >= 0; i-- {
+		e := p.Ledger[i]
+		if e.Type == "release" && e.Status == "failed" && e.CreatedAt.After(p.LastRelease) {
+			p.Ledger[i] = entry
+			return
+		}
+	}
+	p.Ledger = append(p.Ledger, entry)
+}
+
+// nextRelease returns the first release time strictly after t.
+func (p *SmoothingPlan) nextRelease(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := (int(p.ReleaseDay) - int(day.Weekday()) + 7) % 7
+	next := day.AddDate(0, 0, offset)
+	if !next.After(t) {
+		next = next.AddDate(0, 0, 7)
+	}
+	return next
+}
+
+// isSmoothableIncome reports whether a transaction is income smoothing should
+// park. Money coming back from savings is never re-parked, and income in
+// another currency than the plan's is left alone.
+func isSmoothableIncome(tx map[string]interface{}, minIncome float64, currency string) bool {
+	txCurrency := txString(tx, "currency")
+	return txString(tx, "type") == "receive" &&
+		txString(tx, "category") != "savings" &&
+		(txCurrency == "" || strings.EqualFold(txCurrency, currency)) &&
+		txAmount(tx) >= minIncome
+}
+
+// suggestWeeklyAllowance spreads the observed income evenly over the weeks it
+// covers.
+func suggestWeeklyAllowance(transactions []map[string]interface{}, minIncome float64, currency string) float64 {
+	var first, last time.Time
+	total := 0.0
+	for _, tx := range transactions {
+		ts, ok := txTime(tx)
+		if !ok || !isSmoothableIncome(tx, minIncome, currency) {
+			continue
+		}
+		total += txAmount(tx)
+		if first.IsZero() || ts.Before(first) {
+			first = ts
+		}
+		if ts.After(last) {
+			last = ts
+		}
+	}
+	weeks := math.Max(last.Sub(first).Hours()/24/7, 1)
+	// A single payment spans no time, so assume it is a month's income.
+	if first.Equal(last) {
+		weeks = 52.0 / 12
+	}
+	return math.Floor(total/weeks*100) / 100
+}
+
+// weekStart truncates t to midnight on the Monday of its week.
+func weekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day.AddDate(0, 0, -offset)
+}
+
+// weeklySeries buckets amounts into consecutive weeks, filling gaps with zero.
+func weeklySeries(points map[time.Time]float64) []float64 {
+	if len(points) == 0 {
+		return nil
+	}
+	var weeks []time.Time
+	for w := range points {
+		weeks = append(weeks, w)
+	}
+	sort.Slice(weeks, func(i, j int) bool { return weeks[i].Before(weeks[j]) })
+
+	var series []float64
+	for w := weeks[0]; !w.After(weeks[len(weeks)-1]); w = w.AddDate(0, 0, 7) {
+		series = append(series, points[w])
+	}
+	return series
+}
+
+// smoothingVolatility compares weekly wallet inflows with and without
+// smoothing over the same history, simulating the weekly releases.
+func smoothingVolatility(transactions []map[string]interface{}, weekly, minIncome float64, currency string) map[string]interface{} {
+	raw := make(map[time.Time]float64)
+	for _, tx := range transactions {
+		ts, ok := txTime(tx)
+		if !ok || !isSmoothableIncome(tx, minIncome, currency) {
+			continue
+		}
+		raw[weekStart(ts)] += txAmount(tx)
+	}
+	before := weeklySeries(raw)
+	if len(before) < 2 {
+		return map[string]interface{}{
+			"summary": "Not enough weekly income history to measure volatility",
+		}
+	}
+
+	// Replay the history: income goes into the buffer, the allowance comes out.
+	var after []float64
+	buffer := 0.0
+	for _, income := range before {
+		buffer += income
+		release := math.Min(weekly, buffer)
+		buffer -= release
+		after = append(after, release)
+	}
+
+	stdBefore := math.Sqrt(calculateVariance(before))
+	stdAfter := math.Sqrt(calculateVariance(after))
+	reduction := 0.0
+	if stdBefore > 0 {
+		reduction = (1 - stdAfter/stdBefore) * 100
+	}
+
+	return map[string]interface{}{
+		"weeks_analyzed":          len(before),
+		"weekly_stddev_before":    fmt.Sprintf("%.2f", stdBefore),
+		"weekly_stddev_after":     fmt.Sprintf("%.2f", stdAfter),
+		"volatility_reduction":    fmt.Sprintf("%.0f%%", reduction),
+		"avg_weekly_income":       fmt.Sprintf("%.2f", calculateMean(before)),
+		"simulated_buffer_at_end": fmt.Sprintf("%.2f", buffer),
+	}
+}
+
+// smoothingService owns income smoothing plans for all users.
+type smoothingService struct {
+	liminalExecutor core.ToolExecutor
+	store           *jsonStore
+
+	mu     sync.Mutex
+	plans  map[string]*SmoothingPlan
+	moving map[string]bool // users with transfers in flight
+}
+
+func newSmoothingService(liminalExecutor core.ToolExecutor) *smoothingService {
+	s := &smoothingService{
+		liminalExecutor: liminalExecutor,
+		store:           newJSONStore("smoothing.json"),
+		plans:           make(map[string]*SmoothingPlan),
+		moving:          make(map[string]bool),
+	}
+	if err := s.store.load(&s.plans); err != nil {
+		log.Printf("⚠️  Smoothing state not loaded: %v", err)
+	}
+	return s
+}
+
+func (s *smoothingService) plan(userID string) *SmoothingPlan {
+	plan, ok := s.plans[userID]
+	if !ok {
+		plan = &SmoothingPlan{ReleaseDay: time.Monday, MinIncome: 100, Currency: "USD"}
+		s.plans[userID] = plan
+	}
+	return plan
+}
+
+func (s *smoothingService) persist() {
+	if err := s.store.save(s.plans); err != nil {
+		log.Printf("⚠️  Failed to save smoothing state: %v", err)
+	}
+}
+
+// process parks any new income and releases the weekly allowance if a release
+// day has passed since the last one. It returns the transactions it fetched so
+// callers can reuse them.
+func (s *smoothingService) process(ctx context.Context, userID, requestID string) ([]map[string]interface{}, error) {
+	transactions, err := fetchTransactions(ctx, s.liminalExecutor, userID, requestID, 100)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	plan := s.plan(userID)
+	if !plan.Enabled || s.moving[userID] {
+		s.mu.Unlock()
+		return transactions, nil
+	}
+	var parks []SmoothingEntry
+	for _, tx := range transactions {
+		ts, ok := txTime(tx)
+		if !ok || ts.Before(plan.StartedAt) || !isSmoothableIncome(tx, plan.MinIncome, plan.Currency) || plan.hasParked(txID(tx)) {
+			continue
+		}
+		parks = append(parks, SmoothingEntry{Type: "park", Amount: txAmount(tx), Currency: plan.Currency, TxID: txID(tx), Status: "done", CreatedAt: time.Now()})
+	}
+	s.moving[userID] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.moving, userID)
+		s.mu.Unlock()
+	}()
+
+	// Transfers are network calls, made without the lock so other users and
+	// the settings tools don't wait on them.
+	for i := range parks {
+		if err := depositSavings(ctx, s.liminalExecutor, userID, requestID, parks[i].Amount, parks[i].Currency); err != nil {
+			parks[i].Status = "failed"
+			parks[i].Error = err.Error()
+		}
+	}
+
+	s.mu.Lock()
+	plan = s.plan(userID)
+	for _, entry := range parks {
+		plan.recordPark(entry)
+	}
+	changed := len(parks) > 0
+
+	now := time.Now()
+	from := plan.LastRelease
+	if from.IsZero() {
+		from = plan.StartedAt
+	}
+	var release *SmoothingEntry
+	if due := plan.nextRelease(from); plan.Enabled && !due.After(now) {
+		if amount := math.Min(plan.WeeklyAmount, plan.parked()); amount > 0 {
+			release = &SmoothingEntry{Type: "release", Amount: amount, Currency: plan.Currency, Status: "done", CreatedAt: now}
+		} else {
+			// Nothing to release; missed weeks are not paid out retroactively.
+			plan.LastRelease = now
+			changed = true
+		}
+	}
+	if changed {
+		s.persist()
+	}
+	s.mu.Unlock()
+	if release == nil {
+		return transactions, nil
+	}
+
+	err = withdrawSavings(ctx, s.liminalExecutor, userID, requestID, release.Amount, release.Currency)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	plan = s.plan(userID)
+	if err != nil {
+		// LastRelease stays put, so the allowance is retried on the next run.
+		release.Status = "failed"
+		release.Error = err.Error()
+	} else {
+		log.Printf("💸 Released weekly allowance of %.2f %s for user %s", release.Amount, release.Currency, userID)
+	}
+	plan.recordRelease(*release)
+	if err == nil {
+		// Missed weeks are not paid out retroactively; the next release is a week from now.
+		plan.LastRelease = now
+	}
+	s.persist()
+	return transactions, nil
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday,
+	"wednesday": time.Wednesday, "thursday": time.Thursday, "friday": time.Friday,
+	"saturday": time.Saturday,
+}
+
+func createSmoothingSettingsTool(smoothing *smoothingService) core.Tool {
+	return tools.New("configure_income_smoothing").
+		Description("Turn income smoothing on or off and adjust the weekly allowance. While on, incoming payments are parked in savings and a fixed weekly allowance is released back to the wallet. Turning it on requires opt_in=true after the user explicitly agrees.").
+		Schema(tools.ObjectSchema(map[string]interface{}{
+			"enabled":       tools.BooleanProperty("Whether smoothing is active"),
+			"weekly_amount": tools.NumberProperty("Weekly allowance to release; 0 recalculates it from income history"),
+			"release_day":   tools.StringProperty("Weekday to release the allowance (default: monday)"),
+			"min_income":    tools.NumberProperty("Ignore incoming payments smaller than this, e.g. friends paying you back (default: 100)"),
+			"currency":      tools.StringProperty("Currency for transfers (default: USD)"),
+			"opt_in":        tools.BooleanProperty("Must be true when turning smoothing on - confirms the user explicitly agreed to automatic transfers"),
+		})).
+		Handler(func(ctx context.Context, toolParams *core.ToolParams) (*core.ToolResult, error) {
+			var params struct {
+				Enabled      *bool    `json:"enabled"`
+				WeeklyAmount *float64 `json:"weekly_amount"`
+				ReleaseDay   *string  `json:"release_day"`
+				MinIncome    *float64 `json:"min_income"`
+				Currency     *string  `json:"currency"`
+				OptIn        bool     `json:"opt_in"`
+			}
+			if err := json.Unmarshal(toolParams.Input, &params); err != nil {
+				return &core.ToolResult{
+					Success: false,
+					Error:   fmt.Sprintf("invalid input: %v", err),
+				}, nil
+			}
+
+			if params.ReleaseDay != nil {
+				if _, ok := weekdayNames[strings.ToLower(*params.ReleaseDay)]; !ok {
+					return &core.ToolResult{Success: false, Error: fmt.Sprintf("unknown weekday %q", *params.ReleaseDay)}, nil
+				}
+			}
+			if params.WeeklyAmount != nil && *params.WeeklyAmount < 0 {
+				return &core.ToolResult{Success: false, Error: "weekly_amount cannot be negative"}, nil
+			}
+
+			// The allowance may need recalculating from income history. Fetch
+			// it before locking, so the plan is read and written under one lock
+			// and nothing process records in between is overwritten.
+			smoothing.mu.Lock()
+			current := *smoothing.plan(toolParams.UserID)
+			smoothing.mu.Unlock()
+			manual, weekly := current.Manual, current.WeeklyAmount
+			if params.WeeklyAmount != nil {
+				manual, weekly = *params.WeeklyAmount > 0, *params.WeeklyAmount
+			}
+			recalculate := !manual && (weekly == 0 || params.MinIncome != nil || params.Currency != nil)
+			var transactions []map[string]interface{}
+			if recalculate {
+				var err error
+				transactions, err = fetchTransactions(ctx, smoothing.liminalExecutor, toolParams.UserID, toolParams.RequestID, 100)
+				if err != nil {
+					return &core.ToolResult{
+						Success: false,
+						Error:   err.Error(),
+					}, nil
+				}
+			}
+
+			smoothing.mu.Lock()
+			defer smoothing.mu.Unlock()
+			plan := smoothing.plan(toolParams.UserID)
+			updated := *plan
+
+			if params.ReleaseDay != nil {
+				updated.ReleaseDay = weekdayNames[strings.ToLower(*params.ReleaseDay)]
+			}
+			if params.MinIncome != nil {
+				updated.MinIncome = *params.MinIncome
+			}
+			if params.Currency != nil && *params.Currency != "" {
+				updated.Currency = *params.Currency
+			}
+			if params.Enabled != nil {
+				if *params.Enabled && !updated.Enabled {
+					if !params.OptIn {
+						return &core.ToolResult{
+							Success: false,
+							Error:   "the user must explicitly opt in (opt_in=true) before smoothing moves money",
+						}, nil
+					}
+					updated.StartedAt = time.Now()
+					updated.LastRelease = time.Time{}
+				}
+				updated.Enabled = *params.Enabled
+			}
+
+			if params.WeeklyAmount != nil {
+				updated.WeeklyAmount = *params.WeeklyAmount
+				updated.Manual = *params.WeeklyAmount > 0
+			}
+			if recalculate && !updated.Manual {
+				updated.WeeklyAmount = suggestWeeklyAllowance(transactions, updated.MinIncome, updated.Currency)
+			}
+
+			*plan = updated
+			smoothing.persist()
+
+			return &core.ToolResult{
+				Success: true,
+				Data: map[string]interface{}{
+					"enabled":       updated.Enabled,
+					"weekly_amount": fmt.Sprintf("%.2f", updated.WeeklyAmount),
+					"manual_amount": updated.Manual,
+					"release_day":   strings.ToLower(updated.ReleaseDay.String()),
+					"min_income":    updated.MinIncome,
+					"currency":      updated.Currency,
+					"next_release":  updated.nextRelease(time.Now()).Format(time.RFC3339),
+				},
+			}, nil
+		}).
+		Build()
+}
+
+func createSmoothingLedgerTool(smoothing *smoothingService) core.Tool {
+	return tools.New("get_smoothing_ledger").
+		Description("Show the income smoothing ledger: income parked in savings, weekly allowances released, the amount still held back, the next release, and how much smoothing reduces week-to-week income volatility. Also parks new income and releases any allowance that is due.").
+		Schema(tools.ObjectSchema(map[string]interface{}{
+			"limit": tools.IntegerProperty("Maximum number of ledger entries to return (default: 20)"),
+		})).
+		Handler(func(ctx context.Context, toolParams *core.ToolParams) (*core.ToolResult, error) {
+			var params struct {
+				Limit int `json:"limit"`
+			}
+			if err := json.Unmarshal(toolParams.Input, &params); err != nil {
+				return &core.ToolResult{
+					Success: false,
+					Error:   fmt.Sprintf("invalid input: %v", err),
+				}, nil
+			}
+			if params.Limit == 0 {
+				params.Limit = 20
+			}
+
+			transactions, err := smoothing.process(ctx, toolParams.UserID, toolParams.RequestID)
+			if err != nil {
+				return &core.ToolResult{
+					Success: false,
+					Error:   err.Error(),
+				}, nil
+			}
+
+			smoothing.mu.Lock()
+			plan := *smoothing.plan(toolParams.UserID)
+			smoothing.mu.Unlock()
+
+			weekly := plan.WeeklyAmount
+			if weekly == 0 {
+				weekly = suggestWeeklyAllowance(transactions, plan.MinIncome, plan.Currency)
+			}
+			ledger := plan.Ledger
+			if len(ledger) > params.Limit {
+				ledger = ledger[len(ledger)-params.Limit:]
+			}
+
+			result := map[string]interface{}{
+				"enabled":       plan.Enabled,
+				"weekly_amount": fmt.Sprintf("%.2f", weekly),
+				"parked":        fmt.Sprintf("%.2f", plan.parked()),
+				"currency":      plan.Currency,
+				"ledger":        ledger,
+				"volatility":    smoothingVolatility(transactions, weekly, plan.MinIncome, plan.Currency),
+			}
+			if plan.Enabled {
+				from := plan.LastRelease
+				if from.IsZero() {
+					from = plan.StartedAt
+				}
+				result["next_release"] = plan.nextRelease(from).Format(time.RFC3339)
+			}
+
+			return &core.ToolResult{
+				Success: true,
+				Data:    result,
+			}, nil
+		}).
+		Build()
+}
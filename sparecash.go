@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+	"github.com/becomeliminal/nim-go-sdk/tools"
+)
+
+// ============================================================================
+// CUSTOM TOOL: SPARE CASH CALCULATOR
+// ============================================================================
+// Defines "spare cash" for the proactive greeting:
+//
+//   spare cash = wallet balance
+//              - recurring bills due before next payday
+//              - the user's safety buffer
+//              - forecast discretionary spend until next payday
+//
+// Anything left over is safe to save; balance minus bills and buffer, spread
+// over the days to payday, is what is safe to spend per day.
+
+// defaultPaydayGapDays is assumed when no recurring income is detected.
+const defaultPaydayGapDays = 14
+
+// SpareCashItem is one line of the spare cash explanation.
+type SpareCashItem struct {
+	Label  string  `json:"label"`
+	Amount float64 `json:"amount"`
+	Detail string  `json:"detail,omitempty"`
+}
+
+// SpareCash is the result of the spare cash calculation.
+type SpareCash struct {
+	Balance          float64         `json:"balance"`
+	NextPayday       time.Time       `json:"next_payday"`
+	PaydayDetected   bool            `json:"payday_detected"`
+	DaysToPayday     int             `json:"days_to_payday"`
+	UpcomingBills    float64         `json:"upcoming_bills"`
+	Buffer           float64         `json:"buffer"`
+	Discretionary    float64         `json:"forecast_discretionary"`
+	SpareCash        float64         `json:"spare_cash"`
+	SafeToSave       float64         `json:"safe_to_save"`
+	SafeToSpendDaily float64         `json:"safe_to_spend_per_day"`
+	Items            []SpareCashItem `json:"items"`
+}
+
+// computeSpareCash works out spare cash from the balance and history.
+func computeSpareCash(balance, buffer float64, transactions []map[string]interface{}, now time.Time) SpareCash {
+	result := SpareCash{Balance: balance, Buffer: buffer}
+	result.Items = append(result.Items, SpareCashItem{Label: "Wallet balance", Amount: balance})
+
+	// Next payday: the soonest expected paycheck across income streams.
+	for _, stream := range detectRecurring(transactions, "receive") {
+		next := stream.nextDue(now)
+		if !next.IsZero() && (result.NextPayday.IsZero() || next.Before(result.NextPayday)) {
+			result.NextPayday = next
+			result.PaydayDetected = true
+		}
+	}
+	if result.NextPayday.IsZero() {
+		result.NextPayday = now.AddDate(0, 0, defaultPaydayGapDays)
+	}
+	days := math.Ceil(result.NextPayday.Sub(now).Hours() / 24)
+	result.DaysToPayday = int(math.Max(days, 1))
+
+	// Bills due before payday.
+	bills := detectRecurring(transactions, "send")
+	recurring := make(map[string]bool, len(bills))
+	for _, bill := range bills {
+		recurring[bill.Counterparty] = true
+		due := bill.dueBetween(now, result.NextPayday)
+		if len(due) == 0 {
+			continue
+		}
+		amount := bill.AvgAmount * float64(len(due))
+		result.UpcomingBills += amount
+		result.Items = append(result.Items, SpareCashItem{
+			Label:  fmt.Sprintf("Upcoming %s bill: %s", bill.Cadence, bill.Counterparty),
+			Amount: -amount,
+			Detail: fmt.Sprintf("due %s", due[0].Format("Mon Jan 2")),
+		})
+	}
+
+	if buffer > 0 {
+		result.Items = append(result.Items, SpareCashItem{Label: "Safety buffer", Amount: -buffer})
+	}
+
+	// Discretionary: everything else sent in the last 30 days, per day.
+	since := now.AddDate(0, 0, -30)
+	spent := 0.0
+	for _, tx := range transactions {
+		ts, ok := txTime(tx)
+		if !ok || ts.Before(since) || txString(tx, "type") != "send" || isSavingsTransfer(tx) {
+			continue
+		}
+		if recurring[normalizeCounterparty(txString(tx, "counterparty"))] {
+			continue
+		}
+		spent += txAmount(tx)
+	}
+	daily := spent / 30
+	result.Discretionary = daily * float64(result.DaysToPayday)
+	result.Items = append(result.Items, SpareCashItem{
+		Label:  "Forecast everyday spending",
+		Amount: -result.Discretionary,
+		Detail: fmt.Sprintf("$%.2f/day for %d days", daily, result.DaysToPayday),
+	})
+
+	result.SpareCash = balance - result.UpcomingBills - buffer - result.Discretionary
+	result.SafeToSave = math.Max(result.SpareCash, 0)
+	result.SafeToSpendDaily = math.Max(balance-result.UpcomingBills-buffer, 0) / float64(result.DaysToPayday)
+
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	result.UpcomingBills = round(result.UpcomingBills)
+	result.Discretionary = round(result.Discretionary)
+	result.SpareCash = round(result.SpareCash)
+	result.SafeToSave = round(result.SafeToSave)
+	result.SafeToSpendDaily = round(result.SafeToSpendDaily)
+	for i := range result.Items {
+		result.Items[i].Amount = round(result.Items[i].Amount)
+	}
+	sort.SliceStable(result.Items[1:], func(i, j int) bool {
+		return result.Items[1+i].Amount < result.Items[1+j].Amount
+	})
+	return result
+}
+
+// spareCashService keeps the user-configured safety buffers.
+type spareCashService struct {
+	liminalExecutor core.ToolExecutor
+	store           *jsonStore
+
+	mu      sync.Mutex
+	buffers map[string]float64
+}
+
+func newSpareCashService(liminalExecutor core.ToolExecutor) *spareCashService {
+	s := &spareCashService{
+		liminalExecutor: liminalExecutor,
+		store:           newJSONStore("spare_cash.json"),
+		buffers:         make(map[string]float64),
+	}
+	if err := s.store.load(&s.buffers); err != nil {
+		log.Printf("⚠️  Spare cash settings not loaded: %v", err)
+	}
+	return s
+}
+
+func (s *spareCashService) buffer(userID string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buffers[userID]
+}
+
+func (s *spareCashService) setBuffer(userID string, buffer float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buffers[userID] = buffer
+	if err := s.store.save(s.buffers); err != nil {
+		log.Printf("⚠️  Failed to save spare cash settings: %v", err)
+	}
+}
+
+// calculate fetches the live balance and history and computes spare cash.
+func (s *spareCashService) calculate(ctx context.Context, toolParams *core.ToolParams, useCSV bool) (SpareCash, error) {
+	transactions, err := loadToolTransactions(ctx, s.liminalExecutor, toolParams, useCSV)
+	if err != nil {
+		return SpareCash{}, err
+	}
+
+	var balance float64
+	if useCSV {
+		// The newest row's running balance stands in for the wallet.
+		var latest time.Time
+		for _, tx := range transactions {
+			if ts, ok := txTime(tx); ok && !ts.Before(latest) {
+				latest = ts
+				balance, _ = numberValue(tx["balance_after"])
+			}
+		}
+	} else {
+		balanceData, err := callLiminal(ctx, s.liminalExecutor, toolParams.UserID, toolParams.RequestID, "get_balance", map[string]interface{}{})
+		if err != nil {
+			return SpareCash{}, err
+		}
+		balance = balanceTotal(balanceData, mainCurrency(transactions))
+	}
+
+	return computeSpareCash(balance, s.buffer(toolParams.UserID), transactions, time.Now()), nil
+}
+
+func createSpareCashTool(spareCash *spareCashService) core.Tool {
+	return tools.New("get_spare_cash").
+		Description("Calculate the user's spare cash: wallet balance minus bills due before next payday, their safety buffer, and forecast everyday spending until payday. Returns safe-to-save and safe-to-spend-per-day amounts with an itemized explanation. Use this for the greeting.").
+		Schema(tools.ObjectSchema(map[string]interface{}{
+			"buffer":  tools.NumberProperty("Set the safety buffer the user always wants to keep in the wallet (remembered for next time)"),
+			"use_csv": tools.BooleanProperty("Use local CSV file instead of API (for testing, default: false)"),
+		})).
+		Handler(func(ctx context.Context, toolParams *core.ToolParams) (*core.ToolResult, error) {
+			var params struct {
+				Buffer *float64 `json:"buffer"`
+				UseCSV bool     `json:"use_csv"`
+			}
+			if err := json.Unmarshal(toolParams.Input, &params); err != nil {
+				return &core.ToolResult{
+					Success: false,
+					Error:   fmt.Sprintf("invalid input: %v", err),
+				}, nil
+			}
+
+			if params.Buffer != nil {
+				if *params.Buffer < 0 {
+					return &core.ToolResult{Success: false, Error: "buffer cannot be negative"}, nil
+				}
+				spareCash.setBuffer(toolParams.UserID, *params.Buffer)
+			}
+
+			result, err := spareCash.calculate(ctx, toolParams, params.UseCSV)
+			if err != nil {
+				return &core.ToolResult{
+					Success: false,
+					Error:   err.Error(),
+				}, nil
+			}
+
+			summary := fmt.Sprintf("You have $%.2f spare - safe to save - and can spend about $%.2f a day until payday in %d days.", result.SafeToSave, result.SafeToSpendDaily, result.DaysToPayday)
+			if result.SpareCash < 0 {
+				summary = fmt.Sprintf("You're $%.2f short of covering bills, your buffer and usual spending until payday in %d days.", -result.SpareCash, result.DaysToPayday)
+			}
+
+			return &core.ToolResult{
+				Success: true,
+				Data: map[string]interface{}{
+					"spare_cash":  result,
+					"summary":     summary,
+					"data_source": map[string]bool{"csv": params.UseCSV, "api": !params.UseCSV},
+				},
+			}, nil
+		}).
+		Build()
+}
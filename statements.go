@@ -0,0 +1,315 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// BANK STATEMENTS
+// ============================================================================
+// Users can bring in statements from their other banks - CSV exports
+// (importer.go), OFX/QFX (ofx.go), QIF (qif.go), camt.053 (camt.go) and
+// MT940 (mt940.go) - so analyze_spending and analyze_money_personality see
+// their whole financial life, not just the Liminal wallet. Files are
+// uploaded through the companion API (POST /v1/statements) or `neurapay
+// import --user`, parsed into the same transaction maps Liminal returns,
+// and kept per user in statements.json.
+//
+// Banks' downloads overlap, so a transaction already imported from an
+// earlier file is skipped rather than counted twice.
+
+// maxStatementTransactions bounds how many imported transactions are kept
+// per user.
+const maxStatementTransactions = 20000
+
+// detectStatementFormat sniffs the format from the content, falling back to
+// the file extension; anything unrecognised is tried as CSV.
+func detectStatementFormat(name string, data []byte) string {
+	head := data
+	if len(head) > 4096 {
+		head = head[:4096]
+	}
+	text, _, _ := decodeCSV(head, "")
+	upper := strings.ToUpper(strings.TrimSpace(text))
+	ext := strings.ToLower(filepath.Ext(name))
+	switch {
+	case strings.Contains(upper, "OFXHEADER") || strings.Contains(upper, "<OFX>"):
+		if ext == ".qfx" {
+			return "qfx"
+		}
+		return "ofx"
+	case strings.HasPrefix(upper, "!TYPE:") || strings.HasPrefix(upper, "!ACCOUNT") || strings.HasPrefix(upper, "!OPTION:"):
+		return "qif"
+	case strings.Contains(text, "camt.05") || strings.Contains(text, "<BkToCstmrStmt"):
+		return "camt053"
+	case looksLikeMT940(text):
+		return "mt940"
+	}
+	switch ext {
+	case ".ofx", ".qfx", ".qif":
+		return ext[1:]
+	case ".sta", ".mt940":
+		return "mt940"
+	}
+	return "csv"
+}
+
+// importStatement parses a statement in any supported format. preset only
+// applies to CSV; "" detects the layout.
+func importStatement(name string, data []byte, preset string) (*ImportResult, error) {
+	switch format := detectStatementFormat(name, data); format {
+	case "ofx", "qfx":
+		return importOFX(data, format)
+	case "qif":
+		return importQIF(data)
+	case "camt053":
+		return importCAMT053(data)
+	case "mt940":
+		return importMT940(data)
+	default:
+		return importCSV(data, preset, nil)
+	}
+}
+
+// importStatementFile reads and parses a statement file.
+func importStatementFile(path, preset string) (*ImportResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open statement: %w", err)
+	}
+	return importStatement(filepath.Base(path), data, preset)
+}
+
+// loadStatementFile reads a statement for the use_csv tools and the analyze
+// command, logging the rows it skipped.
+func loadStatementFile(path string) ([]map[string]interface{}, error) {
+	result, err := importStatementFile(path, csvPreset)
+	if err != nil {
+		return nil, err
+	}
+	logImportErrors(path, result)
+	log.Printf("✅ Loaded %d transactions from %s file", len(result.Transactions), strings.ToUpper(result.Format))
+	return result.Transactions, nil
+}
+
+// ----------------------------------------------------------------------------
+// Per-user statement library
+// ----------------------------------------------------------------------------
+
+// StatementFile describes one imported file.
+type StatementFile struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Format     string    `json:"format"`
+	Accounts   []string  `json:"accounts,omitempty"`
+	From       string    `json:"from,omitempty"` // first and last transaction dates
+	To         string    `json:"to,omitempty"`
+	Added      int       `json:"added"`
+	Duplicates int       `json:"duplicates"` // already imported from another file
+	Errors     int       `json:"errors"`     // rows that couldn't be read
+	ImportedAt time.Time `json:"imported_at"`
+}
+
+// statementLibrary is everything one user has imported.
+type statementLibrary struct {
+	Files        []StatementFile          `json:"files"`
+	Transactions []map[string]interface{} `json:"transactions"`
+}
+
+// statementStore keeps every user's statement library.
+type statementStore struct {
+	store *jsonStore
+
+	mu    sync.Mutex
+	users map[string]*statementLibrary
+}
+
+func newStatementStore() *statementStore {
+	s := &statementStore{
+		store: newJSONStore("statements.json"),
+		users: make(map[string]*statementLibrary),
+	}
+	if err := s.store.load(&s.users); err != nil {
+		log.Printf("⚠️  Statements not loaded: %v", err)
+	}
+	return s
+}
+
+// add stores the transactions of a parsed statement. data is the raw file,
+// which identifies it: importing the same file again changes nothing and
+// reports every transaction as a duplicate.
+func (s *statementStore) add(userID, name string, data []byte, result *ImportResult) (StatementFile, error) {
+	sum := sha256.Sum256(data)
+	id := hex.EncodeToString(sum[:6])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lib := s.users[userID]
+	if lib == nil {
+		lib = &statementLibrary{}
+	}
+	for _, f := range lib.Files {
+		if f.ID == id {
+			f.Duplicates, f.Added = f.Added+f.Duplicates, 0
+			return f, nil
+		}
+	}
+
+	// Count-based, so two identical coffees on the same day in one file
+	// both stay, while the same day seen in an earlier download is dropped.
+	seen := make(map[string]int, len(lib.Transactions))
+	for _, tx := range lib.Transactions {
+		seen[txID(tx)]++
+	}
+	file := StatementFile{
+		ID:         id,
+		Name:       filepath.Base(name),
+		Format:     result.Format,
+		Accounts:   result.Accounts,
+		Errors:     len(result.Errors),
+		ImportedAt: time.Now(),
+	}
+	var added []map[string]interface{}
+	for _, tx := range result.Transactions {
+		if key := txID(tx); seen[key] > 0 {
+			seen[key]--
+			file.Duplicates++
+			continue
+		}
+		stored := make(map[string]interface{}, len(tx)+2)
+		for k, v := range tx {
+			stored[k] = v
+		}
+		stored["statement_id"] = id
+		stored["source"] = result.Format
+		added = append(added, stored)
+
+		t, ok := txTime(tx)
+		if !ok {
+			continue
+		}
+		day := t.Format("2006-01-02")
+		if file.From == "" || day < file.From {
+			file.From = day
+		}
+		if day > file.To {
+			file.To = day
+		}
+	}
+	file.Added = len(added)
+	if n := len(lib.Transactions) + len(added); n > maxStatementTransactions {
+		return StatementFile{}, fmt.Errorf("statements are limited to %d transactions per user; remove an older file first", maxStatementTransactions)
+	}
+
+	lib.Files = append(lib.Files, file)
+	lib.Transactions = append(lib.Transactions, added...)
+	s.users[userID] = lib
+	if err := s.store.save(s.users); err != nil {
+		return StatementFile{}, err
+	}
+	return file, nil
+}
+
+// files lists the user's imported files, oldest first.
+func (s *statementStore) files(userID string) []StatementFile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lib := s.users[userID]
+	if lib == nil {
+		return []StatementFile{}
+	}
+	return append([]StatementFile{}, lib.Files...)
+}
+
+// remove drops a file and its transactions; false if there's no such file.
+func (s *statementStore) remove(userID, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lib := s.users[userID]
+	if lib == nil {
+		return false, nil
+	}
+	found := false
+	var files []StatementFile
+	for _, f := range lib.Files {
+		if f.ID == id {
+			found = true
+		} else {
+			files = append(files, f)
+		}
+	}
+	if !found {
+		return false, nil
+	}
+	var kept []map[string]interface{}
+	for _, tx := range lib.Transactions {
+		if txString(tx, "statement_id") != id {
+			kept = append(kept, tx)
+		}
+	}
+	lib.Files, lib.Transactions = files, kept
+	return true, s.store.save(s.users)
+}
+
+// transactions returns copies of the user's imported transactions since the
+// given time (zero: all of them), oldest first.
+func (s *statementStore) transactions(userID string, since time.Time) []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lib := s.users[userID]
+	if lib == nil {
+		return nil
+	}
+	var out []map[string]interface{}
+	for _, tx := range lib.Transactions {
+		if t, ok := txTime(tx); ok && t.Before(since) {
+			continue
+		}
+		c := make(map[string]interface{}, len(tx))
+		for k, v := range tx {
+			c[k] = v
+		}
+		out = append(out, c)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return txString(out[i], "timestamp") < txString(out[j], "timestamp")
+	})
+	return out
+}
+
+// withStatements adds the user's imported transactions since the given time
+// to what a tool fetched from Liminal, and reports how many it added.
+func (s *statementStore) withStatements(userID string, transactions []map[string]interface{}, since time.Time) ([]map[string]interface{}, int) {
+	if s == nil {
+		return transactions, 0
+	}
+	imported := s.transactions(userID, since)
+	return append(transactions, imported...), len(imported)
+}
+
+// statementName keeps an uploaded file name printable and short.
+func statementName(name string) string {
+	name = filepath.Base(strings.TrimSpace(name))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7F {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "." || name == "/" || name == "" {
+		return "statement"
+	}
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	return name
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ----------------------------------------------------------------------------
+// Fixture checks
+// ----------------------------------------------------------------------------
+// The importer tests (camt_test.go, mt940_test.go, ...) re-import their
+// fixtures in testdata/imports and compare the result with the output
+// recorded in expected/<file>.json. `go test -run Fixtures -update` records
+// the current output instead, for new fixtures and deliberate parser
+// changes - review the diff before committing it.
+
+var updateImports = flag.Bool("update", false, "record the current importer output in testdata/imports/expected")
+
+const importFixtures = "testdata/imports"
+
+// importExpectation is one expected/<file>.json.
+type importExpectation struct {
+	File         string                   `json:"file"`
+	Mapping      string                   `json:"mapping,omitempty"` // CSV mapping YAML next to the fixture
+	Preset       string                   `json:"preset,omitempty"`
+	Result       *ImportResult            `json:"result"`
+	Transactions []map[string]interface{} `json:"transactions"`
+}
+
+// checkImportFixtures checks every fixture matching pattern against its
+// expected output.
+func checkImportFixtures(t *testing.T, pattern string) {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(importFixtures, pattern))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no fixtures match %s", pattern)
+	}
+	for _, path := range files {
+		name := filepath.Base(path)
+		t.Run(name, func(t *testing.T) { checkImportFixture(t, name) })
+	}
+}
+
+func checkImportFixture(t *testing.T, name string) {
+	expectedPath := filepath.Join(importFixtures, "expected", name+".json")
+	exp := importExpectation{File: name}
+	raw, err := os.ReadFile(expectedPath)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(raw, &exp); err != nil {
+			t.Fatalf("invalid %s: %v", expectedPath, err)
+		}
+	case !*updateImports:
+		t.Fatal("no expected output; run `go test -run Fixtures -update` and review it")
+	}
+
+	data, err := os.ReadFile(filepath.Join(importFixtures, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result *ImportResult
+	if exp.Mapping != "" {
+		var m CSVMapping
+		mappingRaw, err := os.ReadFile(filepath.Join(importFixtures, exp.Mapping))
+		if err == nil {
+			err = yaml.Unmarshal(mappingRaw, &m)
+		}
+		if err != nil {
+			t.Fatalf("mapping %s: %v", exp.Mapping, err)
+		}
+		result, err = importCSV(data, exp.Preset, &m)
+		if err != nil {
+			t.Fatalf("import failed: %v", err)
+		}
+	} else if result, err = importStatement(name, data, exp.Preset); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if result.Errors == nil {
+		result.Errors = []RowError{}
+	}
+
+	got := exp
+	got.Result, got.Transactions = result, result.Transactions
+	gotJSON, _ := json.MarshalIndent(got, "", "  ")
+	// Round-trip the expectation too, so both sides encode numbers alike.
+	wantJSON, _ := json.MarshalIndent(exp, "", "  ")
+	if string(gotJSON) == string(wantJSON) {
+		return
+	}
+	if *updateImports {
+		if err := os.MkdirAll(filepath.Dir(expectedPath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(expectedPath, append(gotJSON, '\n'), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("expected output updated")
+		return
+	}
+	t.Error(importDifference(exp, got))
+}
+
+// importDifference describes the first difference between two outcomes.
+func importDifference(want, got importExpectation) string {
+	wantResult, _ := json.Marshal(want.Result)
+	gotResult, _ := json.Marshal(got.Result)
+	if string(wantResult) != string(gotResult) {
+		return fmt.Sprintf("import report differs:\n    want %s\n    got  %s", wantResult, gotResult)
+	}
+	if len(want.Transactions) != len(got.Transactions) {
+		return fmt.Sprintf("%d transactions, want %d", len(got.Transactions), len(want.Transactions))
+	}
+	for i := range want.Transactions {
+		w, _ := json.Marshal(want.Transactions[i])
+		g, _ := json.Marshal(got.Transactions[i])
+		if string(w) != string(g) {
+			return fmt.Sprintf("transaction %d differs:\n    want %s\n    got  %s", i+1, w, g)
+		}
+	}
+	return "output differs"
+}
+
+// TestImportFixturesHaveExpectations catches fixtures no expected output
+// covers and expectations whose fixture is gone, which would otherwise pass
+// silently.
+func TestImportFixturesHaveExpectations(t *testing.T) {
+	entries, err := os.ReadDir(importFixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".yaml", ".yml", ".json":
+			continue
+		}
+		if e.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(importFixtures, "expected", e.Name()+".json")); err != nil {
+			t.Errorf("%s has no expected output", e.Name())
+		}
+	}
+	expected, _ := filepath.Glob(filepath.Join(importFixtures, "expected", "*.json"))
+	for _, path := range expected {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		if _, err := os.Stat(filepath.Join(importFixtures, name)); err != nil {
+			t.Errorf("expected output %s has no fixture", filepath.Base(path))
+		}
+	}
+}
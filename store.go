@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ============================================================================
+// LOCAL STATE STORE
+// ============================================================================
+// Automations (round-ups, auto-save rules, ...) need to remember what they
+// have already done between restarts. State is kept as small JSON files in
+// NEURAPAY_DATA_DIR (server.data_dir in the config; default: ./data).
+
+// dataDir returns the directory NeuraPay persists local state into.
+func dataDir() string {
+	if dir := os.Getenv("NEURAPAY_DATA_DIR"); dir != "" {
+		return dir
+	}
+	if configuredDataDir != "" {
+		return configuredDataDir
+	}
+	return "data"
+}
+
+// jsonStore persists a single value as a JSON file.
+type jsonStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func newJSONStore(name string) *jsonStore {
+	return &jsonStore{path: filepath.Join(dataDir(), name)}
+}
+
+// load decodes the stored value into v. A missing file leaves v untouched.
+func (s *jsonStore) load(v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", s.path, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// save writes v atomically so a crash never leaves a half-written file.
+func (s *jsonStore) save(v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", s.path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create data dir: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+)
+
+// ============================================================================
+// CUSTOM TOOLSET
+// ============================================================================
+// Builds every NeuraPay tool and the background services they share. main
+// registers the tools on the server through registerTools; the scenario
+// tests (harness_test.go) drive the same registry without one.
+
+// toolset is the custom tools plus the services main starts in the background.
+type toolset struct {
+	tools      []core.Tool
+	insights   *insightQueue
+	monitor    *monitor
+	notifier   *notifier
+	greeter    *sessionGreeter
+	contexts   *userContextStore
+	statements *statementStore
+	exports    *exportStore
+}
+
+func (t *toolset) add(tool core.Tool) {
+	t.tools = append(t.tools, tool)
+}
+
+func newToolset(liminalExecutor core.ToolExecutor, credentials *credentialStore, cfg *Config) *toolset {
+	t := &toolset{statements: newStatementStore(), exports: newExportStore(), contexts: newUserContextStore()}
+
+	t.add(createSpendingAnalyzerTool(liminalExecutor, t.statements, t.contexts))
+	log.Println("✅ Added custom spending analyzer tool")
+
+	t.add(createMoneyPersonality(liminalExecutor, t.statements, t.contexts))
+	log.Println("✅ Added Money Personality analyzer")
+
+	t.add(createCounterpartyAnalyzerTool(liminalExecutor, t.statements))
+	log.Println("✅ Added counterparty analyzer")
+
+	t.add(createDetectAnomaliesTool(liminalExecutor, t.statements))
+	log.Println("✅ Added unusual activity detector")
+
+	roundups := newRoundupService(liminalExecutor)
+	t.add(createRoundupSettingsTool(roundups))
+	t.add(createRoundupSummaryTool(roundups))
+	log.Println("✅ Added round-up savings tools")
+
+	autosave := newAutosaveService(liminalExecutor)
+	t.add(createAutosaveRuleTool(autosave))
+	t.add(createAutosaveCheckTool(autosave))
+	log.Println("✅ Added payday auto-save tools")
+
+	smoothing := newSmoothingService(liminalExecutor)
+	t.add(createSmoothingSettingsTool(smoothing))
+	t.add(createSmoothingLedgerTool(smoothing))
+	log.Println("✅ Added income smoothing tools")
+
+	spareCash := newSpareCashService(liminalExecutor)
+	t.add(createSpareCashTool(spareCash))
+	log.Println("✅ Added spare cash calculator")
+
+	ledgers := newLedgerService()
+	t.add(createRecordSharedExpenseTool(ledgers))
+	t.add(createBalancesWithTool(ledgers))
+	t.add(createSettleUpTool(ledgers))
+	log.Println("✅ Added shared expenses tools")
+
+	t.add(createSearchTransactionsTool(liminalExecutor, t.statements))
+	log.Println("✅ Added transaction search tool")
+
+	t.add(createExportTool(liminalExecutor, t.statements, t.exports))
+	log.Println("✅ Added transaction export tool")
+
+	// Background monitor: polls opted-in users, runs the savings automations
+	// and queues insights for their next conversation.
+
+	t.insights = newInsightQueue()
+	t.monitor = newMonitor(liminalExecutor, credentials, t.insights, cfg.MonitorInterval())
+	t.monitor.addJob("roundups", func(ctx context.Context, userID, requestID string) error {
+		ready, err := roundups.process(ctx, userID, requestID)
+		if ready != nil {
+			t.insights.push(userID, Insight{
+				Type:     "roundup_ready",
+				Severity: "info",
+				Title:    "Round-ups ready to save",
+				Message:  fmt.Sprintf("Your round-ups add up to $%.2f - want to move them to savings?", ready.Amount),
+				Data:     map[string]interface{}{"amount": ready.Amount, "currency": ready.Currency},
+				DedupKey: fmt.Sprintf("roundup:%.2f", ready.Amount),
+			})
+		}
+		return err
+	})
+	t.monitor.addJob("payday_autosave", func(ctx context.Context, userID, requestID string) error {
+		_, created, err := autosave.process(ctx, userID, requestID)
+		for _, transfer := range created {
+			msg := fmt.Sprintf("Payday! Want to save $%.2f of your $%.2f paycheck?", transfer.Amount, transfer.Paycheck)
+			if transfer.Status == "executed" {
+				msg = fmt.Sprintf("Payday! $%.2f of your $%.2f paycheck went straight to savings.", transfer.Amount, transfer.Paycheck)
+			}
+			t.insights.push(userID, Insight{
+				Type:     "payday_autosave",
+				Severity: "celebrate",
+				Title:    "Paycheck landed",
+				Message:  msg,
+				Data:     map[string]interface{}{"transfer_id": transfer.ID, "status": transfer.Status, "amount": transfer.Amount},
+				DedupKey: "autosave:" + transfer.ID,
+			})
+		}
+		return err
+	})
+	t.monitor.addJob("income_smoothing", func(ctx context.Context, userID, requestID string) error {
+		_, err := smoothing.process(ctx, userID, requestID)
+		return err
+	})
+
+	t.add(createMonitorSettingsTool(t.monitor))
+	t.add(createPendingInsightsTool(t.insights))
+	log.Println("✅ Added background monitoring tools")
+
+	// Insights are also pushed out through the user's notification channels.
+	t.notifier = newNotifier(defaultNotificationChannels(cfg.Notifications))
+	t.insights.subscribe(t.notifier.enqueue)
+	t.add(createNotificationSettingsTool(t.notifier))
+	log.Println("✅ Added notification settings tool")
+
+	t.add(createUserContextTool(t.contexts))
+	log.Println("✅ Added user context tool")
+
+	t.greeter = newSessionGreeter(liminalExecutor, spareCash, t.insights)
+	t.add(createStartSessionTool(t.greeter))
+	log.Println("✅ Added session-start greeting tool")
+
+	t.add(createCSVTransactionsTool())
+	log.Println("✅ Added CSV transactions reader (for testing)")
+
+	return t
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ============================================================================
+// LOCAL TRANSACTION CACHE
+// ============================================================================
+// Every analytic tool reads the user's history through get_transactions, and
+// the model likes to chain them (analyze_spending, then
+// analyze_money_personality, then get_spare_cash), so the same history used
+// to be downloaded several times a minute. cachingExecutor answers
+// get_transactions from a SQLite database in the data dir instead:
+//
+//   - A user's first read downloads transaction_limit transactions.
+//   - After that a sync asks for a small page of the newest ones and only
+//     asks for more (doubling the page) until it reaches the newest
+//     transaction it already has, so a sync normally costs one small call.
+//   - Within data.cache.max_age of the last sync nothing is fetched at all.
+//     Money movements through the executor (confirmed writes and the
+//     automations' direct calls) mark the user's cache stale, so a send
+//     shows up on the next read, and tools with a refresh option
+//     force a sync (withFreshTransactions).
+//   - If a sync fails the cached history is served, with a warning logged.
+//
+// Calls with inputs other than limit pass straight through to Liminal.
+
+const (
+	cacheSyncPage = 25   // first page of an incremental sync
+	cacheMaxPage  = 1000 // largest page a sync grows to
+)
+
+type freshTransactionsKey struct{}
+
+// withFreshTransactions marks ctx so get_transactions calls made with it sync
+// the cache first, whatever its age.
+func withFreshTransactions(ctx context.Context) context.Context {
+	return context.WithValue(ctx, freshTransactionsKey{}, true)
+}
+
+// syncState is where a user's cache stands.
+type syncState struct {
+	lastID   string    // newest transaction seen
+	lastAt   time.Time // and its time
+	syncedAt time.Time
+	complete bool // a sync reached the start of the history
+	count    int  // cached transactions
+}
+
+// transactionCache stores each user's Liminal transactions in SQLite.
+type transactionCache struct {
+	db     *sql.DB
+	maxAge time.Duration
+
+	mu    sync.Mutex
+	users map[string]*sync.Mutex // one sync at a time per user
+	stale map[string]bool        // money moved since the last sync
+}
+
+const transactionCacheSchema = `
+CREATE TABLE IF NOT EXISTS transactions (
+	user_id     TEXT NOT NULL,
+	tx_id       TEXT NOT NULL,
+	occurred_at INTEGER NOT NULL, -- unix seconds, 0 when unknown
+	data        TEXT NOT NULL,    -- the transaction as Liminal returned it
+	PRIMARY KEY (user_id, tx_id)
+);
+CREATE INDEX IF NOT EXISTS transactions_by_time ON transactions (user_id, occurred_at DESC);
+CREATE TABLE IF NOT EXISTS sync_state (
+	user_id   TEXT PRIMARY KEY,
+	last_id   TEXT NOT NULL,
+	last_at   INTEGER NOT NULL,
+	synced_at INTEGER NOT NULL,
+	complete  INTEGER NOT NULL
+);`
+
+// newTransactionCache opens (or creates) <data_dir>/transactions.db.
+func newTransactionCache(maxAge time.Duration) (*transactionCache, error) {
+	path := filepath.Join(dataDir(), "transactions.db")
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data dir: %w", err)
+	}
+	db, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	if _, err := db.Exec(transactionCacheSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to set up %s: %w", path, err)
+	}
+	return &transactionCache{db: db, maxAge: maxAge, users: make(map[string]*sync.Mutex), stale: make(map[string]bool)}, nil
+}
+
+// markStale makes the user's next read sync.
+func (c *transactionCache) markStale(userID string) {
+	c.mu.Lock()
+	c.stale[userID] = true
+	c.mu.Unlock()
+}
+
+func (c *transactionCache) state(userID string) (*syncState, error) {
+	var (
+		s                syncState
+		lastAt, syncedAt int64
+		complete         bool
+	)
+	err := c.db.QueryRow(`SELECT last_id, last_at, synced_at, complete FROM sync_state WHERE user_id = ?`, userID).
+		Scan(&s.lastID, &lastAt, &syncedAt, &complete)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.lastAt, s.syncedAt, s.complete = time.Unix(lastAt, 0), time.Unix(syncedAt, 0), complete
+	if err := c.db.QueryRow(`SELECT COUNT(*) FROM transactions WHERE user_id = ?`, userID).Scan(&s.count); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// transactions returns up to limit of the user's newest transactions,
+// syncing first when the cache is stale, too old, or holds fewer than limit
+// of a history that goes back further.
+func (c *transactionCache) transactions(ctx context.Context, inner core.ToolExecutor, userID, requestID string, limit int) ([]map[string]interface{}, error) {
+	c.mu.Lock()
+	lock := c.users[userID]
+	if lock == nil {
+		lock = &sync.Mutex{}
+		c.users[userID] = lock
+	}
+	stale := c.stale[userID]
+	c.mu.Unlock()
+	lock.Lock()
+	defer lock.Unlock()
+
+	state, err := c.state(userID)
+	if err != nil {
+		return nil, fmt.Errorf("transaction cache: %w", err)
+	}
+	forced, _ := ctx.Value(freshTransactionsKey{}).(bool)
+	fresh := state != nil && !forced && !stale &&
+		time.Since(state.syncedAt) < c.maxAge &&
+		(state.count >= limit || state.complete)
+	if !fresh {
+		if err := c.sync(ctx, inner, userID, requestID, state, limit); err != nil {
+			if state == nil || state.count == 0 {
+				return nil, err
+			}
+			log.Printf("⚠️  Transaction sync for %s failed, serving %d cached: %v", userID, state.count, err)
+		} else if stale {
+			c.mu.Lock()
+			delete(c.stale, userID)
+			c.mu.Unlock()
+		}
+	}
+	return c.read(userID, limit)
+}
+
+// sync fetches the transactions the cache is missing. Liminal returns the
+// newest first and only takes a limit, so each page is a larger prefix of
+// the history; a sync stops once a page reaches the newest transaction the
+// cache already had, or comes back short.
+func (c *transactionCache) sync(ctx context.Context, inner core.ToolExecutor, userID, requestID string, state *syncState, want int) error {
+	page := cacheSyncPage
+	if state == nil || (state.count < want && !state.complete) {
+		// First sync, or a caller wants more history than is cached.
+		page = want
+		if page < transactionFetchLimit {
+			page = transactionFetchLimit
+		}
+	}
+	complete := state != nil && state.complete
+
+	var newest map[string]interface{}
+	var newestAt time.Time
+	for {
+		fetched, err := fetchTransactions(ctx, inner, userID, requestID, page)
+		if err != nil {
+			return err
+		}
+		reached, err := c.store(userID, fetched, state)
+		if err != nil {
+			return fmt.Errorf("transaction cache: %w", err)
+		}
+		for _, tx := range fetched {
+			if at, _ := txTime(tx); newest == nil || at.After(newestAt) {
+				newest, newestAt = tx, at
+			}
+		}
+		if len(fetched) < page {
+			complete = true
+			break
+		}
+		if state == nil || reached {
+			break
+		}
+		if page >= cacheMaxPage {
+			log.Printf("⚠️  Transaction sync for %s stopped at %d; older transactions may be missing from the cache", userID, page)
+			break
+		}
+		page *= 2
+	}
+
+	lastID, lastAt := "", time.Time{}
+	if state != nil {
+		lastID, lastAt = state.lastID, state.lastAt
+	}
+	if newest != nil && (lastID == "" || newestAt.After(lastAt)) {
+		lastID, lastAt = txID(newest), newestAt
+	}
+	_, err := c.db.Exec(`INSERT INTO sync_state (user_id, last_id, last_at, synced_at, complete) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET last_id = excluded.last_id, last_at = excluded.last_at, synced_at = excluded.synced_at, complete = excluded.complete`,
+		userID, lastID, lastAt.Unix(), time.Now().Unix(), complete)
+	if err != nil {
+		return fmt.Errorf("transaction cache: %w", err)
+	}
+	return nil
+}
+
+// store adds the transactions the cache doesn't have yet, updates the ones
+// it has (a status change, a corrected amount), and reports whether the page
+// reached the newest one it had before this sync.
+func (c *transactionCache) store(userID string, transactions []map[string]interface{}, state *syncState) (bool, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	insert, err := tx.Prepare(`INSERT INTO transactions (user_id, tx_id, occurred_at, data) VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id, tx_id) DO UPDATE SET data = excluded.data, occurred_at = excluded.occurred_at`)
+	if err != nil {
+		return false, err
+	}
+	defer insert.Close()
+
+	reached := false
+	for _, t := range transactions {
+		id := txID(t)
+		var at int64
+		if ts, ok := txTime(t); ok {
+			at = ts.Unix()
+			if state != nil && !ts.After(state.lastAt) {
+				reached = true
+			}
+		}
+		if state != nil && id == state.lastID {
+			reached = true
+		}
+		data, err := json.Marshal(t)
+		if err != nil {
+			return false, err
+		}
+		if _, err := insert.Exec(userID, id, at, string(data)); err != nil {
+			return false, err
+		}
+	}
+	return reached, tx.Commit()
+}
+
+// read returns the user's newest cached transactions, newest first like
+// Liminal.
+func (c *transactionCache) read(userID string, limit int) ([]map[string]interface{}, error) {
+	rows, err := c.db.Query(`SELECT data FROM transactions WHERE user_id = ? ORDER BY occurred_at DESC, rowid DESC LIMIT ?`, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("transaction cache: %w", err)
+	}
+	defer rows.Close()
+	var out []map[string]interface{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("transaction cache: %w", err)
+		}
+		var t map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &t); err != nil {
+			return nil, fmt.Errorf("transaction cache: %w", err)
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// cachingExecutor serves get_transactions from a transactionCache.
+type cachingExecutor struct {
+	inner core.ToolExecutor
+	cache *transactionCache
+}
+
+func newCachingExecutor(inner core.ToolExecutor, cache *transactionCache) *cachingExecutor {
+	return &cachingExecutor{inner: inner, cache: cache}
+}
+
+func (e *cachingExecutor) Execute(ctx context.Context, req *core.ExecuteRequest) (*core.ExecuteResponse, error) {
+	if simWriteTools[req.Tool] {
+		// The automations (round-ups, auto-save, smoothing) move money
+		// through Execute, without a confirmation step.
+		defer e.cache.markStale(req.UserID)
+	}
+	if req.Tool != "get_transactions" {
+		return e.inner.Execute(ctx, req)
+	}
+	var input map[string]interface{}
+	if len(req.Input) > 0 {
+		if err := json.Unmarshal(req.Input, &input); err != nil {
+			return e.inner.Execute(ctx, req)
+		}
+	}
+	limit := 50 // Liminal's default
+	for key, v := range input {
+		n, ok := numberValue(v)
+		if key != "limit" || !ok || n < 1 {
+			// Filters and cursors are Liminal's to answer.
+			return e.inner.Execute(ctx, req)
+		}
+		limit = int(n)
+	}
+
+	transactions, err := e.cache.transactions(ctx, e.inner, req.UserID, req.RequestID, limit)
+	if err != nil {
+		return &core.ExecuteResponse{Success: false, Error: err.Error()}, nil
+	}
+	if transactions == nil {
+		transactions = []map[string]interface{}{}
+	}
+	data, err := json.Marshal(map[string]interface{}{"transactions": transactions, "count": len(transactions)})
+	if err != nil {
+		return nil, err
+	}
+	return &core.ExecuteResponse{Success: true, Data: data}, nil
+}
+
+func (e *cachingExecutor) ExecuteWrite(ctx context.Context, req *core.ExecuteRequest) (*core.ExecuteResponse, error) {
+	e.cache.markStale(req.UserID)
+	return e.inner.ExecuteWrite(ctx, req)
+}
+
+func (e *cachingExecutor) Confirm(ctx context.Context, userID, confirmationID string) (*core.ExecuteResponse, error) {
+	e.cache.markStale(userID)
+	return e.inner.Confirm(ctx, userID, confirmationID)
+}
+
+func (e *cachingExecutor) Cancel(ctx context.Context, userID, confirmationID string) error {
+	return e.inner.Cancel(ctx, userID, confirmationID)
+}
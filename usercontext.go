@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/becomeliminal/nim-go-sdk/core"
+	"github.com/becomeliminal/nim-go-sdk/tools"
+)
+
+// ============================================================================
+// CUSTOM TOOL: USER CONTEXT
+// ============================================================================
+// What the user has told NeuraPay about themselves - name, preferred
+// currency, locale, timezone and money goals. start_session renders it through the
+// prompt's "user" template (prompt.go) so every conversation starts with it.
+
+// maxUserGoals bounds how many goals are kept per user.
+const maxUserGoals = 10
+
+// UserContext is the per-user part of the prompt.
+type UserContext struct {
+	Name      string    `json:"name,omitempty"`
+	Currency  string    `json:"preferred_currency,omitempty"`
+	Locale    string    `json:"locale,omitempty"`
+	Timezone  string    `json:"timezone,omitempty"` // IANA name, for when they spend
+	Goals     []string  `json:"goals,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// userContextStore keeps every user's context.
+type userContextStore struct {
+	store *jsonStore
+
+	mu    sync.Mutex
+	users map[string]UserContext
+}
+
+func newUserContextStore() *userContextStore {
+	s := &userContextStore{
+		store: newJSONStore("user_context.json"),
+		users: make(map[string]UserContext),
+	}
+	if err := s.store.load(&s.users); err != nil {
+		log.Printf("⚠️  User context not loaded: %v", err)
+	}
+	return s
+}
+
+func (s *userContextStore) get(userID string) UserContext {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	uc := s.users[userID]
+	uc.Goals = append([]string(nil), uc.Goals...)
+	return uc
+}
+
+func (s *userContextStore) set(userID string, uc UserContext) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	uc.UpdatedAt = time.Now()
+	s.users[userID] = uc
+	if err := s.store.save(s.users); err != nil {
+		log.Printf("⚠️  Failed to save user context: %v", err)
+	}
+}
+
+func createUserContextTool(contexts *userContextStore) core.Tool {
+	return tools.New("update_user_context").
+		Description("Remember the user's name, preferred currency, locale, timezone or money goals for future conversations. Only pass what the user told you; omitted fields are kept.").
+		Schema(tools.ObjectSchema(map[string]interface{}{
+			"name":               tools.StringProperty("What the user likes to be called"),
+			"preferred_currency": tools.StringProperty("Currency code to default to, e.g. USD"),
+			"locale":             tools.StringProperty("Locale such as en-US or es-MX"),
+			"timezone":           tools.StringProperty("IANA timezone such as America/New_York, used to read when the user spends"),
+			"goals": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "The user's money goals in their words; replaces the saved list",
+			},
+			"add_goal":    tools.StringProperty("One goal to add to the saved list"),
+			"remove_goal": tools.StringProperty("A saved goal to drop"),
+		})).
+		Handler(func(ctx context.Context, toolParams *core.ToolParams) (*core.ToolResult, error) {
+			var params struct {
+				Name       *string  `json:"name"`
+				Currency   *string  `json:"preferred_currency"`
+				Locale     *string  `json:"locale"`
+				Timezone   *string  `json:"timezone"`
+				Goals      []string `json:"goals"`
+				AddGoal    string   `json:"add_goal"`
+				RemoveGoal string   `json:"remove_goal"`
+			}
+			if err := json.Unmarshal(toolParams.Input, &params); err != nil {
+				return &core.ToolResult{
+					Success: false,
+					Error:   fmt.Sprintf("invalid input: %v", err),
+				}, nil
+			}
+
+			uc := contexts.get(toolParams.UserID)
+			if params.Name != nil {
+				uc.Name = strings.TrimSpace(*params.Name)
+			}
+			if params.Currency != nil {
+				uc.Currency = strings.ToUpper(strings.TrimSpace(*params.Currency))
+			}
+			if params.Locale != nil {
+				uc.Locale = strings.TrimSpace(*params.Locale)
+			}
+			if params.Timezone != nil {
+				uc.Timezone = strings.TrimSpace(*params.Timezone)
+				if _, err := heatmapLocation(uc.Timezone); err != nil {
+					return &core.ToolResult{Success: false, Error: err.Error()}, nil
+				}
+			}
+			if params.Goals != nil {
+				uc.Goals = nil
+				for _, g := range params.Goals {
+					if g = strings.TrimSpace(g); g != "" {
+						uc.Goals = append(uc.Goals, g)
+					}
+				}
+			}
+			if g := strings.TrimSpace(params.AddGoal); g != "" {
+				uc.Goals = append(uc.Goals, g)
+			}
+			if g := strings.TrimSpace(params.RemoveGoal); g != "" {
+				var kept []string
+				for _, existing := range uc.Goals {
+					if !strings.EqualFold(existing, g) {
+						kept = append(kept, existing)
+					}
+				}
+				uc.Goals = kept
+			}
+
+			if len(uc.Currency) != 0 && len(uc.Currency) != 3 {
+				return &core.ToolResult{
+					Success: false,
+					Error:   fmt.Sprintf("preferred_currency must be a 3-letter code, got %q", uc.Currency),
+				}, nil
+			}
+			if len(uc.Goals) > maxUserGoals {
+				return &core.ToolResult{
+					Success: false,
+					Error:   fmt.Sprintf("at most %d goals can be saved; remove one first", maxUserGoals),
+				}, nil
+			}
+
+			contexts.set(toolParams.UserID, uc)
+			return &core.ToolResult{
+				Success: true,
+				Data:    contexts.get(toolParams.UserID),
+			}, nil
+		}).
+		Build()
+}